@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	onepassword "github.com/hinterland-software/openv/internal/1password"
+	"github.com/hinterland-software/openv/internal/dotenv"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/store"
+)
+
+// secretReferenceResolver returns a function resolving op:// secret references through the secret store.
+// Values that are not secret references are returned unchanged.
+func secretReferenceResolver(secretStore store.SecretStore) func(string) (string, error) {
+	return func(value string) (string, error) {
+		if !onepassword.IsSecretReference(value) {
+			return value, nil
+		}
+		resolver, ok := secretStore.(store.Resolver)
+		if !ok {
+			return "", fmt.Errorf("the selected secret store cannot resolve secret reference %s", value)
+		}
+		logging.Logger.Debug("resolving secret reference", "reference", value)
+		return resolver.ResolveReference(value)
+	}
+}
+
+// expandEnvironment resolves secret references and expands variable references in all other values.
+// Resolved secrets are taken literally, a $ in them is never expanded. Variables already expanded on import
+// are returned unchanged, expanding them again would expand references unescaped by the first expansion.
+// References to variables not defined in the environment fall back to the process environment.
+func expandEnvironment(secretStore store.SecretStore, environment *store.Environment) (map[string]string, error) {
+	if environment.Expanded {
+		logging.Logger.Debug("environment variables were expanded on import, skipping expansion")
+		return environment.Variables, nil
+	}
+	return dotenv.ExpandAndResolveAll(environment.Variables, os.LookupEnv, secretReferenceResolver(secretStore))
+}
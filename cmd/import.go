@@ -5,29 +5,38 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
 
 	onepassword "github.com/hinterland-software/openv/internal/1password"
-	"github.com/hinterland-software/openv/internal/cli"
+	"github.com/hinterland-software/openv/internal/heroku"
 	"github.com/hinterland-software/openv/internal/logging"
 	"github.com/hinterland-software/openv/internal/profile"
+	"github.com/hinterland-software/openv/internal/source"
+	"github.com/hinterland-software/openv/internal/store"
 	"github.com/spf13/cobra"
 )
 
 // importCmd represents the import command
 var importCmd = &cobra.Command{
 	Use:   "import [flags]",
-	Short: "Import environment variables into 1Password",
-	Long: `Import environment variables from a specified .env file into 1Password. 
+	Short: "Import environment variables into the secret store",
+	Long: `Import environment variables from a specified .env, JSON, YAML or TOML file into the secret store (1Password by default). 
 The variables are stored securely with metadata and can be synchronized with different profiles.
+The format is detected from the file extension unless set with --format. Nested keys are flattened to PARENT_CHILD names.
+Use --file - to read from standard input, or --from-env to capture variables of the current process environment.
+Use --from heroku:<app> to import the config vars of a Heroku app, authenticated with HEROKU_API_KEY.
 Example usage:
-  openv import --url github.com/org/repo --env staging --file .env.staging`,
+  openv import --url github.com/org/repo --env staging --file .env.staging
+  openv import --url github.com/org/repo --env staging --file .env.staging --expand
+  openv import --url github.com/org/repo --env staging --file .env.staging --plain 'PUBLIC_*,LOG_LEVEL'
+  openv import --url github.com/org/repo --env production --file config.production.yaml --separator __
+  heroku config --shell --app my-app | openv import --url github.com/org/repo --env production --file -
+  HEROKU_API_KEY=... openv import --url github.com/org/repo --env production --from heroku:my-app
+  openv import --url github.com/org/repo --env ci --from-env 'APP_*,DATABASE_URL'`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var err error
-		opServiceAuthToken, err = cli.GetToken(cmd)
-		if err != nil {
-			return fmt.Errorf("❌ failed to get token: %w", err)
-		}
-
 		url, err := cmd.Flags().GetString("url")
 		if err != nil {
 			return fmt.Errorf("❌ failed to get url flag: %w", err)
@@ -46,24 +55,36 @@ Example usage:
 			return fmt.Errorf("❌ failed to get file flag: %w", err)
 		}
 		vaultTitle, _ := cmd.Flags().GetString("vault")
+		expand, _ := cmd.Flags().GetBool("expand")
+		plainPatterns, _ := cmd.Flags().GetStringSlice("plain")
+		fromEnv, _ := cmd.Flags().GetStringSlice("from-env")
+		from, _ := cmd.Flags().GetString("from")
+		format, _ := cmd.Flags().GetString("format")
+		separator, _ := cmd.Flags().GetString("separator")
+		if format == "" {
+			format = string(source.DetectFormat(file))
+		}
+		if err := source.ValidateFormat(source.Format(format)); err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
 
 		logging.Logger.Debug("starting import",
 			"url", url,
 			"name", name,
 			"env", env,
 			"file", file,
-			"vault", vaultTitle)
+			"format", format,
+			"from-env", fromEnv,
+			"from", from,
+			"vault", vaultTitle,
+			"expand", expand,
+			"plain", plainPatterns)
 
-		if vaultTitle == "" {
-			vaultTitle = onepassword.DefaultVault
-			logging.Logger.Debug("using default vault", "vault", vaultTitle)
-		}
 		syncProfiles, _ := cmd.Flags().GetStringSlice("sync-profiles")
 
-		logging.Logger.Debug("connecting to 1Password")
-		service, err := onepassword.NewService(cmd.Context(), opServiceAuthToken)
+		secretStore, err := initializeSecretStore(cmd)
 		if err != nil {
-			return fmt.Errorf("❌ failed to create 1Password service: %w", err)
+			return err
 		}
 
 		// Validate profiles exist
@@ -73,19 +94,27 @@ Example usage:
 			return err
 		}
 
-		logging.Logger.Debug("looking up vault", "vault", vaultTitle)
-		vault, err := service.GetVault(vaultTitle)
+		variables, expanded, err := readVariables(cmd, secretStore, file, source.Format(format), fromEnv, from, source.Options{
+			Separator: separator,
+			Expand:    expand,
+		})
 		if err != nil {
-			return fmt.Errorf("❌ failed to get vault: %w", err)
+			return fmt.Errorf("❌ %w", err)
 		}
 
-		opts := onepassword.ImportOptions{
+		plain, err := matchKeys(variables, plainPatterns)
+		if err != nil {
+			return fmt.Errorf("❌ invalid plain pattern: %w", err)
+		}
+
+		opts := store.PutOptions{
 			Name:         name,
 			Env:          env,
-			FilePath:     file,
 			URL:          baseURL,
-			VaultID:      vault.ID,
 			SyncProfiles: syncProfiles,
+			Variables:    variables,
+			Plain:        plain,
+			Expanded:     expanded,
 		}
 
 		logging.Logger.Debug("importing environment variables",
@@ -93,14 +122,14 @@ Example usage:
 			"env", env,
 			"sync-profiles", syncProfiles)
 
-		item, err := service.Import(opts)
+		environment, err := secretStore.Put(opts)
 		if err != nil {
 			return fmt.Errorf("❌ failed to import environment variables: %w", err)
 		}
 
 		logging.Logger.Info("successfully imported environment variables",
 			"name", name,
-			"item_id", item.ID)
+			"item_id", environment.ID)
 		return nil
 	},
 }
@@ -109,12 +138,116 @@ func init() {
 	rootCmd.AddCommand(importCmd)
 
 	importCmd.Flags().String("env", "", "Environment (e.g., production, staging)")
-	importCmd.Flags().String("file", "", "Path to the environment file to import")
+	importCmd.Flags().String("file", "", "Path to the environment file to import, - to read from standard input")
+	importCmd.Flags().StringSlice("from-env", []string{}, "Import variables of the process environment matching the names or glob patterns (e.g. APP_*)")
+	importCmd.Flags().String("from", "", "Import variables of a deployment platform (heroku:<app>)")
+	importCmd.Flags().String("format", "", fmt.Sprintf("Input file format (%s), detected from the file extension by default", strings.Join(source.FormatsToStrings(), ", ")))
+	importCmd.Flags().String("separator", source.DefaultSeparator, "Separator joining nested keys of JSON, YAML and TOML files")
 	importCmd.Flags().String("url", "", "Service URL")
 	importCmd.Flags().String("vault", onepassword.DefaultVault, "1Password vault to use")
 	importCmd.Flags().StringSlice("sync-profiles", []string{}, "Sync profiles to use")
+	importCmd.Flags().StringSlice("plain", []string{}, "Keys or glob patterns (e.g. PUBLIC_*) of variables that are not sensitive")
+	importCmd.Flags().Bool("expand", false, "Expand ${VAR} references and resolve op:// secret references before storing")
 
 	cobra.CheckErr(importCmd.MarkFlagRequired("env"))
-	cobra.CheckErr(importCmd.MarkFlagRequired("file"))
 	cobra.CheckErr(importCmd.MarkFlagRequired("url"))
+	importCmd.MarkFlagsOneRequired("file", "from-env", "from")
+	importCmd.MarkFlagsMutuallyExclusive("file", "from-env", "from")
+}
+
+// readVariables reads the variables to import from the file, standard input, the process environment or a deployment platform.
+// It reports whether references were expanded, which only files and standard input support.
+func readVariables(cmd *cobra.Command, secretStore store.SecretStore, file string, format source.Format, fromEnv []string, from string, opts source.Options) (map[string]string, bool, error) {
+	if opts.Expand {
+		opts.Lookup = os.LookupEnv
+		opts.Resolve = secretReferenceResolver(secretStore)
+	}
+
+	switch {
+	case len(fromEnv) > 0:
+		logging.Logger.Debug("reading process environment", "patterns", fromEnv)
+		environ := source.ParseEnviron(os.Environ())
+		keys, err := matchKeys(environ, fromEnv)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid from-env pattern: %w", err)
+		}
+		if len(keys) == 0 {
+			return nil, false, fmt.Errorf("no environment variables match %s", strings.Join(fromEnv, ", "))
+		}
+		variables := make(map[string]string, len(keys))
+		for _, key := range keys {
+			variables[key] = environ[key]
+		}
+		variables, err = resolveReferences(variables, opts)
+		return variables, opts.Expand, err
+	case from != "":
+		logging.Logger.Debug("reading deployment platform", "from", from)
+		variables, err := readPlatform(from)
+		if err != nil {
+			return nil, false, err
+		}
+		variables, err = resolveReferences(variables, opts)
+		return variables, opts.Expand, err
+	case file == "-":
+		logging.Logger.Debug("reading standard input", "format", format)
+		variables, err := source.ParseReader(cmd.InOrStdin(), format, opts)
+		return variables, opts.Expand, err
+	default:
+		logging.Logger.Debug("reading environment file", "file", file, "format", format)
+		variables, err := source.ParseFile(file, format, opts)
+		return variables, opts.Expand, err
+	}
+}
+
+// resolveReferences resolves secret references when expanding, values of the process environment
+// and deployment platforms are final, so ${VAR} references are kept
+func resolveReferences(variables map[string]string, opts source.Options) (map[string]string, error) {
+	if !opts.Expand {
+		return variables, nil
+	}
+	for key, value := range variables {
+		resolved, err := opts.Resolve(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve value of %s: %w", key, err)
+		}
+		variables[key] = resolved
+	}
+	return variables, nil
+}
+
+// readPlatform reads the variables of a deployment platform given as <platform>:<name>
+func readPlatform(from string) (map[string]string, error) {
+	platform, name, found := strings.Cut(from, ":")
+	if !found || name == "" {
+		return nil, fmt.Errorf("invalid source %s, use <platform>:<name> like heroku:my-app", from)
+	}
+	switch platform {
+	case "heroku":
+		token := os.Getenv("HEROKU_API_KEY")
+		if token == "" {
+			return nil, fmt.Errorf("HEROKU_API_KEY is required to import from Heroku")
+		}
+		return heroku.NewHerokuService(token, "").ImportConfigVars(name)
+	default:
+		return nil, fmt.Errorf("unsupported source platform %s, supported: heroku", platform)
+	}
+}
+
+// matchKeys returns the sorted keys of variables matching any of the glob patterns
+func matchKeys(variables map[string]string, patterns []string) ([]string, error) {
+	keys := []string{}
+	for key := range variables {
+		for _, pattern := range patterns {
+			matched, err := path.Match(pattern, key)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
 }
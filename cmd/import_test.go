@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal/source"
+	"github.com/hinterland-software/openv/internal/store"
+	"github.com/spf13/cobra"
+)
+
+func TestReadVariablesFromStdin(t *testing.T) {
+	tests := []struct {
+		name   string
+		format source.Format
+		input  string
+		expand bool
+		want   map[string]string
+	}{
+		{
+			name:   "dotenv",
+			format: source.FormatDotenv,
+			input:  "export GREETING=hello\nMESSAGE=\"${GREETING} world\"\n",
+			want:   map[string]string{"GREETING": "hello", "MESSAGE": "${GREETING} world"},
+		},
+		{
+			name:   "dotenv expanded",
+			format: source.FormatDotenv,
+			input:  "GREETING=hello\nMESSAGE=\"${GREETING} world\"\n",
+			expand: true,
+			want:   map[string]string{"GREETING": "hello", "MESSAGE": "hello world"},
+		},
+		{
+			name:   "json",
+			format: source.FormatJSON,
+			input:  `{"database": {"host": "localhost"}}`,
+			want:   map[string]string{"DATABASE_HOST": "localhost"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cmd.SetIn(strings.NewReader(tt.input))
+
+			got, expanded, err := readVariables(cmd, store.NewMemoryStore(), "-", tt.format, nil, "", source.Options{Expand: tt.expand})
+			if err != nil {
+				t.Fatalf("readVariables() error = %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("readVariables() = %v, want %v", got, tt.want)
+			}
+			if expanded != tt.expand {
+				t.Errorf("readVariables() expanded = %t, want %t", expanded, tt.expand)
+			}
+		})
+	}
+}
+
+func TestReadVariablesFromEnv(t *testing.T) {
+	t.Setenv("OPENV_TEST_APP_NAME", "app")
+	t.Setenv("OPENV_TEST_APP_URL", "https://${HOST}")
+	t.Setenv("OPENV_TEST_PASSWORD", "op://vault/db/password")
+	t.Setenv("OPENV_TEST_OTHER", "ignored")
+	secretStore := &resolvingStore{
+		MemoryStore: store.NewMemoryStore(),
+		secrets:     map[string]string{"op://vault/db/password": "pa$word"},
+	}
+	patterns := []string{"OPENV_TEST_APP_*", "OPENV_TEST_PASSWORD"}
+
+	got, expanded, err := readVariables(&cobra.Command{}, secretStore, "", "", patterns, "", source.Options{})
+	if err != nil {
+		t.Fatalf("readVariables() error = %v", err)
+	}
+	want := map[string]string{
+		"OPENV_TEST_APP_NAME": "app",
+		"OPENV_TEST_APP_URL":  "https://${HOST}",
+		"OPENV_TEST_PASSWORD": "op://vault/db/password",
+	}
+	if !maps.Equal(got, want) || expanded {
+		t.Errorf("readVariables() = %v, expanded %t, want %v", got, expanded, want)
+	}
+
+	// References are resolved and stored as final values, so ${VAR} and a $ in a secret stay literal
+	got, expanded, err = readVariables(&cobra.Command{}, secretStore, "", "", patterns, "", source.Options{Expand: true})
+	if err != nil {
+		t.Fatalf("readVariables() error = %v", err)
+	}
+	want["OPENV_TEST_PASSWORD"] = "pa$word"
+	if !maps.Equal(got, want) || !expanded {
+		t.Errorf("readVariables() = %v, expanded %t, want %v expanded", got, expanded, want)
+	}
+	values, err := expandEnvironment(secretStore, &store.Environment{Variables: got, Expanded: expanded})
+	if err != nil {
+		t.Fatalf("expandEnvironment() error = %v", err)
+	}
+	if !maps.Equal(values, want) {
+		t.Errorf("expandEnvironment() = %v, want the stored values unchanged", values)
+	}
+
+	if _, _, err := readVariables(&cobra.Command{}, secretStore, "", "", []string{"OPENV_TEST_MISSING_*"}, "", source.Options{}); err == nil {
+		t.Error("readVariables() without matching variables succeeded")
+	}
+}
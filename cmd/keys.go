@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hinterland-software/openv/internal/filestore"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage identities and recipients of the encrypted file store",
+	Long: `Generate identities and manage the recipients that can decrypt the encrypted environment file used by the file store.
+Example usage:
+  openv keys generate
+  openv keys add openv-... --name ci-runner
+  openv keys remove ci-runner`,
+}
+
+var keysGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a new identity",
+	Long:  `Generate a new identity and write it to the identity file. The printed public key can be added as a recipient of an encrypted file.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := identityFilePath()
+		if err != nil {
+			return err
+		}
+
+		identity, err := filestore.GenerateIdentity()
+		if err != nil {
+			return fmt.Errorf("❌ failed to generate identity: %w", err)
+		}
+		if err := filestore.WriteIdentityFile(path, identity); err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+
+		logging.Logger.Info("identity generated", "file", path, "public_key", identity.Recipient())
+		return nil
+	},
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the recipients of the encrypted file",
+	Long:  `Display the public keys that can decrypt the encrypted environment file.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := viper.GetString("store-file")
+		recipients, err := filestore.NewStore(path, nil).Recipients()
+		if err != nil {
+			return fmt.Errorf("❌ failed to read recipients: %w", err)
+		}
+
+		if len(recipients) == 0 {
+			logging.Logger.Info("No recipients configured", "file", path)
+			return nil
+		}
+
+		for _, r := range recipients {
+			logging.Logger.Info("Recipient", "name", r.Name, "public_key", r.PublicKey)
+		}
+		return nil
+	},
+}
+
+var keysAddCmd = &cobra.Command{
+	Use:   "add [public-key]",
+	Short: "Add a recipient to the encrypted file",
+	Long:  `Allow another public key to decrypt the encrypted environment file. The file is re-encrypted using your identity.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+
+		fileStore, err := initializeFileStore()
+		if err != nil {
+			return err
+		}
+		if err := fileStore.AddRecipient(name, args[0]); err != nil {
+			return fmt.Errorf("❌ failed to add recipient: %w", err)
+		}
+
+		logging.Logger.Info("recipient added", "name", name, "public_key", args[0])
+		return nil
+	},
+}
+
+var keysRemoveCmd = &cobra.Command{
+	Use:   "remove [name|public-key]",
+	Short: "Remove a recipient from the encrypted file",
+	Long:  `Remove a recipient by name or public key. The file is re-encrypted with a new data key, so the removed key cannot decrypt future versions.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fileStore, err := initializeFileStore()
+		if err != nil {
+			return err
+		}
+		if err := fileStore.RemoveRecipient(args[0]); err != nil {
+			return fmt.Errorf("❌ failed to remove recipient: %w", err)
+		}
+
+		logging.Logger.Info("recipient removed", "recipient", args[0])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(keysGenerateCmd)
+	keysCmd.AddCommand(keysListCmd)
+	keysCmd.AddCommand(keysAddCmd)
+	keysCmd.AddCommand(keysRemoveCmd)
+
+	keysAddCmd.Flags().String("name", "", "Recipient name")
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/profile"
+	"github.com/hinterland-software/openv/internal/store"
+)
+
+// ownedTarget identifies a write-only sync target in the owned keys of an environment
+func ownedTarget(syncType profile.SyncType, location string) string {
+	return fmt.Sprintf("%s:%s", syncType, location)
+}
+
+// recordOwned stores the keys synced to a target with the environment in the secret store,
+// so the next sync from any machine can delete the keys removed since
+func recordOwned(secretStore store.SecretStore, envVars *store.Environment, target string) error {
+	err := secretStore.SetOwned(store.GetEnvironmentOptions{URL: envVars.URL, Env: envVars.Env}, target, syncedKeys(envVars))
+	if err != nil {
+		return fmt.Errorf("failed to record synced keys: %w", err)
+	}
+	return nil
+}
+
+// syncedKeys returns the keys of the environment without the OPENV_KEYS bookkeeping entry
+func syncedKeys(envVars *store.Environment) []string {
+	keys := []string{}
+	for key := range envVars.Variables {
+		if key != internal.OPENV_KEYS {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
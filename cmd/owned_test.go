@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal/profile"
+	"github.com/hinterland-software/openv/internal/store"
+)
+
+func TestRecordOwned(t *testing.T) {
+	secretStore := store.NewMemoryStore()
+	if _, err := secretStore.Put(store.PutOptions{
+		Name:      "app",
+		Env:       "production",
+		URL:       "github.com/org/app",
+		Variables: map[string]string{"B": "2", "A": "1"},
+	}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	envVars, err := retrieveEnvironmentVariables(secretStore, nil, "https://github.com/org/app", "production")
+	if err != nil {
+		t.Fatalf("retrieveEnvironmentVariables() error = %v", err)
+	}
+
+	target := ownedTarget(profile.DenoDeploy, "app")
+	if err := recordOwned(secretStore, envVars, target); err != nil {
+		t.Fatalf("recordOwned() error = %v", err)
+	}
+
+	// Another machine reads the owned keys from the secret store
+	envVars, err = retrieveEnvironmentVariables(secretStore, nil, "https://github.com/org/app", "production")
+	if err != nil {
+		t.Fatalf("retrieveEnvironmentVariables() error = %v", err)
+	}
+	if got := envVars.Owned[target]; !slices.Equal(got, []string{"A", "B"}) {
+		t.Errorf("Owned[%s] = %v, want [A B] without the bookkeeping key", target, got)
+	}
+}
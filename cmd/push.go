@@ -3,17 +3,23 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
+	"path"
 	"slices"
 	"sort"
 	"strings"
 
 	"github.com/hinterland-software/openv/internal"
 	onepassword "github.com/hinterland-software/openv/internal/1password"
-	"github.com/hinterland-software/openv/internal/cli"
-	"github.com/hinterland-software/openv/internal/github"
+	"github.com/hinterland-software/openv/internal/circleci"
+	"github.com/hinterland-software/openv/internal/export"
+	"github.com/hinterland-software/openv/internal/kubernetes"
 	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/netlify"
 	"github.com/hinterland-software/openv/internal/profile"
+	"github.com/hinterland-software/openv/internal/store"
+	"github.com/hinterland-software/openv/internal/vercel"
 	"github.com/hinterland-software/openv/internal/version"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
@@ -23,16 +29,33 @@ import (
 var pushCmd = &cobra.Command{
 	Use:   "push",
 	Short: "Push environment variables to a file or sync profile",
-	Long: `Push environment variables stored in 1Password to a local .env file or sync them to a specified service using a sync profile.
+	Long: `Push environment variables stored in the secret store (1Password by default) to a local .env file or sync them to a specified service using a sync profile.
 Example usage:
   openv push --url github.com/org/repo --env production --file .env
-  openv push --url github.com/org/repo --env production --profile my-github-profile`,
+  openv push --url github.com/org/repo --env production --file env.json --format json
+  openv push --url github.com/org/repo --env production --file secret.yaml --format kubernetes --k8s-namespace web
+  openv push --url github.com/org/repo --env production --profile my-github-profile
+  openv push --url github.com/org/repo --env production --profile my-github-profile --plan --plan-out plan.json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		profileName, _ := cmd.Flags().GetString("profile")
 		url, _ := cmd.Flags().GetString("url")
 		env, _ := cmd.Flags().GetString("env")
 		file, _ := cmd.Flags().GetString("file")
+		format, _ := cmd.Flags().GetString("format")
+		k8sName, _ := cmd.Flags().GetString("k8s-name")
+		k8sNamespace, _ := cmd.Flags().GetString("k8s-namespace")
+		k8sLabels, _ := cmd.Flags().GetStringToString("k8s-labels")
+		kubernetesOpts := export.KubernetesOptions{
+			Name:      k8sName,
+			Namespace: k8sNamespace,
+			Labels:    k8sLabels,
+		}
 		force, _ := cmd.Flags().GetBool("force")
+		planOnly, _ := cmd.Flags().GetBool("plan")
+		planOut, _ := cmd.Flags().GetString("plan-out")
+		if planOut != "" {
+			planOnly = true
+		}
 		vaultTitle, _ := cmd.Flags().GetString("vault")
 
 		logging.Logger.Debug("push command parameters",
@@ -40,21 +63,24 @@ Example usage:
 			"url", url,
 			"env", env,
 			"file", file,
+			"format", format,
 			"force", force,
+			"plan", planOnly,
 			"vault", vaultTitle)
 
-		var err error
-		opServiceAuthToken, err = cli.GetToken(cmd)
-		if err != nil {
-			return fmt.Errorf("❌ failed to get token: %w", err)
+		if err := export.ValidateFormat(export.Format(format)); err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+
+		if planOnly && profileName == "" {
+			return fmt.Errorf("❌ --plan requires a sync profile")
 		}
-		logging.Logger.Debug("successfully obtained auth token")
 
-		op, vaultID, err := initializeOnePasswordService(cmd, vaultTitle)
+		secretStore, err := initializeSecretStore(cmd)
 		if err != nil {
 			return err
 		}
-		logging.Logger.Debug("1Password service initialized", "vault_id", vaultID)
+		logging.Logger.Debug("secret store initialized")
 
 		var configProfile *profile.Profile
 		if profileName != "" {
@@ -66,49 +92,30 @@ Example usage:
 			logging.Logger.Debug("profile found", "profile", configProfile.Name)
 		}
 
-		envVars, err := retrieveEnvironmentVariables(op, configProfile, vaultID, url, env)
+		envVars, err := retrieveEnvironmentVariables(secretStore, configProfile, url, env)
 		if err != nil {
 			return err
 		}
 
 		switch {
-		case file != "":
-			return exportToFile(envVars, url, env, file)
+		case planOnly:
+			return planProfile(cmd, secretStore, envVars, url, configProfile, planOut)
+		// --file has a default, so a profile takes precedence
 		case profileName != "":
-			return syncToProfile(envVars, url, configProfile, force)
+			return syncToProfile(secretStore, envVars, url, configProfile, force)
+		case file != "":
+			return exportToFile(envVars, url, env, file, export.Format(format), kubernetesOpts)
 		default:
 			return fmt.Errorf("no file or profile specified")
 		}
 	},
 }
 
-func initializeOnePasswordService(cmd *cobra.Command, vaultTitle string) (*onepassword.Service, string, error) {
-	if vaultTitle == "" {
-		vaultTitle = onepassword.DefaultVault
-		logging.Logger.Debug("using default vault", "vault", vaultTitle)
-	}
-
-	logging.Logger.Debug("connecting to 1Password")
-	service, err := onepassword.NewService(cmd.Context(), opServiceAuthToken)
-	if err != nil {
-		return nil, "", fmt.Errorf("❌ failed to create 1Password service: %w", err)
-	}
-
-	logging.Logger.Debug("looking up vault", "vault", vaultTitle)
-	vault, err := service.GetVault(vaultTitle)
-	if err != nil {
-		return nil, "", fmt.Errorf("❌ failed to get vault: %w", err)
-	}
-
-	return service, vault.ID, nil
-}
-
-func retrieveEnvironmentVariables(service *onepassword.Service, configProfile *profile.Profile, vaultID, url, env string) (*onepassword.EnvironmentResult, error) {
-	logging.Logger.Debug("retrieving environment variables from 1Password", "vault", vaultID, "env", env)
-	envVars, err := service.GetEnvironment(onepassword.GetEnvironmentOptions{
-		URL:     onepassword.GetBaseName(url),
-		Env:     env,
-		VaultID: vaultID,
+func retrieveEnvironmentVariables(secretStore store.SecretStore, configProfile *profile.Profile, url, env string) (*store.Environment, error) {
+	logging.Logger.Debug("retrieving environment variables from secret store", "env", env)
+	envVars, err := secretStore.GetEnvironment(store.GetEnvironmentOptions{
+		URL: onepassword.GetBaseName(url),
+		Env: env,
 	})
 
 	if err != nil {
@@ -116,7 +123,7 @@ func retrieveEnvironmentVariables(service *onepassword.Service, configProfile *p
 	}
 
 	if configProfile != nil && slices.Contains(configProfile.Flags, profile.FlagPrefixWithEnv) {
-		envVars.Variables = prefixKeys(envVars, env)
+		prefixKeys(envVars, env)
 	}
 
 	if err := addOpenvKey(envVars); err != nil {
@@ -126,11 +133,13 @@ func retrieveEnvironmentVariables(service *onepassword.Service, configProfile *p
 	return envVars, nil
 }
 
-func addOpenvKey(envVars *onepassword.EnvironmentResult) error {
+func addOpenvKey(envVars *store.Environment) error {
 	keys := make([]string, 0, len(envVars.Variables))
 	for key := range envVars.Variables {
 		keys = append(keys, key)
 	}
+	// Sort the keys so the bookkeeping value only changes when the keys do
+	sort.Strings(keys)
 	keysJSON, err := json.Marshal(keys)
 	if err != nil {
 		return fmt.Errorf("failed to marshal keys: %w", err)
@@ -139,17 +148,27 @@ func addOpenvKey(envVars *onepassword.EnvironmentResult) error {
 	return nil
 }
 
-func prefixKeys(envVars *onepassword.EnvironmentResult, env string) map[string]string {
+func prefixKeys(envVars *store.Environment, env string) {
 	variables := make(map[string]string)
+	plain := []string{}
 	logging.Logger.Debug("prefixing environment variables with environment", "env", env)
 	for k, v := range envVars.Variables {
 		prefixed := fmt.Sprintf("%s_%s", strings.ToUpper(env), k)
 		variables[prefixed] = v
+		if !envVars.IsSensitive(k) {
+			plain = append(plain, prefixed)
+		}
 	}
-	return variables
+	envVars.Variables = variables
+	envVars.Plain = plain
 }
 
-func syncToProfile(envVars *onepassword.EnvironmentResult, url string, configProfile *profile.Profile, force bool) error {
+func syncToProfile(secretStore store.SecretStore, envVars *store.Environment, url string, configProfile *profile.Profile, force bool) error {
+	syncer, err := newSyncer(secretStore, url, envVars.Env, configProfile)
+	if err != nil {
+		return err
+	}
+
 	if !force {
 		confirmPrompt := promptui.Prompt{
 			Label:     fmt.Sprintf("Are you sure you want to sync to %s using profile %s", url, configProfile.Name),
@@ -161,72 +180,170 @@ func syncToProfile(envVars *onepassword.EnvironmentResult, url string, configPro
 		}
 	}
 
-	var err error
-	switch {
-	case slices.Contains(profile.ProfileSyncsGithub, configProfile.Sync):
-		var owner, repo string
-		owner, repo, err = github.DeriveLocationFromURL(url)
+	if err := syncer.Sync(envVars); err != nil {
+		return fmt.Errorf("failed to sync environment variables: %w", err)
+	}
+
+	logging.Logger.Info("Environment variables synced successfully")
+	return nil
+}
+
+// circleciTarget returns the project or organization slug of a CircleCI sync and its target in the owned keys.
+// The slug is read from the profile URL, defaulting to url.
+func circleciTarget(url, env string, configProfile *profile.Profile) (string, string, error) {
+	if configProfile.Sync == profile.CircleCIContext {
+		orgSlug, err := circleci.Slug(profileLocation(url, configProfile), 1)
 		if err != nil {
-			return err
+			return "", "", err
 		}
-		githubService := github.NewGitHubService(configProfile.Token)
-
-		switch configProfile.Sync {
-		case profile.GithubEnvironmentSecret:
-			err = githubService.SyncToRepoEnvironmentSecret(owner, repo, envVars.Env, envVars.Variables, true)
-		case profile.GithubEnvironmentVariable:
-			err = githubService.SyncToRepoEnvironmentVariable(owner, repo, envVars.Env, envVars.Variables, true)
-		case profile.GithubRepoSecret:
-			err = githubService.SyncToRepoSecret(owner, repo, envVars.Variables, true)
-		case profile.GithubRepoVariable:
-			err = githubService.SyncToRepoVariable(owner, repo, envVars.Variables, true)
-		case profile.GithubOrgSecret:
-			err = githubService.SyncToOrgSecret(owner, envVars.Variables, true)
-		case profile.GithubOrgVariable:
-			err = githubService.SyncToOrgVariable(owner, envVars.Variables, true)
-		default:
-			err = fmt.Errorf("sync not implemented for %s", configProfile.Sync)
+		return orgSlug, ownedTarget(configProfile.Sync, orgSlug+"/"+env), nil
+	}
+	projectSlug, err := circleci.Slug(profileLocation(url, configProfile), 2)
+	if err != nil {
+		return "", "", err
+	}
+	return projectSlug, ownedTarget(configProfile.Sync, projectSlug), nil
+}
+
+// workerScript returns the script name Wrangler deploys an environment of a Worker to
+func workerScript(script, env string) string {
+	return script + "-" + env
+}
+
+// netlifyScopes returns the Netlify scopes of new variables for a Netlify sync type
+func netlifyScopes(syncType profile.SyncType) []netlify.Scope {
+	switch syncType {
+	case profile.NetlifyDeployContextScopeBuild:
+		return []netlify.Scope{netlify.ScopeBuilds}
+	case profile.NetlifyDeployContextScopeFunctions:
+		return []netlify.Scope{netlify.ScopeFunctions}
+	case profile.NetlifyDeployContextScopeRuntime:
+		return []netlify.Scope{netlify.ScopeRuntime}
+	case profile.NetlifyDeployContextScopePostProcessing:
+		return []netlify.Scope{netlify.ScopePostProcessing}
+	default:
+		return netlify.AllScopes
+	}
+}
+
+// vercelEnvironment returns the Vercel environment and git branch for a Vercel sync type.
+// Preview variables are limited to the branch named like env, unless env is preview itself.
+// Custom environments are named like env.
+func vercelEnvironment(syncType profile.SyncType, env string) (string, string) {
+	switch syncType {
+	case profile.VercelEnvironmentProduction:
+		return vercel.EnvironmentProduction, ""
+	case profile.VercelEnvironmentDevelopment:
+		return vercel.EnvironmentDevelopment, ""
+	case profile.VercelEnvironmentPreview:
+		if env == vercel.EnvironmentPreview {
+			return vercel.EnvironmentPreview, ""
 		}
+		return vercel.EnvironmentPreview, env
 	default:
-		// TODO: Implement missing syncs
-		err = fmt.Errorf("sync not implemented for %s", configProfile.Sync)
+		return env, ""
+	}
+}
+
+// profileLocation returns the location of a self-hostable target, e.g. gitlab.example.com/group/project.
+// The profile URL defaults to the url of the environment.
+func profileLocation(url string, configProfile *profile.Profile) string {
+	if configProfile.URL != "" {
+		return configProfile.URL
 	}
+	return url
+}
 
+// awsSecretName returns <prefix>/<env>, the SSM parameter path without the leading slash or the Secrets Manager secret name.
+// The prefix is the profile URL, defaulting to the repository name of url.
+func awsSecretName(url, env string, configProfile *profile.Profile) string {
+	prefix := strings.Trim(configProfile.URL, "/")
+	if prefix == "" {
+		prefix = path.Base(onepassword.GetBaseName(url))
+	}
+	return prefix + "/" + env
+}
+
+// kubernetesTarget connects to the cluster of the kubeconfig context in the profile URL, [<context>/]<namespace>.
+// The namespace defaults to the one of the context, the Secret is named like the exported manifests.
+func kubernetesTarget(url, env string, configProfile *profile.Profile) (*kubernetes.KubernetesService, string, string, error) {
+	kubeconfig, err := kubernetes.KubeconfigPath()
 	if err != nil {
-		return fmt.Errorf("failed to sync environment variables: %w", err)
+		return nil, "", "", err
+	}
+	contextName, namespace := kubernetes.ParseLocation(configProfile.URL)
+	cluster, err := kubernetes.LoadCluster(kubeconfig, contextName)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if namespace == "" {
+		namespace = cluster.Namespace
+	}
+	if namespace == "" {
+		namespace = "default"
 	}
+	name := export.KubernetesName(path.Base(onepassword.GetBaseName(url)), env)
+	return kubernetes.NewKubernetesService(cluster), namespace, name, nil
+}
 
-	logging.Logger.Info("Environment variables synced successfully")
+// planProfile prints the changes a sync to the profile would apply without changing anything.
+// If planOut is set, the plan is also written to that file as JSON.
+func planProfile(cmd *cobra.Command, secretStore store.SecretStore, envVars *store.Environment, url string, configProfile *profile.Profile, planOut string) error {
+	syncer, err := newSyncer(secretStore, url, envVars.Env, configProfile)
+	if err != nil {
+		return err
+	}
+	syncPlan, err := syncer.Plan(envVars)
+	if err != nil {
+		return fmt.Errorf("failed to plan sync: %w", err)
+	}
+
+	if err := syncPlan.Write(cmd.OutOrStdout()); err != nil {
+		return fmt.Errorf("failed to print plan: %w", err)
+	}
+
+	if planOut != "" {
+		content, err := json.MarshalIndent(syncPlan, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal plan: %w", err)
+		}
+		if err := os.WriteFile(planOut, append(content, '\n'), 0600); err != nil {
+			return fmt.Errorf("❌ failed to write plan file: %w", err)
+		}
+		logging.Logger.Debug("plan written", "file", planOut)
+	}
 	return nil
 }
 
-func exportToFile(envVars *onepassword.EnvironmentResult, url, env string, file string) error {
+func exportToFile(envVars *store.Environment, url, env string, file string, format export.Format, kubernetesOpts export.KubernetesOptions) error {
 	logging.Logger.Debug("starting export",
 		"url", url,
 		"env", env,
-		"file", file)
+		"file", file,
+		"format", format)
 
-	var keys []string
-	for k := range envVars.Variables {
-		keys = append(keys, k)
+	if kubernetesOpts.Name == "" {
+		kubernetesOpts.Name = export.KubernetesName(path.Base(onepassword.GetBaseName(url)), env)
 	}
-	sort.Strings(keys)
 
-	var content strings.Builder
-	content.WriteString(fmt.Sprintf("# Environment variables for %s (%s)\n", url, env))
-	content.WriteString(fmt.Sprintf("# Generated by openv %s\n\n", version.Info()))
-	for _, key := range keys {
-		value := envVars.Variables[key]
-		if strings.Contains(value, " ") || strings.Contains(value, "#") {
-			value = strings.ReplaceAll(value, `\`, `\\`)
-			value = strings.ReplaceAll(value, `"`, `\"`)
-			value = fmt.Sprintf(`"%s"`, value)
-		}
-		content.WriteString(fmt.Sprintf("%s=%s\n", key, value))
+	// OPENV_KEYS only tracks the keys synced to services, exported files are replaced as a whole
+	variables := maps.Clone(envVars.Variables)
+	delete(variables, internal.OPENV_KEYS)
+
+	content, err := export.Render(format, variables, export.Options{
+		Header: []string{
+			fmt.Sprintf("Environment variables for %s (%s)", url, env),
+			fmt.Sprintf("Generated by openv %s", version.Info()),
+		},
+		Plain:      envVars.Plain,
+		Kubernetes: kubernetesOpts,
+	})
+	if err != nil {
+		return fmt.Errorf("❌ failed to render environment file: %w", err)
 	}
 
-	logging.Logger.Debug("writing environment variables to file", "file", file, "count", len(envVars.Variables))
-	if err := os.WriteFile(file, []byte(content.String()), 0600); err != nil {
+	logging.Logger.Debug("writing environment variables to file", "file", file, "count", len(variables))
+	if err := os.WriteFile(file, content, 0600); err != nil {
 		return fmt.Errorf("❌ failed to write environment file: %w", err)
 	}
 
@@ -240,7 +357,13 @@ func init() {
 	pushCmd.Flags().String("url", "", "Service URL")
 	pushCmd.Flags().String("env", "", "Environment (e.g., production, staging)")
 	pushCmd.Flags().StringP("file", "f", ".env", "Path to the output environment file")
+	pushCmd.Flags().String("format", string(export.FormatDotenv), fmt.Sprintf("Output file format (%s)", strings.Join(export.FormatsToStrings(), ", ")))
+	pushCmd.Flags().String("k8s-name", "", "Name of the Kubernetes Secret/ConfigMap (default is <repo>-<env>)")
+	pushCmd.Flags().String("k8s-namespace", "", "Namespace of the Kubernetes Secret/ConfigMap")
+	pushCmd.Flags().StringToString("k8s-labels", nil, "Labels of the Kubernetes Secret/ConfigMap (e.g. app=web,tier=backend)")
 	pushCmd.Flags().BoolP("force", "y", false, "Do not prompt for confirmation")
+	pushCmd.Flags().Bool("plan", false, "Show the changes a sync would apply without applying them")
+	pushCmd.Flags().String("plan-out", "", "Write the plan as JSON to this file (implies --plan)")
 	pushCmd.Flags().String("vault", onepassword.DefaultVault, "1Password vault to use")
 
 	cobra.CheckErr(pushCmd.MarkFlagRequired("url"))
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/export"
+	"github.com/hinterland-software/openv/internal/store"
+)
+
+func TestExportToFileOmitsOpenvKeys(t *testing.T) {
+	secretStore := store.NewMemoryStore()
+	if _, err := secretStore.Put(store.PutOptions{
+		Name:      "app",
+		Env:       "production",
+		URL:       "github.com/org/app",
+		Variables: map[string]string{"API_KEY": "secret", "LOG_LEVEL": "info"},
+		Plain:     []string{"LOG_LEVEL"},
+	}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	envVars, err := retrieveEnvironmentVariables(secretStore, nil, "github.com/org/app", "production")
+	if err != nil {
+		t.Fatalf("retrieveEnvironmentVariables() error = %v", err)
+	}
+	if envVars.Variables[internal.OPENV_KEYS] == "" {
+		t.Fatalf("retrieveEnvironmentVariables() did not add %s for syncs", internal.OPENV_KEYS)
+	}
+
+	for _, format := range export.Formats {
+		file := filepath.Join(t.TempDir(), "out")
+		if err := exportToFile(envVars, "github.com/org/app", "production", file, format, export.KubernetesOptions{}); err != nil {
+			t.Fatalf("exportToFile(%s) error = %v", format, err)
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(content), internal.OPENV_KEYS) {
+			t.Errorf("exportToFile(%s) wrote %s:\n%s", format, internal.OPENV_KEYS, content)
+		}
+		if !strings.Contains(string(content), "LOG_LEVEL") {
+			t.Errorf("exportToFile(%s) is missing LOG_LEVEL:\n%s", format, content)
+		}
+	}
+}
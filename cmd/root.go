@@ -7,8 +7,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/hinterland-software/openv/internal/filestore"
 	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/store"
 	"github.com/hinterland-software/openv/internal/version"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -16,6 +19,11 @@ import (
 
 var cfgFile string
 var opServiceAuthToken string
+var (
+	storeBackend string
+	storeFile    string
+	identityFile string
+)
 var (
 	verboseFlag bool
 	quietFlag   bool
@@ -51,6 +59,12 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", fmt.Sprintf("config file (default is $HOME/.%s.yaml)", rootCmd.Use))
 	rootCmd.PersistentFlags().StringVarP(&opServiceAuthToken, "op-token", "", "", "The 1Password service account token for authentication")
 	cobra.CheckErr(viper.BindPFlag("op-token", rootCmd.PersistentFlags().Lookup("op-token")))
+	rootCmd.PersistentFlags().StringVar(&storeBackend, "store", string(store.Backend1Password), fmt.Sprintf("Secret store backend (%s)", strings.Join(store.BackendsToStrings(), ", ")))
+	cobra.CheckErr(viper.BindPFlag("store", rootCmd.PersistentFlags().Lookup("store")))
+	rootCmd.PersistentFlags().StringVar(&storeFile, "store-file", filestore.DefaultPath, "Encrypted environment file used by the file store")
+	cobra.CheckErr(viper.BindPFlag("store-file", rootCmd.PersistentFlags().Lookup("store-file")))
+	rootCmd.PersistentFlags().StringVar(&identityFile, "identity-file", "", fmt.Sprintf("Identity used to decrypt the file store (default is $HOME/%s)", defaultIdentityFile))
+	cobra.CheckErr(viper.BindPFlag("identity-file", rootCmd.PersistentFlags().Lookup("identity-file")))
 	viper.SetDefault("version", version.Info())
 
 	rootCmd.PersistentFlags().BoolVarP(&verboseFlag, "verbose", "v", false, "Enable verbose output")
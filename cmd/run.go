@@ -6,26 +6,21 @@ import (
 	"os/exec"
 
 	onepassword "github.com/hinterland-software/openv/internal/1password"
-	"github.com/hinterland-software/openv/internal/cli"
 	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/store"
 	"github.com/spf13/cobra"
 )
 
 // runCmd represents the run command
 var runCmd = &cobra.Command{
 	Use:   "run [command]",
-	Short: "Run a command with environment variables from 1Password",
-	Long: `Execute a specified command with environment variables sourced from 1Password. 
+	Short: "Run a command with environment variables from the secret store",
+	Long: `Execute a specified command with environment variables sourced from the secret store (1Password by default). 
 This allows for secure and seamless integration of environment variables into your workflow.
 Example usage:
-  openv run --url github.com/org/repo --env production -- npm start`,
+  openv run --url github.com/org/repo --env production -- npm start
+  openv run --url github.com/org/repo --env production --expand -- npm start`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var err error
-		opServiceAuthToken, err = cli.GetToken(cmd)
-		if err != nil {
-			return fmt.Errorf("❌ failed to get token: %w", err)
-		}
-
 		url, err := cmd.Flags().GetString("url")
 		if err != nil {
 			return fmt.Errorf("❌ failed to get url flag: %w", err)
@@ -36,6 +31,7 @@ Example usage:
 		}
 		command, _ := cmd.Flags().GetString("command")
 		vaultTitle, _ := cmd.Flags().GetString("vault")
+		expand, _ := cmd.Flags().GetBool("expand")
 
 		if command == "" && len(args) == 0 {
 			return fmt.Errorf("❌ no command specified. Use -- <command> or --command '<command>'")
@@ -46,37 +42,33 @@ Example usage:
 			"env", env,
 			"command", command,
 			"args", args,
-			"vault", vaultTitle)
-
-		if vaultTitle == "" {
-			vaultTitle = onepassword.DefaultVault
-			logging.Logger.Debug("using default vault", "vault", vaultTitle)
-		}
+			"vault", vaultTitle,
+			"expand", expand)
 
-		logging.Logger.Debug("connecting to 1Password")
-		service, err := onepassword.NewService(cmd.Context(), opServiceAuthToken)
+		secretStore, err := initializeSecretStore(cmd)
 		if err != nil {
-			return fmt.Errorf("❌ failed to create 1Password service: %w", err)
-		}
-
-		logging.Logger.Debug("looking up vault", "vault", vaultTitle)
-		vault, err := service.GetVault(vaultTitle)
-		if err != nil {
-			return fmt.Errorf("❌ failed to get vault: %w", err)
+			return err
 		}
 
 		logging.Logger.Debug("fetching environment variables",
 			"url", onepassword.GetBaseName(url),
 			"env", env)
-		envVars, err := service.GetEnvironment(onepassword.GetEnvironmentOptions{
-			URL:     onepassword.GetBaseName(url),
-			Env:     env,
-			VaultID: vault.ID,
+		envVars, err := secretStore.GetEnvironment(store.GetEnvironmentOptions{
+			URL: onepassword.GetBaseName(url),
+			Env: env,
 		})
 		if err != nil {
 			return fmt.Errorf("❌ failed to get environment variables: %w", err)
 		}
 
+		if expand {
+			logging.Logger.Debug("expanding environment variables")
+			envVars.Variables, err = expandEnvironment(secretStore, envVars)
+			if err != nil {
+				return fmt.Errorf("❌ failed to expand environment variables: %w", err)
+			}
+		}
+
 		// Prepare command to run
 		var cmdToRun *exec.Cmd
 		if command != "" {
@@ -118,6 +110,7 @@ func init() {
 	runCmd.Flags().String("env", "", "Environment (e.g., production, staging)")
 	runCmd.Flags().String("vault", onepassword.DefaultVault, "1Password vault to use")
 	runCmd.Flags().String("command", "", "Command to run (alternative to using --)")
+	runCmd.Flags().Bool("expand", false, "Expand ${VAR} references and resolve op:// secret references before running, unless already done on import")
 
 	cobra.CheckErr(runCmd.MarkFlagRequired("url"))
 	cobra.CheckErr(runCmd.MarkFlagRequired("env"))
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	onepassword "github.com/hinterland-software/openv/internal/1password"
+	"github.com/hinterland-software/openv/internal/cli"
+	"github.com/hinterland-software/openv/internal/filestore"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/store"
+	"github.com/hinterland-software/openv/internal/vault"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// defaultIdentityFile is the identity file name in the home directory
+const defaultIdentityFile = ".openv.key"
+
+// initializeSecretStore creates the secret store backend selected via the --store flag or config
+func initializeSecretStore(cmd *cobra.Command) (store.SecretStore, error) {
+	backend := store.Backend(viper.GetString("store"))
+	if backend == "" {
+		backend = store.Backend1Password
+	}
+	if err := store.ValidateBackend(backend); err != nil {
+		return nil, fmt.Errorf("❌ %w", err)
+	}
+	logging.Logger.Debug("initializing secret store", "backend", backend)
+
+	switch backend {
+	case store.BackendFile:
+		return initializeFileStore()
+	case store.BackendVault:
+		return initializeVaultStore(cmd)
+	default:
+		return initializeOnePasswordStore(cmd)
+	}
+}
+
+func initializeOnePasswordStore(cmd *cobra.Command) (*onepassword.Store, error) {
+	var err error
+	opServiceAuthToken, err = cli.GetToken(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to get token: %w", err)
+	}
+
+	vaultTitle := onepassword.DefaultVault
+	if flag := cmd.Flags().Lookup("vault"); flag != nil && flag.Value.String() != "" {
+		vaultTitle = flag.Value.String()
+	} else {
+		logging.Logger.Debug("using default vault", "vault", vaultTitle)
+	}
+
+	logging.Logger.Debug("connecting to 1Password", "vault", vaultTitle)
+	opStore, err := onepassword.NewStore(cmd.Context(), opServiceAuthToken, vaultTitle)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to create 1Password store: %w", err)
+	}
+	return opStore, nil
+}
+
+// initializeVaultStore connects to HashiCorp Vault using the hashicorp_vault config section,
+// falling back to the environment variables used by the vault CLI
+func initializeVaultStore(cmd *cobra.Command) (*vault.Store, error) {
+	opts := vault.Options{
+		Address:   configOrEnv("hashicorp_vault.address", "VAULT_ADDR"),
+		Token:     configOrEnv("hashicorp_vault.token", "VAULT_TOKEN"),
+		Namespace: configOrEnv("hashicorp_vault.namespace", "VAULT_NAMESPACE"),
+		Mount:     viper.GetString("hashicorp_vault.mount"),
+		Prefix:    viper.GetString("hashicorp_vault.prefix"),
+	}
+	logging.Logger.Debug("connecting to Vault", "address", opts.Address, "mount", opts.Mount, "prefix", opts.Prefix)
+
+	vaultStore, err := vault.NewStore(cmd.Context(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to create Vault store: %w", err)
+	}
+	return vaultStore, nil
+}
+
+func configOrEnv(key, env string) string {
+	if value := viper.GetString(key); value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
+
+func initializeFileStore() (*filestore.Store, error) {
+	identity, err := loadIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	path := viper.GetString("store-file")
+	logging.Logger.Debug("using encrypted file", "file", path, "recipient", identity.Recipient())
+	return filestore.NewStore(path, identity), nil
+}
+
+// loadIdentity reads the identity used to decrypt the encrypted file.
+// The OPENV_IDENTITY environment variable takes precedence over the identity file.
+func loadIdentity() (*filestore.Identity, error) {
+	if key := viper.GetString("identity"); key != "" {
+		identity, err := filestore.ParseIdentity(key)
+		if err != nil {
+			return nil, fmt.Errorf("❌ failed to parse identity: %w", err)
+		}
+		return identity, nil
+	}
+
+	path, err := identityFilePath()
+	if err != nil {
+		return nil, err
+	}
+	identity, err := filestore.ReadIdentityFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to load identity (run 'openv keys generate' to create one): %w", err)
+	}
+	return identity, nil
+}
+
+func identityFilePath() (string, error) {
+	if path := viper.GetString("identity-file"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("❌ failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, defaultIdentityFile), nil
+}
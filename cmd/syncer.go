@@ -0,0 +1,582 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/hinterland-software/openv/internal/aws"
+	"github.com/hinterland-software/openv/internal/azure"
+	"github.com/hinterland-software/openv/internal/bitbucket"
+	"github.com/hinterland-software/openv/internal/circleci"
+	"github.com/hinterland-software/openv/internal/cloudflare"
+	"github.com/hinterland-software/openv/internal/deno"
+	"github.com/hinterland-software/openv/internal/fly"
+	"github.com/hinterland-software/openv/internal/gitea"
+	"github.com/hinterland-software/openv/internal/github"
+	"github.com/hinterland-software/openv/internal/gitlab"
+	"github.com/hinterland-software/openv/internal/heroku"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/netlify"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/profile"
+	"github.com/hinterland-software/openv/internal/railway"
+	"github.com/hinterland-software/openv/internal/render"
+	"github.com/hinterland-software/openv/internal/shopify"
+	"github.com/hinterland-software/openv/internal/store"
+	"github.com/hinterland-software/openv/internal/vercel"
+)
+
+// Syncer syncs an environment to the target of a sync profile
+type Syncer interface {
+	// Sync applies the environment to the target, deleting the keys openv synced before that were removed
+	Sync(envVars *store.Environment) error
+	// Plan computes the changes Sync would apply without changing anything
+	Plan(envVars *store.Environment) (*plan.Plan, error)
+}
+
+// syncFuncs is a Syncer built from the sync and plan calls of one target,
+// defining both together keeps them from drifting apart
+type syncFuncs struct {
+	sync func(envVars *store.Environment) error
+	plan func(envVars *store.Environment) (*plan.Plan, error)
+}
+
+func (s syncFuncs) Sync(envVars *store.Environment) error {
+	return s.sync(envVars)
+}
+
+func (s syncFuncs) Plan(envVars *store.Environment) (*plan.Plan, error) {
+	return s.plan(envVars)
+}
+
+// newSyncer returns the Syncer for the target of a sync profile.
+// The target is resolved from the profile, the url and the environment name env.
+func newSyncer(secretStore store.SecretStore, url, env string, configProfile *profile.Profile) (Syncer, error) {
+	switch {
+	case slices.Contains(profile.ProfileSyncsGithub, configProfile.Sync):
+		return githubSyncer(url, configProfile)
+	case slices.Contains(profile.ProfileSyncsNetlify, configProfile.Sync):
+		return netlifySyncer(configProfile), nil
+	case slices.Contains(profile.ProfileSyncsVercel, configProfile.Sync):
+		return vercelSyncer(env, configProfile), nil
+	case slices.Contains(profile.ProfileSyncsDeno, configProfile.Sync):
+		return denoSyncer(secretStore, configProfile), nil
+	case slices.Contains(profile.ProfileSyncsShopify, configProfile.Sync):
+		return shopifySyncer(configProfile)
+	case slices.Contains(profile.ProfileSyncsGitlab, configProfile.Sync):
+		return gitlabSyncer(url, configProfile)
+	case slices.Contains(profile.ProfileSyncsBitbucket, configProfile.Sync):
+		return bitbucketSyncer(url, configProfile)
+	case slices.Contains(profile.ProfileSyncsGitea, configProfile.Sync):
+		return giteaSyncer(url, configProfile)
+	case slices.Contains(profile.ProfileSyncsAws, configProfile.Sync):
+		return awsSyncer(url, env, configProfile)
+	case slices.Contains(profile.ProfileSyncsKubernetes, configProfile.Sync):
+		return kubernetesSyncer(url, env, configProfile)
+	case slices.Contains(profile.ProfileSyncsFly, configProfile.Sync):
+		return flySyncer(secretStore, configProfile), nil
+	case slices.Contains(profile.ProfileSyncsRender, configProfile.Sync):
+		return renderSyncer(configProfile)
+	case slices.Contains(profile.ProfileSyncsRailway, configProfile.Sync):
+		return railwaySyncer(configProfile), nil
+	case slices.Contains(profile.ProfileSyncsCloudflare, configProfile.Sync):
+		return cloudflareSyncer(env, configProfile)
+	case slices.Contains(profile.ProfileSyncsHeroku, configProfile.Sync):
+		return herokuSyncer(configProfile), nil
+	case slices.Contains(profile.ProfileSyncsCircleCI, configProfile.Sync):
+		return circleciSyncer(secretStore, url, env, configProfile)
+	case slices.Contains(profile.ProfileSyncsAzure, configProfile.Sync):
+		return azureSyncer(env, configProfile)
+	default:
+		return nil, notImplemented(configProfile)
+	}
+}
+
+func notImplemented(configProfile *profile.Profile) error {
+	return fmt.Errorf("sync not implemented for %s", configProfile.Sync)
+}
+
+func githubSyncer(url string, configProfile *profile.Profile) (Syncer, error) {
+	owner, repo, err := github.DeriveLocationFromURL(url)
+	if err != nil {
+		return nil, err
+	}
+	githubService := github.NewGitHubService(configProfile.Token)
+
+	switch configProfile.Sync {
+	case profile.GithubEnvironmentSecret:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return githubService.SyncToRepoEnvironmentSecret(owner, repo, envVars.Env, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return githubService.PlanRepoEnvironmentSecret(owner, repo, envVars.Env, envVars.Variables)
+			},
+		}, nil
+	case profile.GithubEnvironmentVariable:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return githubService.SyncToRepoEnvironmentVariable(owner, repo, envVars.Env, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return githubService.PlanRepoEnvironmentVariable(owner, repo, envVars.Env, envVars.Variables)
+			},
+		}, nil
+	case profile.GithubRepoSecret:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return githubService.SyncToRepoSecret(owner, repo, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return githubService.PlanRepoSecret(owner, repo, envVars.Variables)
+			},
+		}, nil
+	case profile.GithubRepoVariable:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return githubService.SyncToRepoVariable(owner, repo, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return githubService.PlanRepoVariable(owner, repo, envVars.Variables)
+			},
+		}, nil
+	case profile.GithubOrgSecret:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return githubService.SyncToOrgSecret(owner, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return githubService.PlanOrgSecret(owner, envVars.Variables)
+			},
+		}, nil
+	case profile.GithubOrgVariable:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return githubService.SyncToOrgVariable(owner, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return githubService.PlanOrgVariable(owner, envVars.Variables)
+			},
+		}, nil
+	default:
+		return nil, notImplemented(configProfile)
+	}
+}
+
+func netlifySyncer(configProfile *profile.Profile) Syncer {
+	netlifyService := netlify.NewNetlifyService(configProfile.Token, "")
+	return syncFuncs{
+		sync: func(envVars *store.Environment) error {
+			return netlifyService.SyncToDeployContext(configProfile.URL, envVars.Env, netlifyScopes(configProfile.Sync), envVars.Variables, true)
+		},
+		plan: func(envVars *store.Environment) (*plan.Plan, error) {
+			return netlifyService.PlanDeployContext(configProfile.URL, envVars.Env, netlifyScopes(configProfile.Sync), envVars.Variables)
+		},
+	}
+}
+
+func vercelSyncer(env string, configProfile *profile.Profile) Syncer {
+	vercelService := vercel.NewVercelService(configProfile.Token, "")
+	environment, gitBranch := vercelEnvironment(configProfile.Sync, env)
+	return syncFuncs{
+		sync: func(envVars *store.Environment) error {
+			return vercelService.SyncToEnvironment(configProfile.URL, environment, gitBranch, envVars.Variables, envVars.Plain, true)
+		},
+		plan: func(envVars *store.Environment) (*plan.Plan, error) {
+			return vercelService.PlanEnvironment(configProfile.URL, environment, gitBranch, envVars.Variables)
+		},
+	}
+}
+
+// denoSyncer syncs to a Deno Deploy project, which cannot read back variables,
+// so the owned keys are recorded with the environment in the secret store
+func denoSyncer(secretStore store.SecretStore, configProfile *profile.Profile) Syncer {
+	denoService := deno.NewDenoService(configProfile.Token, "", "")
+	target := ownedTarget(configProfile.Sync, configProfile.URL)
+	return syncFuncs{
+		sync: func(envVars *store.Environment) error {
+			if err := denoService.SyncToProject(configProfile.URL, envVars.Variables, envVars.Owned[target], true); err != nil {
+				return err
+			}
+			if err := recordOwned(secretStore, envVars, target); err != nil {
+				return err
+			}
+
+			if !slices.Contains(configProfile.Flags, profile.FlagRedeploy) {
+				logging.Logger.Info("Deno Deploy applies environment variables to new deployments, redeploy the project or add the redeploy flag to the profile", "project", configProfile.URL)
+				return nil
+			}
+			return denoService.Redeploy(configProfile.URL)
+		},
+		plan: func(envVars *store.Environment) (*plan.Plan, error) {
+			return denoService.PlanProject(configProfile.URL, envVars.Variables, envVars.Owned[target]), nil
+		},
+	}
+}
+
+func shopifySyncer(configProfile *profile.Profile) (Syncer, error) {
+	shop, storefront, err := shopify.DeriveLocationFromURL(configProfile.URL)
+	if err != nil {
+		return nil, err
+	}
+	shopifyService := shopify.NewShopifyService(configProfile.Token, shop, "")
+	return syncFuncs{
+		sync: func(envVars *store.Environment) error {
+			return shopifyService.SyncToEnvironment(storefront, envVars.Env, envVars.Variables, envVars.Plain, true)
+		},
+		plan: func(envVars *store.Environment) (*plan.Plan, error) {
+			return shopifyService.PlanEnvironment(storefront, envVars.Env, envVars.Variables)
+		},
+	}, nil
+}
+
+func gitlabSyncer(url string, configProfile *profile.Profile) (Syncer, error) {
+	baseURL, location, err := gitlab.DeriveLocationFromURL(profileLocation(url, configProfile))
+	if err != nil {
+		return nil, err
+	}
+	gitlabService := gitlab.NewGitLabService(configProfile.Token, baseURL)
+	protected := slices.Contains(configProfile.Flags, profile.FlagProtected)
+
+	switch configProfile.Sync {
+	case profile.GitlabProjectVariable:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return gitlabService.SyncToProjectVariable(location, gitlab.EnvironmentScope(envVars.Env), envVars.Variables, envVars.Plain, protected, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return gitlabService.PlanProjectVariable(location, gitlab.EnvironmentScope(envVars.Env), envVars.Variables)
+			},
+		}, nil
+	case profile.GitlabGroupVariable:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return gitlabService.SyncToGroupVariable(location, gitlab.EnvironmentScope(envVars.Env), envVars.Variables, envVars.Plain, protected, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return gitlabService.PlanGroupVariable(location, gitlab.EnvironmentScope(envVars.Env), envVars.Variables)
+			},
+		}, nil
+	case profile.GitlabInstanceVariable:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return gitlabService.SyncToInstanceVariable(envVars.Variables, envVars.Plain, protected, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return gitlabService.PlanInstanceVariable(envVars.Variables)
+			},
+		}, nil
+	default:
+		return nil, notImplemented(configProfile)
+	}
+}
+
+func bitbucketSyncer(url string, configProfile *profile.Profile) (Syncer, error) {
+	workspace, repo, err := bitbucket.DeriveLocationFromURL(url)
+	if err != nil {
+		return nil, err
+	}
+	bitbucketService := bitbucket.NewBitbucketService(configProfile.Token, "")
+
+	switch configProfile.Sync {
+	case profile.BitbucketRepoVariable:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return bitbucketService.SyncToRepoVariable(workspace, repo, envVars.Variables, envVars.Plain, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return bitbucketService.PlanRepoVariable(workspace, repo, envVars.Variables)
+			},
+		}, nil
+	case profile.BitbucketDeploymentVariable:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return bitbucketService.SyncToDeploymentVariable(workspace, repo, envVars.Env, envVars.Variables, envVars.Plain, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return bitbucketService.PlanDeploymentVariable(workspace, repo, envVars.Env, envVars.Variables)
+			},
+		}, nil
+	default:
+		return nil, notImplemented(configProfile)
+	}
+}
+
+func giteaSyncer(url string, configProfile *profile.Profile) (Syncer, error) {
+	baseURL, owner, repo, err := gitea.DeriveLocationFromURL(profileLocation(url, configProfile))
+	if err != nil {
+		return nil, err
+	}
+	giteaService := gitea.NewGiteaService(configProfile.Token, baseURL)
+
+	switch configProfile.Sync {
+	case profile.GiteaRepoSecret:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return giteaService.SyncToRepoSecret(owner, repo, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return giteaService.PlanRepoSecret(owner, repo, envVars.Variables)
+			},
+		}, nil
+	case profile.GiteaRepoVariable:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return giteaService.SyncToRepoVariable(owner, repo, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return giteaService.PlanRepoVariable(owner, repo, envVars.Variables)
+			},
+		}, nil
+	case profile.GiteaOrgSecret:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return giteaService.SyncToOrgSecret(owner, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return giteaService.PlanOrgSecret(owner, envVars.Variables)
+			},
+		}, nil
+	case profile.GiteaOrgVariable:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return giteaService.SyncToOrgVariable(owner, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return giteaService.PlanOrgVariable(owner, envVars.Variables)
+			},
+		}, nil
+	default:
+		return nil, notImplemented(configProfile)
+	}
+}
+
+func awsSyncer(url, env string, configProfile *profile.Profile) (Syncer, error) {
+	awsConfig, err := aws.LoadConfig(configProfile.Token)
+	if err != nil {
+		return nil, err
+	}
+	name := awsSecretName(url, env, configProfile)
+
+	switch configProfile.Sync {
+	case profile.AwsSsmParameter:
+		ssmService := aws.NewSSMService(awsConfig, "")
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return ssmService.SyncToPath("/"+name, envVars.Variables, envVars.Plain, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return ssmService.PlanPath("/"+name, envVars.Variables)
+			},
+		}, nil
+	case profile.AwsSecretsManagerSecret:
+		secretsManagerService := aws.NewSecretsManagerService(awsConfig, "")
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return secretsManagerService.SyncToSecret(name, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return secretsManagerService.PlanSecret(name, envVars.Variables)
+			},
+		}, nil
+	default:
+		return nil, notImplemented(configProfile)
+	}
+}
+
+func kubernetesSyncer(url, env string, configProfile *profile.Profile) (Syncer, error) {
+	kubernetesService, namespace, name, err := kubernetesTarget(url, env, configProfile)
+	if err != nil {
+		return nil, err
+	}
+	return syncFuncs{
+		sync: func(envVars *store.Environment) error {
+			return kubernetesService.SyncToSecret(namespace, name, envVars.Variables)
+		},
+		plan: func(envVars *store.Environment) (*plan.Plan, error) {
+			return kubernetesService.PlanSecret(namespace, name, envVars.Variables)
+		},
+	}, nil
+}
+
+// flySyncer syncs to Fly.io app secrets, which cannot be read back,
+// so the owned keys are recorded with the environment in the secret store
+func flySyncer(secretStore store.SecretStore, configProfile *profile.Profile) Syncer {
+	flyService := fly.NewFlyService(configProfile.Token, "")
+	target := ownedTarget(configProfile.Sync, configProfile.URL)
+	return syncFuncs{
+		sync: func(envVars *store.Environment) error {
+			if err := flyService.SyncToApp(configProfile.URL, envVars.Variables, envVars.Owned[target], true); err != nil {
+				return err
+			}
+			return recordOwned(secretStore, envVars, target)
+		},
+		plan: func(envVars *store.Environment) (*plan.Plan, error) {
+			return flyService.PlanApp(configProfile.URL, envVars.Variables, envVars.Owned[target])
+		},
+	}
+}
+
+func renderSyncer(configProfile *profile.Profile) (Syncer, error) {
+	renderService := render.NewRenderService(configProfile.Token, "")
+
+	switch configProfile.Sync {
+	case profile.RenderEnvGroup:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return renderService.SyncToEnvGroup(configProfile.URL, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return renderService.PlanEnvGroup(configProfile.URL, envVars.Variables)
+			},
+		}, nil
+	case profile.RenderService:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return renderService.SyncToService(configProfile.URL, envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return renderService.PlanService(configProfile.URL, envVars.Variables)
+			},
+		}, nil
+	default:
+		return nil, notImplemented(configProfile)
+	}
+}
+
+func railwaySyncer(configProfile *profile.Profile) Syncer {
+	railwayService := railway.NewRailwayService(configProfile.Token, "")
+	return syncFuncs{
+		sync: func(envVars *store.Environment) error {
+			return railwayService.SyncToServiceVariable(configProfile.URL, envVars.Env, envVars.Variables, true)
+		},
+		plan: func(envVars *store.Environment) (*plan.Plan, error) {
+			return railwayService.PlanServiceVariable(configProfile.URL, envVars.Env, envVars.Variables)
+		},
+	}
+}
+
+func cloudflareSyncer(env string, configProfile *profile.Profile) (Syncer, error) {
+	accountID, name, err := cloudflare.ParseLocation(configProfile.URL)
+	if err != nil {
+		return nil, err
+	}
+	cloudflareService := cloudflare.NewCloudflareService(configProfile.Token, "")
+
+	switch configProfile.Sync {
+	case profile.CloudflareWorkerSecret, profile.CloudflareWorkerEnvironmentSecret:
+		script := name
+		if configProfile.Sync == profile.CloudflareWorkerEnvironmentSecret {
+			script = workerScript(name, env)
+		}
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return cloudflareService.SyncToWorker(accountID, script, envVars.Variables, envVars.Plain, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return cloudflareService.PlanWorker(accountID, script, envVars.Variables)
+			},
+		}, nil
+	case profile.CloudflarePagesProduction, profile.CloudflarePagesPreview:
+		pagesEnvironment := cloudflare.PagesProduction
+		if configProfile.Sync == profile.CloudflarePagesPreview {
+			pagesEnvironment = cloudflare.PagesPreview
+		}
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return cloudflareService.SyncToPagesProject(accountID, name, pagesEnvironment, envVars.Variables, envVars.Plain, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return cloudflareService.PlanPagesProject(accountID, name, pagesEnvironment, envVars.Variables)
+			},
+		}, nil
+	default:
+		return nil, notImplemented(configProfile)
+	}
+}
+
+func herokuSyncer(configProfile *profile.Profile) Syncer {
+	herokuService := heroku.NewHerokuService(configProfile.Token, "")
+	return syncFuncs{
+		sync: func(envVars *store.Environment) error {
+			return herokuService.SyncToApp(configProfile.URL, envVars.Variables, true)
+		},
+		plan: func(envVars *store.Environment) (*plan.Plan, error) {
+			return herokuService.PlanApp(configProfile.URL, envVars.Variables)
+		},
+	}
+}
+
+// circleciSyncer syncs to CircleCI project environment variables or a context named like the environment.
+// Values cannot be read back, so the owned keys are recorded with the environment in the secret store.
+func circleciSyncer(secretStore store.SecretStore, url, env string, configProfile *profile.Profile) (Syncer, error) {
+	location, target, err := circleciTarget(url, env, configProfile)
+	if err != nil {
+		return nil, err
+	}
+	circleciService := circleci.NewCircleCIService(configProfile.Token, "")
+
+	switch configProfile.Sync {
+	case profile.CircleCIProjectVariable:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				if err := circleciService.SyncToProject(location, envVars.Variables, envVars.Owned[target], true); err != nil {
+					return err
+				}
+				return recordOwned(secretStore, envVars, target)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return circleciService.PlanProject(location, envVars.Variables, envVars.Owned[target])
+			},
+		}, nil
+	case profile.CircleCIContext:
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				if err := circleciService.SyncToContext(location, envVars.Env, envVars.Variables, envVars.Owned[target], true); err != nil {
+					return err
+				}
+				return recordOwned(secretStore, envVars, target)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return circleciService.PlanContext(location, envVars.Env, envVars.Variables, envVars.Owned[target])
+			},
+		}, nil
+	default:
+		return nil, notImplemented(configProfile)
+	}
+}
+
+func azureSyncer(env string, configProfile *profile.Profile) (Syncer, error) {
+	switch configProfile.Sync {
+	case profile.AzureDevOpsVariableGroup:
+		organization, project, group, err := azure.ParseDevOpsLocation(configProfile.URL, env)
+		if err != nil {
+			return nil, err
+		}
+		devOpsService := azure.NewDevOpsService(configProfile.Token, "")
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return devOpsService.SyncToVariableGroup(organization, project, group, envVars.Variables, envVars.Plain, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return devOpsService.PlanVariableGroup(organization, project, group, envVars.Variables)
+			},
+		}, nil
+	case profile.AzureKeyVaultSecret:
+		accessToken, err := azure.KeyVaultAccessToken(configProfile.Token)
+		if err != nil {
+			return nil, err
+		}
+		keyVaultService := azure.NewKeyVaultService(configProfile.URL, accessToken)
+		return syncFuncs{
+			sync: func(envVars *store.Environment) error {
+				return keyVaultService.SyncToVault(envVars.Variables, true)
+			},
+			plan: func(envVars *store.Environment) (*plan.Plan, error) {
+				return keyVaultService.PlanVault(envVars.Variables)
+			},
+		}, nil
+	default:
+		return nil, notImplemented(configProfile)
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/profile"
+	"github.com/hinterland-software/openv/internal/store"
+)
+
+// testProfileURL returns a profile URL the targets of a sync type can be resolved from without network access
+func testProfileURL(syncType profile.SyncType) string {
+	switch {
+	case slices.Contains(profile.ProfileSyncsShopify, syncType):
+		return "my-shop.myshopify.com/storefront"
+	case slices.Contains(profile.ProfileSyncsCloudflare, syncType):
+		return "account/app"
+	case slices.Contains(profile.ProfileSyncsKubernetes, syncType):
+		return "test/apps"
+	case slices.Contains(profile.ProfileSyncsAzure, syncType) && syncType == profile.AzureDevOpsVariableGroup:
+		return "org/project"
+	case slices.Contains(profile.ProfileSyncsAws, syncType):
+		return ""
+	case slices.Contains(profile.ProfileSyncsGitlab, syncType), slices.Contains(profile.ProfileSyncsGitea, syncType),
+		slices.Contains(profile.ProfileSyncsCircleCI, syncType):
+		return "github.com/org/app"
+	default:
+		return "app"
+	}
+}
+
+func TestNewSyncerCoversActiveSyncs(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(kubeconfig, []byte(`current-context: test
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+clusters:
+- name: test
+  cluster:
+    server: https://kubernetes.example.com
+users:
+- name: test
+  user:
+    token: token
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", kubeconfig)
+	t.Setenv("AWS_REGION", "eu-west-1")
+
+	for _, syncType := range profile.ActiveProfileSyncs {
+		configProfile := &profile.Profile{Name: "test", Sync: syncType, Token: "key:secret", URL: testProfileURL(syncType)}
+		syncer, err := newSyncer(store.NewMemoryStore(), "https://github.com/org/app", "production", configProfile)
+		if err != nil || syncer == nil {
+			t.Errorf("newSyncer(%s) = %v, %v, want a syncer", syncType, syncer, err)
+		}
+	}
+}
+
+func TestNewSyncerRejectsUnknownSync(t *testing.T) {
+	configProfile := &profile.Profile{Name: "test", Sync: profile.SyncType("unknown")}
+	if _, err := newSyncer(store.NewMemoryStore(), "https://github.com/org/app", "production", configProfile); err == nil {
+		t.Error("newSyncer() error = nil, want not implemented error")
+	}
+}
+
+func TestSyncerPlanUsesOwnedKeys(t *testing.T) {
+	configProfile := &profile.Profile{Name: "deno", Sync: profile.DenoDeploy, Token: "token", URL: "app"}
+	syncer, err := newSyncer(store.NewMemoryStore(), "https://github.com/org/app", "production", configProfile)
+	if err != nil {
+		t.Fatalf("newSyncer() error = %v", err)
+	}
+
+	envVars := &store.Environment{
+		Env:       "production",
+		Variables: map[string]string{"KEPT": "1"},
+		Owned:     map[string][]string{ownedTarget(profile.DenoDeploy, "app"): {"KEPT", "REMOVED"}},
+	}
+	syncPlan, err := syncer.Plan(envVars)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	actions := map[string]plan.Action{}
+	for _, change := range syncPlan.Changes {
+		actions[change.Key] = change.Action
+	}
+	if actions["REMOVED"] != plan.ActionDelete || actions["KEPT"] != plan.ActionUpdate {
+		t.Errorf("actions = %v, want KEPT updated and REMOVED deleted", actions)
+	}
+}
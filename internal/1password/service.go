@@ -2,11 +2,13 @@ package onepassword
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 
 	op "github.com/1password/onepassword-sdk-go"
+	"github.com/hinterland-software/openv/internal/store"
 	"github.com/hinterland-software/openv/internal/version"
 )
 
@@ -17,10 +19,12 @@ const DefaultVault = "service-account"
 type ImportOptions struct {
 	Name         string
 	Env          string
-	FilePath     string
 	URL          string
 	VaultID      string
 	SyncProfiles []string
+	Variables    map[string]string
+	Plain        []string
+	Expanded     bool
 }
 
 // GetEnvironmentOptions represents the options for getting environment variables
@@ -30,13 +34,6 @@ type GetEnvironmentOptions struct {
 	VaultID string
 }
 
-// EnvironmentResult contains environment variables and metadata
-type EnvironmentResult struct {
-	Variables map[string]string
-	ItemID    string
-	Env       string
-}
-
 // Service handles 1Password operations including environment variable management
 type Service struct {
 	client *op.Client
@@ -57,14 +54,8 @@ func NewService(ctx context.Context, token string) (*Service, error) {
 
 // Import imports environment variables into 1Password
 func (s *Service) Import(opts ImportOptions) (*op.Item, error) {
-	// Read and parse env file
-	envVars, err := parseEnvFile(opts.FilePath)
-	if err != nil {
-		return nil, err
-	}
-
 	// Create item template
-	item := createItemTemplate(opts, envVars)
+	item := createItemTemplate(opts, opts.Variables)
 
 	// Check for existing item
 	existingItem, err := s.FindExistingItem(opts)
@@ -73,6 +64,10 @@ func (s *Service) Import(opts ImportOptions) (*op.Item, error) {
 	}
 
 	if existingItem != nil {
+		// Keep the keys synced to write-only targets, they still exist there
+		if owned := findField(existingItem.Fields, ownedFieldID); owned != nil {
+			item.Fields = append(item.Fields, *owned)
+		}
 		// Update existing item with new template values
 		*existingItem = op.Item{
 			ID:       existingItem.ID,      // Preserve the original ID
@@ -131,6 +126,39 @@ func (s *Service) UpdateItem(item op.Item) (*op.Item, error) {
 	return &updatedItem, nil
 }
 
+// SetOwned records the keys synced to a target in the metadata of an item
+func (s *Service) SetOwned(vaultID, itemID, target string, keys []string) error {
+	item, err := s.client.Items.Get(s.ctx, vaultID, itemID)
+	if err != nil {
+		return fmt.Errorf("failed to get item: %w", err)
+	}
+
+	owned := map[string][]string{}
+	field := findField(item.Fields, ownedFieldID)
+	if field != nil {
+		owned = parseOwned(field.Value)
+	}
+	owned[target] = store.OwnedKeys(keys)
+	ownedJSON, err := json.Marshal(owned)
+	if err != nil {
+		return fmt.Errorf("failed to marshal owned keys: %w", err)
+	}
+
+	if field != nil {
+		field.Value = string(ownedJSON)
+	} else {
+		item.Fields = append(item.Fields, op.ItemField{
+			ID:        ownedFieldID,
+			FieldType: op.ItemFieldTypeText,
+			Title:     "Owned Keys",
+			Value:     string(ownedJSON),
+			SectionID: &metadataSection.ID,
+		})
+	}
+	_, err = s.UpdateItem(item)
+	return err
+}
+
 // FindExistingItem looks for an existing item with the same name and environment
 func (s *Service) FindExistingItem(opts ImportOptions) (*op.Item, error) {
 	items, err := s.client.Items.ListAll(s.ctx, opts.VaultID)
@@ -168,9 +196,9 @@ func (s *Service) FindExistingItem(opts ImportOptions) (*op.Item, error) {
 }
 
 // GetEnvironment retrieves environment variables for a given URL and environment.
-// Returns an EnvironmentResult containing the variables and item ID.
+// Returns a store.Environment containing the variables and item metadata.
 // Returns an error if no matching environment is found or if there's an API error.
-func (s *Service) GetEnvironment(opts GetEnvironmentOptions) (*EnvironmentResult, error) {
+func (s *Service) GetEnvironment(opts GetEnvironmentOptions) (*store.Environment, error) {
 	// Find the item by URL and environment
 	items, err := s.client.Items.ListAll(s.ctx, opts.VaultID)
 	if err != nil {
@@ -178,7 +206,6 @@ func (s *Service) GetEnvironment(opts GetEnvironmentOptions) (*EnvironmentResult
 	}
 
 	envTag := fmt.Sprintf("env:%s", opts.Env)
-	envVars := make(map[string]string)
 
 	for {
 		itemOverview, err := items.Next()
@@ -223,24 +250,58 @@ func (s *Service) GetEnvironment(opts GetEnvironmentOptions) (*EnvironmentResult
 		}
 
 		// Found the right item, extract environment variables
-		for _, field := range item.Fields {
-			if field.SectionID != nil && *field.SectionID == variablesSection.ID {
-				envVars[field.Title] = field.Value
+		return itemToEnvironment(item, true), nil
+	}
+
+	return nil, store.NotFoundError(opts.URL, opts.Env)
+}
+
+// ListEnvironments returns the metadata of all environments stored in a vault
+func (s *Service) ListEnvironments(vaultID string) ([]store.Environment, error) {
+	items, err := s.client.Items.ListAll(s.ctx, vaultID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+
+	environments := []store.Environment{}
+	for {
+		itemOverview, err := items.Next()
+		if errors.Is(err, op.ErrorIteratorDone) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to iterate items: %w", err)
+		} else if !strings.HasPrefix(strings.ToLower(itemOverview.Title), itemNamePrefix) {
+			continue
+		}
+
+		item, err := s.client.Items.Get(s.ctx, itemOverview.VaultID, itemOverview.ID)
+		if err != nil {
+			if err.Error() == "item is not in an active state" {
+				continue
 			}
+			return nil, fmt.Errorf("failed to get item: %w", err)
 		}
 
-		return &EnvironmentResult{
-			Variables: envVars,
-			ItemID:    item.ID,
-			Env:       opts.Env,
-		}, nil
+		environment := itemToEnvironment(item, false)
+		if environment.URL == "" || environment.Env == "" {
+			continue
+		}
+		environments = append(environments, *environment)
 	}
 
-	return nil, fmt.Errorf("no environment variables found for %s (%s)", opts.URL, opts.Env)
+	return environments, nil
+}
+
+// DeleteItem deletes an item from 1Password
+func (s *Service) DeleteItem(vaultID, itemID string) error {
+	if err := s.client.Items.Delete(s.ctx, vaultID, itemID); err != nil {
+		return fmt.Errorf("failed to delete item: %w", err)
+	}
+	return nil
 }
 
 func (s *Service) ResolveToken(token string) (string, error) {
-	if strings.HasPrefix(token, "op://") {
+	if IsSecretReference(token) {
 		resolvedToken, err := s.client.Secrets.Resolve(s.ctx, token)
 		if err != nil {
 			return "", fmt.Errorf("failed to resolve token: %w", err)
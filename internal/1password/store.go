@@ -0,0 +1,107 @@
+package onepassword
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hinterland-software/openv/internal/store"
+)
+
+// Store adapts Service to the store.SecretStore interface for a single vault
+type Store struct {
+	service *Service
+	vaultID string
+}
+
+var (
+	_ store.SecretStore = (*Store)(nil)
+	_ store.Resolver    = (*Store)(nil)
+)
+
+// NewStore creates a new 1Password backed secret store using the vault with the given title
+func NewStore(ctx context.Context, token, vaultTitle string) (*Store, error) {
+	service, err := NewService(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	vault, err := service.GetVault(vaultTitle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vault: %w", err)
+	}
+
+	return &Store{service: service, vaultID: vault.ID}, nil
+}
+
+// Service returns the underlying 1Password service
+func (s *Store) Service() *Service {
+	return s.service
+}
+
+// GetEnvironment retrieves the environment stored for a URL and environment name
+func (s *Store) GetEnvironment(opts store.GetEnvironmentOptions) (*store.Environment, error) {
+	return s.service.GetEnvironment(GetEnvironmentOptions{
+		URL:     opts.URL,
+		Env:     opts.Env,
+		VaultID: s.vaultID,
+	})
+}
+
+// Put creates or updates the item for the given name and environment
+func (s *Store) Put(opts store.PutOptions) (*store.Environment, error) {
+	item, err := s.service.Import(ImportOptions{
+		Name:         opts.Name,
+		Env:          opts.Env,
+		URL:          opts.URL,
+		VaultID:      s.vaultID,
+		SyncProfiles: opts.SyncProfiles,
+		Variables:    opts.Variables,
+		Plain:        opts.Plain,
+		Expanded:     opts.Expanded,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return itemToEnvironment(*item, true), nil
+}
+
+// FindExistingItem looks for an existing item with the same name and environment
+func (s *Store) FindExistingItem(name, env string) (*store.Environment, error) {
+	item, err := s.service.FindExistingItem(ImportOptions{
+		Name:    name,
+		Env:     env,
+		VaultID: s.vaultID,
+	})
+	if err != nil || item == nil {
+		return nil, err
+	}
+	return itemToEnvironment(*item, true), nil
+}
+
+// ListEnvironments returns the metadata of all environments stored in the vault
+func (s *Store) ListEnvironments() ([]store.Environment, error) {
+	return s.service.ListEnvironments(s.vaultID)
+}
+
+// Delete removes the item stored for a URL and environment name
+func (s *Store) Delete(opts store.GetEnvironmentOptions) error {
+	environment, err := s.GetEnvironment(opts)
+	if err != nil {
+		return err
+	}
+	return s.service.DeleteItem(s.vaultID, environment.ID)
+}
+
+// SetOwned records the keys synced to a target in the item of the environment
+func (s *Store) SetOwned(opts store.GetEnvironmentOptions, target string, keys []string) error {
+	environment, err := s.GetEnvironment(opts)
+	if err != nil {
+		return err
+	}
+	return s.service.SetOwned(s.vaultID, environment.ID, target, keys)
+}
+
+// ResolveReference resolves a secret reference like op://vault/item/field
+func (s *Store) ResolveReference(reference string) (string, error) {
+	return s.service.ResolveToken(reference)
+}
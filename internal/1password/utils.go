@@ -1,14 +1,25 @@
 package onepassword
 
 import (
+	"encoding/json"
 	"fmt"
-	"os"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	op "github.com/1password/onepassword-sdk-go"
 	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/store"
+)
+
+const (
+	// itemNamePrefix is the prefix of all item titles created by openv
+	itemNamePrefix = ".env."
+	// secretReferencePrefix is the scheme of 1Password secret references
+	secretReferencePrefix = "op://"
+	// ownedFieldID is the metadata field holding the JSON encoded keys synced to write-only targets
+	ownedFieldID = "owned_keys"
 )
 
 var (
@@ -29,35 +40,6 @@ var (
 	}
 )
 
-func parseEnvFile(filePath string) (map[string]string, error) {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read env file: %w", err)
-	}
-
-	vars := make(map[string]string)
-	lines := strings.Split(string(content), "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		value = strings.Trim(value, `"'`)
-		vars[key] = value
-	}
-
-	return vars, nil
-}
-
 func createItemTemplate(opts ImportOptions, envVars map[string]string) op.ItemCreateParams {
 	isoDate := time.Now().UTC().Format(time.RFC3339)
 
@@ -92,12 +74,26 @@ func createItemTemplate(opts ImportOptions, envVars map[string]string) op.ItemCr
 		})
 	}
 
-	// Add environment variables
+	if opts.Expanded {
+		fields = append(fields, op.ItemField{
+			ID:        "expanded",
+			FieldType: op.ItemFieldTypeText,
+			Title:     "Expanded",
+			Value:     "true",
+			SectionID: &metadataSection.ID,
+		})
+	}
+
+	// Add environment variables, plain variables are stored as text fields
 	envFields := []op.ItemField{}
 	for key, value := range envVars {
+		fieldType := op.ItemFieldTypeConcealed
+		if slices.Contains(opts.Plain, key) {
+			fieldType = op.ItemFieldTypeText
+		}
 		envFields = append(envFields, op.ItemField{
 			ID:        key,
-			FieldType: op.ItemFieldTypeConcealed,
+			FieldType: fieldType,
 			Title:     key,
 			Value:     value,
 			SectionID: &variablesSection.ID,
@@ -153,7 +149,67 @@ func deriveNameFromGithubURL(url string) string {
 }
 
 func getItemName(name string) string {
-	return strings.ToLower(fmt.Sprintf(".env.%s", name))
+	return strings.ToLower(itemNamePrefix + name)
+}
+
+// itemToEnvironment converts an item created by createItemTemplate into a store.Environment
+func itemToEnvironment(item op.Item, withVariables bool) *store.Environment {
+	environment := &store.Environment{
+		ID:   item.ID,
+		Name: item.Title,
+	}
+	if withVariables {
+		environment.Variables = make(map[string]string)
+	}
+
+	for _, field := range item.Fields {
+		switch {
+		case field.SectionID == nil:
+			continue
+		case *field.SectionID == variablesSection.ID && withVariables:
+			environment.Variables[field.Title] = field.Value
+			if field.FieldType != op.ItemFieldTypeConcealed {
+				environment.Plain = append(environment.Plain, field.Title)
+			}
+		case *field.SectionID == metadataSection.ID:
+			switch field.ID {
+			case "env":
+				environment.Env = field.Value
+			case "url":
+				environment.URL = field.Value
+			case "sync_profiles":
+				if field.Value != "" {
+					environment.SyncProfiles = strings.Split(field.Value, ",")
+				}
+			case "expanded":
+				environment.Expanded = field.Value == "true"
+			case ownedFieldID:
+				if withVariables {
+					environment.Owned = parseOwned(field.Value)
+				}
+			}
+		}
+	}
+	return environment
+}
+
+// findField returns the field with the given ID, if any
+func findField(fields []op.ItemField, id string) *op.ItemField {
+	for i, field := range fields {
+		if field.ID == id {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// parseOwned parses the owned keys stored in the owned_keys field
+func parseOwned(value string) map[string][]string {
+	owned := map[string][]string{}
+	if err := json.Unmarshal([]byte(value), &owned); err != nil {
+		logging.Logger.Warn("failed to unmarshal owned keys", "error", err)
+	}
+	return owned
 }
 
 func GetBaseName(url string) string {
@@ -166,3 +222,8 @@ func GetBaseName(url string) string {
 	}
 	return url
 }
+
+// IsSecretReference reports whether value is a 1Password secret reference like op://vault/item/field
+func IsSecretReference(value string) bool {
+	return strings.HasPrefix(value, secretReferencePrefix)
+}
@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// Config holds the credentials and region used to call AWS
+type Config struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+}
+
+// LoadConfig returns the AWS configuration for a profile token of the form
+// <access key id>:<secret access key>[:<session token>].
+// An empty token uses AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+// The region is read from AWS_REGION or AWS_DEFAULT_REGION.
+func LoadConfig(token string) (Config, error) {
+	config := Config{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Region:          os.Getenv("AWS_REGION"),
+	}
+	if config.Region == "" {
+		config.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+
+	if token != "" {
+		parts := strings.SplitN(token, ":", 3)
+		if len(parts) < 2 {
+			return Config{}, fmt.Errorf("invalid AWS token, expected <access key id>:<secret access key>[:<session token>]")
+		}
+		config.AccessKeyID, config.SecretAccessKey, config.SessionToken = parts[0], parts[1], ""
+		if len(parts) == 3 {
+			config.SessionToken = parts[2]
+		}
+	}
+
+	switch {
+	case config.AccessKeyID == "" || config.SecretAccessKey == "":
+		return Config{}, fmt.Errorf("no AWS credentials configured, set the profile token or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	case config.Region == "":
+		return Config{}, fmt.Errorf("no AWS region configured, set AWS_REGION")
+	}
+	return config, nil
+}
+
+// endpoint returns the endpoint of a service, which AWS_ENDPOINT_URL_<SERVICE> or AWS_ENDPOINT_URL
+// override, e.g. for LocalStack
+func endpoint(config Config, service, envSuffix string) string {
+	if url := os.Getenv("AWS_ENDPOINT_URL_" + envSuffix); url != "" {
+		return url
+	}
+	if url := os.Getenv("AWS_ENDPOINT_URL"); url != "" {
+		return url
+	}
+	return fmt.Sprintf("https://%s.%s.amazonaws.com", service, config.Region)
+}
+
+// call sends an action of an AWS JSON protocol API
+func call(client *rest.Client, target string, input, output any) error {
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	logging.Logger.Debug("calling AWS", "target", target)
+	return client.DoRawWithHeaders("POST", "/", "application/x-amz-json-1.1", map[string]string{
+		"X-Amz-Target": target,
+	}, bytes.NewReader(payload), output)
+}
+
+// hasErrorType reports whether err is an AWS error response of the given type, e.g. ResourceNotFoundException
+func hasErrorType(err error, errorType string) bool {
+	var statusErr *rest.StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	response := struct {
+		Type string `json:"__type"`
+	}{}
+	if json.Unmarshal([]byte(statusErr.Body), &response) != nil {
+		return false
+	}
+	return response.Type == errorType || strings.HasSuffix(response.Type, "#"+errorType)
+}
@@ -0,0 +1,68 @@
+package aws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// awsHandler answers an action of an AWS JSON protocol API with a status code and response body
+type awsHandler func(target string, input json.RawMessage) (int, any)
+
+// newFakeAWS serves an AWS JSON protocol API, rejecting requests not signed for service
+func newFakeAWS(t *testing.T, service string, handler awsHandler) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credential := "Credential=" + testSuiteConfig.AccessKeyID + "/"
+		scope := "/" + testSuiteConfig.Region + "/" + service + "/aws4_request"
+		authorization := r.Header.Get("Authorization")
+		if !strings.Contains(authorization, credential) || !strings.Contains(authorization, scope) || r.Header.Get("X-Amz-Date") == "" {
+			t.Errorf("request not signed for %s: %s", service, authorization)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/x-amz-json-1.1" {
+			t.Errorf("unexpected request %s with content type %s", r.Method, r.Header.Get("Content-Type"))
+		}
+
+		var input json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		status, output := handler(r.Header.Get("X-Amz-Target"), input)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(output)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	t.Setenv("AWS_SESSION_TOKEN", "env-session")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "eu-west-1")
+
+	config, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if want := (Config{"env-key", "env-secret", "env-session", "eu-west-1"}); config != want {
+		t.Errorf("LoadConfig() = %+v, want %+v", config, want)
+	}
+
+	config, err = LoadConfig("key:secret")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if want := (Config{"key", "secret", "", "eu-west-1"}); config != want {
+		t.Errorf("LoadConfig() = %+v, want %+v, the session token must not come from the environment", config, want)
+	}
+
+	if _, err := LoadConfig("key"); err == nil {
+		t.Error("LoadConfig() error = nil, want invalid token error")
+	}
+}
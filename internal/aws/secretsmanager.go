@@ -0,0 +1,132 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// SecretsManagerService handles syncing environment variables to AWS Secrets Manager
+type SecretsManagerService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewSecretsManagerService creates a new SecretsManagerService, an empty baseURL uses the regional endpoint
+func NewSecretsManagerService(config Config, baseURL string) *SecretsManagerService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = endpoint(config, "secretsmanager", "SECRETS_MANAGER")
+	}
+	client := rest.NewClient(ctx, baseURL, nil).WithSigner(signer(config, "secretsmanager"))
+	return &SecretsManagerService{client: client, ctx: ctx}
+}
+
+// SyncToSecret stores the environment variables as JSON object in the secret, creating it if needed.
+// Keys not synced by openv are kept, the synced keys are recorded in the OPENV_KEYS_SECRETS_MANAGER key.
+func (s *SecretsManagerService) SyncToSecret(name string, envVars map[string]string, withCleanup bool) error {
+	current, exists, err := s.getSecret(name)
+	if err != nil {
+		return err
+	}
+
+	logging.Logger.Debug("syncing to Secrets Manager secret", "name", name)
+
+	updated := maps.Clone(current)
+	if bookkeeping, ok := current[internal.OPENV_KEYS_SECRETS_MANAGER]; ok && withCleanup {
+		for _, removed := range internal.ParseKeys(bookkeeping) {
+			if _, exists := envVars[removed]; !exists {
+				delete(updated, removed)
+			}
+		}
+	}
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_SECRETS_MANAGER
+		}
+		updated[key] = value
+	}
+
+	if exists && maps.Equal(current, updated) {
+		return nil
+	}
+	secretString, err := json.Marshal(updated)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secret: %w", err)
+	}
+
+	if !exists {
+		if err := call(s.client, "secretsmanager.CreateSecret", map[string]string{
+			"Name":         name,
+			"SecretString": string(secretString),
+		}, nil); err != nil {
+			return fmt.Errorf("failed to create Secrets Manager secret %s: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := call(s.client, "secretsmanager.PutSecretValue", map[string]string{
+		"SecretId":     name,
+		"SecretString": string(secretString),
+	}, nil); err != nil {
+		return fmt.Errorf("failed to update Secrets Manager secret %s: %w", name, err)
+	}
+	return nil
+}
+
+// PlanSecret computes the changes SyncToSecret would apply
+func (s *SecretsManagerService) PlanSecret(name string, envVars map[string]string) (*plan.Plan, error) {
+	current, _, err := s.getSecret(name)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_SECRETS_MANAGER
+		}
+		desired[key] = value
+	}
+
+	values := map[string]*string{}
+	for key, value := range current {
+		values[key] = &value
+	}
+	owned := []string{}
+	if bookkeeping, ok := current[internal.OPENV_KEYS_SECRETS_MANAGER]; ok {
+		owned = internal.ParseKeys(bookkeeping)
+	}
+	return plan.Compute(fmt.Sprintf("Secrets Manager secret %s", name), desired, plan.State{Values: values, Owned: owned}), nil
+}
+
+// getSecret returns the key-value pairs of the secret and whether it exists
+func (s *SecretsManagerService) getSecret(name string) (map[string]string, bool, error) {
+	if name == "" {
+		return nil, false, fmt.Errorf("no Secrets Manager secret configured")
+	}
+	output := struct {
+		SecretString string `json:"SecretString"`
+	}{}
+	err := call(s.client, "secretsmanager.GetSecretValue", map[string]string{"SecretId": name}, &output)
+	if hasErrorType(err, "ResourceNotFoundException") {
+		return map[string]string{}, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get Secrets Manager secret %s: %w", name, err)
+	}
+
+	values := map[string]string{}
+	if output.SecretString != "" {
+		if err := json.Unmarshal([]byte(output.SecretString), &values); err != nil {
+			return nil, false, fmt.Errorf("Secrets Manager secret %s is not a JSON object of strings: %w", name, err)
+		}
+	}
+	return values, true, nil
+}
@@ -0,0 +1,107 @@
+package aws
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+)
+
+// fakeSecretsManager keeps the secret strings of Secrets Manager by name and records the called targets
+type fakeSecretsManager struct {
+	secrets map[string]string
+	targets []string
+}
+
+func newFakeSecretsManager(t *testing.T, secrets map[string]string) (*fakeSecretsManager, *SecretsManagerService) {
+	f := &fakeSecretsManager{secrets: secrets}
+	server := newFakeAWS(t, "secretsmanager", func(target string, body json.RawMessage) (int, any) {
+		f.targets = append(f.targets, target)
+		var input struct {
+			Name         string `json:"Name"`
+			SecretID     string `json:"SecretId"`
+			SecretString string `json:"SecretString"`
+		}
+		_ = json.Unmarshal(body, &input)
+
+		switch target {
+		case "secretsmanager.GetSecretValue":
+			secretString, ok := f.secrets[input.SecretID]
+			if !ok {
+				return http.StatusBadRequest, map[string]string{"__type": "ResourceNotFoundException", "message": "not found"}
+			}
+			return http.StatusOK, map[string]string{"Name": input.SecretID, "SecretString": secretString}
+		case "secretsmanager.CreateSecret":
+			f.secrets[input.Name] = input.SecretString
+			return http.StatusOK, map[string]string{"Name": input.Name}
+		case "secretsmanager.PutSecretValue":
+			f.secrets[input.SecretID] = input.SecretString
+			return http.StatusOK, map[string]string{"Name": input.SecretID}
+		}
+		t.Errorf("unexpected target %s", target)
+		return http.StatusBadRequest, map[string]string{"__type": "UnknownOperationException"}
+	})
+	return f, NewSecretsManagerService(testSuiteConfig, server.URL)
+}
+
+func (f *fakeSecretsManager) values(t *testing.T, name string) map[string]string {
+	t.Helper()
+	values := map[string]string{}
+	if err := json.Unmarshal([]byte(f.secrets[name]), &values); err != nil {
+		t.Fatalf("secret %s is not a JSON object: %v", name, err)
+	}
+	return values
+}
+
+func TestSyncToSecretCreates(t *testing.T) {
+	f, service := newFakeSecretsManager(t, map[string]string{})
+
+	if err := service.SyncToSecret("app/prod", map[string]string{"KEY": "value", internal.OPENV_KEYS: `["KEY"]`}, true); err != nil {
+		t.Fatalf("SyncToSecret() error = %v", err)
+	}
+
+	values := f.values(t, "app/prod")
+	if values["KEY"] != "value" || values[internal.OPENV_KEYS_SECRETS_MANAGER] != `["KEY"]` || len(values) != 2 {
+		t.Errorf("secret = %v, want KEY and the bookkeeping key", values)
+	}
+}
+
+func TestSyncToSecretCleanup(t *testing.T) {
+	f, service := newFakeSecretsManager(t, map[string]string{
+		"app/prod": `{"KEPT":"old","REMOVED":"gone","UNMANAGED":"keep","OPENV_KEYS_SECRETS_MANAGER":"[\"KEPT\",\"REMOVED\"]"}`,
+	})
+
+	if err := service.SyncToSecret("app/prod", map[string]string{"KEPT": "new", internal.OPENV_KEYS: `["KEPT"]`}, true); err != nil {
+		t.Fatalf("SyncToSecret() error = %v", err)
+	}
+
+	values := f.values(t, "app/prod")
+	if _, ok := values["REMOVED"]; ok {
+		t.Error("REMOVED was not deleted")
+	}
+	if values["KEPT"] != "new" || values["UNMANAGED"] != "keep" {
+		t.Errorf("secret = %v, want KEPT updated and UNMANAGED kept", values)
+	}
+}
+
+func TestSyncToSecretUnchanged(t *testing.T) {
+	f, service := newFakeSecretsManager(t, map[string]string{"app/prod": `{"KEY":"value"}`})
+
+	if err := service.SyncToSecret("app/prod", map[string]string{"KEY": "value"}, true); err != nil {
+		t.Fatalf("SyncToSecret() error = %v", err)
+	}
+	for _, target := range f.targets {
+		if target != "secretsmanager.GetSecretValue" {
+			t.Errorf("unexpected call %s for an unchanged secret", target)
+		}
+	}
+}
+
+func TestSyncToSecretRejectsNonJSON(t *testing.T) {
+	_, service := newFakeSecretsManager(t, map[string]string{"app/prod": "plain text"})
+
+	if err := service.SyncToSecret("app/prod", map[string]string{"KEY": "value"}, true); err == nil {
+		t.Error("SyncToSecret() error = nil, want an error for a secret that is not a JSON object")
+	}
+}
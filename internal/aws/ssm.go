@@ -0,0 +1,201 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+const (
+	parameterTypeString       = "String"
+	parameterTypeSecureString = "SecureString"
+	// deleteBatchSize is the maximum number of parameters DeleteParameters accepts
+	deleteBatchSize = 10
+)
+
+type parameter struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+	Type  string `json:"Type"`
+}
+
+// SSMService handles syncing environment variables to AWS Systems Manager Parameter Store
+type SSMService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewSSMService creates a new SSMService, an empty baseURL uses the regional endpoint
+func NewSSMService(config Config, baseURL string) *SSMService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = endpoint(config, "ssm", "SSM")
+	}
+	client := rest.NewClient(ctx, baseURL, nil).WithSigner(signer(config, "ssm"))
+	return &SSMService{client: client, ctx: ctx}
+}
+
+// SyncToPath writes each variable as parameter <path>/<KEY>. Keys listed in plain are
+// stored as String, all others as SecureString. The keys are recorded in <path>/OPENV_KEYS_SSM.
+// Parameters cannot be empty, so the parameter of an empty variable is deleted.
+func (s *SSMService) SyncToPath(path string, envVars map[string]string, plain []string, withCleanup bool) error {
+	path = strings.TrimSuffix(path, "/")
+	existing, err := s.listParameters(path)
+	if err != nil {
+		return err
+	}
+
+	logging.Logger.Debug("syncing to SSM parameters", "path", path)
+
+	if withCleanup {
+		s.cleanupPath(path, existing, envVars)
+	}
+
+	emptied := []string{}
+	for _, key := range slices.Sorted(maps.Keys(envVars)) {
+		desired := parameter{Name: path + "/" + key, Value: envVars[key], Type: parameterTypeSecureString}
+		switch {
+		case key == internal.OPENV_KEYS:
+			desired = parameter{Name: path + "/" + internal.OPENV_KEYS_SSM, Value: envVars[key], Type: parameterTypeString}
+		case slices.Contains(plain, key):
+			desired.Type = parameterTypeString
+		}
+		if desired.Value == "" {
+			if _, exists := existing[desired.Name]; exists {
+				emptied = append(emptied, desired.Name)
+			}
+			continue
+		}
+		if current, exists := existing[desired.Name]; exists && current == desired {
+			continue
+		}
+
+		input := map[string]any{
+			"Name":      desired.Name,
+			"Value":     desired.Value,
+			"Type":      desired.Type,
+			"Overwrite": true,
+		}
+		if err := call(s.client, "AmazonSSM.PutParameter", input, nil); err != nil {
+			return fmt.Errorf("failed to put SSM parameter %s: %w", desired.Name, err)
+		}
+	}
+
+	for batch := range slices.Chunk(emptied, deleteBatchSize) {
+		logging.Logger.Info("deleting SSM parameters with empty values", "names", batch)
+		if err := call(s.client, "AmazonSSM.DeleteParameters", map[string]any{"Names": batch}, nil); err != nil {
+			return fmt.Errorf("failed to delete empty SSM parameters %v: %w", batch, err)
+		}
+	}
+	return nil
+}
+
+// PlanPath computes the changes SyncToPath would apply
+func (s *SSMService) PlanPath(path string, envVars map[string]string) (*plan.Plan, error) {
+	path = strings.TrimSuffix(path, "/")
+	existing, err := s.listParameters(path)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := make(map[string]string, len(envVars))
+	emptied := []string{}
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_SSM
+		}
+		if value == "" {
+			emptied = append(emptied, key)
+			continue
+		}
+		desired[key] = value
+	}
+
+	current := map[string]*string{}
+	for name, p := range existing {
+		current[strings.TrimPrefix(name, path+"/")] = &p.Value
+	}
+
+	owned := []string{}
+	if bookkeeping, ok := existing[path+"/"+internal.OPENV_KEYS_SSM]; ok {
+		owned = internal.ParseKeys(bookkeeping.Value)
+	}
+	// Empty values cannot be stored, SyncToPath deletes their parameters whether openv owns them or not
+	for _, key := range emptied {
+		if !slices.Contains(owned, key) {
+			owned = append(owned, key)
+		}
+	}
+	p := plan.Compute(fmt.Sprintf("SSM parameters under %s", path), desired, plan.State{Values: current, Owned: owned})
+	for i, change := range p.Changes {
+		if change.Action == plan.ActionDelete && slices.Contains(emptied, change.Key) {
+			p.Changes[i].Reason = "SSM parameters cannot be empty"
+		}
+	}
+	return p, nil
+}
+
+// cleanupPath deletes parameters that were synced before but are no longer present in envVars
+func (s *SSMService) cleanupPath(path string, existing map[string]parameter, envVars map[string]string) {
+	bookkeeping, ok := existing[path+"/"+internal.OPENV_KEYS_SSM]
+	if !ok {
+		return
+	}
+
+	removed := []string{}
+	for _, key := range internal.ParseKeys(bookkeeping.Value) {
+		if _, exists := envVars[key]; exists {
+			continue
+		}
+		if _, exists := existing[path+"/"+key]; exists {
+			removed = append(removed, path+"/"+key)
+		}
+	}
+
+	for batch := range slices.Chunk(removed, deleteBatchSize) {
+		if err := call(s.client, "AmazonSSM.DeleteParameters", map[string]any{"Names": batch}, nil); err != nil {
+			logging.Logger.Warn("failed to delete SSM parameters", "names", batch, "error", err)
+		}
+	}
+}
+
+// listParameters lists the decrypted parameters directly under path by name
+func (s *SSMService) listParameters(path string) (map[string]parameter, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid SSM parameter path %s, expected /<path>", path)
+	}
+	existing := map[string]parameter{}
+	nextToken := ""
+	for {
+		input := map[string]any{
+			"Path":           path,
+			"Recursive":      false,
+			"WithDecryption": true,
+			"MaxResults":     10,
+		}
+		if nextToken != "" {
+			input["NextToken"] = nextToken
+		}
+		output := struct {
+			Parameters []parameter `json:"Parameters"`
+			NextToken  string      `json:"NextToken"`
+		}{}
+		if err := call(s.client, "AmazonSSM.GetParametersByPath", input, &output); err != nil {
+			return nil, fmt.Errorf("failed to list SSM parameters: %w", err)
+		}
+		for _, p := range output.Parameters {
+			existing[p.Name] = p
+		}
+		if output.NextToken == "" {
+			return existing, nil
+		}
+		nextToken = output.NextToken
+	}
+}
@@ -0,0 +1,205 @@
+package aws
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// fakeSSM keeps the parameters of a Parameter Store and records the deleted batches
+type fakeSSM struct {
+	parameters map[string]parameter
+	deletes    [][]string
+	puts       []string
+}
+
+func newFakeSSM(t *testing.T, parameters ...parameter) (*fakeSSM, *SSMService) {
+	f := &fakeSSM{parameters: map[string]parameter{}}
+	for _, p := range parameters {
+		f.parameters[p.Name] = p
+	}
+	server := newFakeAWS(t, "ssm", func(target string, body json.RawMessage) (int, any) {
+		switch target {
+		case "AmazonSSM.GetParametersByPath":
+			var input struct {
+				Path       string `json:"Path"`
+				MaxResults int    `json:"MaxResults"`
+				NextToken  string `json:"NextToken"`
+			}
+			_ = json.Unmarshal(body, &input)
+			if input.MaxResults < 1 || input.MaxResults > 10 {
+				return http.StatusBadRequest, map[string]string{"__type": "ValidationException"}
+			}
+			names := []string{}
+			for _, name := range slices.Sorted(maps.Keys(f.parameters)) {
+				if strings.HasPrefix(name, input.Path+"/") && !strings.Contains(strings.TrimPrefix(name, input.Path+"/"), "/") {
+					names = append(names, name)
+				}
+			}
+			start := 0
+			if input.NextToken != "" {
+				start = slices.Index(names, input.NextToken)
+			}
+			end := min(start+input.MaxResults, len(names))
+			page := []parameter{}
+			for _, name := range names[start:end] {
+				page = append(page, f.parameters[name])
+			}
+			nextToken := ""
+			if end < len(names) {
+				nextToken = names[end]
+			}
+			return http.StatusOK, map[string]any{"Parameters": page, "NextToken": nextToken}
+		case "AmazonSSM.PutParameter":
+			var input parameter
+			_ = json.Unmarshal(body, &input)
+			f.parameters[input.Name] = input
+			f.puts = append(f.puts, input.Name)
+			return http.StatusOK, map[string]any{"Version": 1}
+		case "AmazonSSM.DeleteParameters":
+			var input struct {
+				Names []string `json:"Names"`
+			}
+			_ = json.Unmarshal(body, &input)
+			if len(input.Names) > 10 {
+				return http.StatusBadRequest, map[string]string{"__type": "ValidationException"}
+			}
+			for _, name := range input.Names {
+				delete(f.parameters, name)
+			}
+			f.deletes = append(f.deletes, input.Names)
+			return http.StatusOK, map[string]any{"DeletedParameters": input.Names}
+		}
+		t.Errorf("unexpected target %s", target)
+		return http.StatusBadRequest, map[string]string{"__type": "UnknownOperationException"}
+	})
+	return f, NewSSMService(testSuiteConfig, server.URL)
+}
+
+func TestSyncToPath(t *testing.T) {
+	f, service := newFakeSSM(t,
+		parameter{Name: "/app/UNCHANGED", Value: "same", Type: parameterTypeSecureString},
+		parameter{Name: "/app/UPDATED", Value: "old", Type: parameterTypeSecureString},
+		parameter{Name: "/app/EMPTIED", Value: "old", Type: parameterTypeSecureString},
+		parameter{Name: "/app/nested/KEY", Value: "other", Type: parameterTypeString},
+	)
+
+	err := service.SyncToPath("/app/", map[string]string{
+		"UNCHANGED":         "same",
+		"UPDATED":           "new",
+		"PUBLIC":            "plain",
+		"EMPTY":             "",
+		"EMPTIED":           "",
+		internal.OPENV_KEYS: `["EMPTIED","EMPTY","PUBLIC","UNCHANGED","UPDATED"]`,
+	}, []string{"PUBLIC"}, true)
+	if err != nil {
+		t.Fatalf("SyncToPath() error = %v", err)
+	}
+
+	if want := []string{"/app/OPENV_KEYS_SSM", "/app/PUBLIC", "/app/UPDATED"}; !slices.Equal(f.puts, want) {
+		t.Errorf("put parameters = %v, want %v", f.puts, want)
+	}
+	if got := f.parameters["/app/PUBLIC"].Type; got != parameterTypeString {
+		t.Errorf("PUBLIC type = %s, want %s", got, parameterTypeString)
+	}
+	if got := f.parameters["/app/UPDATED"]; got.Value != "new" || got.Type != parameterTypeSecureString {
+		t.Errorf("UPDATED = %+v, want the new value as SecureString", got)
+	}
+	if want := [][]string{{"/app/EMPTIED"}}; !slices.EqualFunc(f.deletes, want, slices.Equal) {
+		t.Errorf("deleted parameters = %v, want %v", f.deletes, want)
+	}
+}
+
+func TestSyncToPathCleanupBatches(t *testing.T) {
+	owned := []string{}
+	parameters := []parameter{}
+	for i := range 25 {
+		key := "REMOVED_" + string(rune('A'+i))
+		owned = append(owned, key)
+		parameters = append(parameters, parameter{Name: "/app/" + key, Value: "gone", Type: parameterTypeSecureString})
+	}
+	ownedJSON, _ := json.Marshal(owned)
+	parameters = append(parameters,
+		parameter{Name: "/app/" + internal.OPENV_KEYS_SSM, Value: string(ownedJSON), Type: parameterTypeString},
+		parameter{Name: "/app/UNMANAGED", Value: "keep", Type: parameterTypeString},
+	)
+	f, service := newFakeSSM(t, parameters...)
+
+	if err := service.SyncToPath("/app", map[string]string{"KEY": "value", internal.OPENV_KEYS: `["KEY"]`}, nil, true); err != nil {
+		t.Fatalf("SyncToPath() error = %v", err)
+	}
+
+	sizes := []int{}
+	for _, batch := range f.deletes {
+		sizes = append(sizes, len(batch))
+	}
+	if want := []int{10, 10, 5}; !slices.Equal(sizes, want) {
+		t.Errorf("delete batch sizes = %v, want %v", sizes, want)
+	}
+	if _, ok := f.parameters["/app/UNMANAGED"]; !ok {
+		t.Error("UNMANAGED was deleted")
+	}
+	if _, ok := f.parameters["/app/REMOVED_A"]; ok {
+		t.Error("REMOVED_A was not deleted")
+	}
+}
+
+func TestPlanPath(t *testing.T) {
+	_, service := newFakeSSM(t,
+		parameter{Name: "/app/" + internal.OPENV_KEYS_SSM, Value: `["SAME","REMOVED"]`, Type: parameterTypeString},
+		parameter{Name: "/app/SAME", Value: "value", Type: parameterTypeSecureString},
+		parameter{Name: "/app/REMOVED", Value: "gone", Type: parameterTypeSecureString},
+		parameter{Name: "/app/UNMANAGED", Value: "keep", Type: parameterTypeString},
+	)
+
+	p, err := service.PlanPath("/app", map[string]string{"SAME": "value", "ADDED": "new"})
+	if err != nil {
+		t.Fatalf("PlanPath() error = %v", err)
+	}
+	actions := map[string]plan.Action{}
+	for _, change := range p.Changes {
+		actions[change.Key] = change.Action
+	}
+	want := map[string]plan.Action{"SAME": plan.ActionUnchanged, "ADDED": plan.ActionCreate, "REMOVED": plan.ActionDelete}
+	for key, action := range want {
+		if actions[key] != action {
+			t.Errorf("action of %s = %v, want %v", key, actions[key], action)
+		}
+	}
+	if _, ok := actions["UNMANAGED"]; ok {
+		t.Error("plan includes UNMANAGED, which openv does not own")
+	}
+}
+
+func TestPlanPathEmptyValues(t *testing.T) {
+	_, service := newFakeSSM(t,
+		parameter{Name: "/app/" + internal.OPENV_KEYS_SSM, Value: `["OWNED"]`, Type: parameterTypeString},
+		parameter{Name: "/app/OWNED", Value: "old", Type: parameterTypeSecureString},
+		parameter{Name: "/app/UNMANAGED", Value: "old", Type: parameterTypeSecureString},
+	)
+
+	p, err := service.PlanPath("/app", map[string]string{"OWNED": "", "UNMANAGED": "", "MISSING": ""})
+	if err != nil {
+		t.Fatalf("PlanPath() error = %v", err)
+	}
+	want := []plan.Change{
+		{Key: "OWNED", Action: plan.ActionDelete, Reason: "SSM parameters cannot be empty"},
+		{Key: "UNMANAGED", Action: plan.ActionDelete, Reason: "SSM parameters cannot be empty"},
+	}
+	if !slices.Equal(p.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", p.Changes, want)
+	}
+}
+
+func TestSyncToPathRejectsRelativePath(t *testing.T) {
+	_, service := newFakeSSM(t)
+	if err := service.SyncToPath("app", map[string]string{"KEY": "value"}, nil, true); err == nil {
+		t.Error("SyncToPath() error = nil, want invalid path error")
+	}
+}
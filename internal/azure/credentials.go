@@ -0,0 +1,48 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// DefaultAuthorityHost is the Microsoft Entra ID endpoint issuing access tokens
+const DefaultAuthorityHost = "https://login.microsoftonline.com"
+
+// keyVaultScope is the OAuth scope of the Key Vault data plane
+const keyVaultScope = "https://vault.azure.net/.default"
+
+// KeyVaultAccessToken returns token if set, otherwise an access token for Key Vault requested with the
+// client credentials of a service principal in AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET
+func KeyVaultAccessToken(token string) (string, error) {
+	if token != "" {
+		return token, nil
+	}
+	tenantID, clientID, clientSecret := os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_CLIENT_ID"), os.Getenv("AZURE_CLIENT_SECRET")
+	if tenantID == "" || clientID == "" || clientSecret == "" {
+		return "", fmt.Errorf("no Azure credentials, set the profile token to an access token or AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET")
+	}
+	authorityHost := os.Getenv("AZURE_AUTHORITY_HOST")
+	if authorityHost == "" {
+		authorityHost = DefaultAuthorityHost
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", clientID)
+	form.Set("client_secret", clientSecret)
+	form.Set("scope", keyVaultScope)
+	response := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	client := rest.NewClient(context.Background(), authorityHost, nil)
+	path := "/" + url.PathEscape(tenantID) + "/oauth2/v2.0/token"
+	if err := client.DoRaw("POST", path, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), &response); err != nil {
+		return "", fmt.Errorf("failed to get Azure access token: %w", err)
+	}
+	return response.AccessToken, nil
+}
@@ -0,0 +1,198 @@
+package azure
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// DefaultDevOpsBaseURL is the base URL of Azure DevOps Services
+const DefaultDevOpsBaseURL = "https://dev.azure.com"
+
+const devOpsAPIVersion = "7.1"
+
+type variable struct {
+	// Value is null for secrets, which Azure DevOps does not return
+	Value    *string `json:"value"`
+	IsSecret bool    `json:"isSecret,omitempty"`
+}
+
+type projectReference struct {
+	Name             string `json:"name"`
+	Description      string `json:"description,omitempty"`
+	ProjectReference struct {
+		ID   string `json:"id,omitempty"`
+		Name string `json:"name"`
+	} `json:"projectReference"`
+}
+
+type variableGroup struct {
+	ID                             int                 `json:"id,omitempty"`
+	Name                           string              `json:"name"`
+	Type                           string              `json:"type"`
+	Description                    string              `json:"description,omitempty"`
+	Variables                      map[string]variable `json:"variables"`
+	VariableGroupProjectReferences []projectReference  `json:"variableGroupProjectReferences"`
+}
+
+// DevOpsService handles syncing environment variables to Azure DevOps Library variable groups
+type DevOpsService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewDevOpsService creates a new DevOpsService authenticating with a personal access token, an empty baseURL uses DefaultDevOpsBaseURL
+func NewDevOpsService(token, baseURL string) *DevOpsService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = DefaultDevOpsBaseURL
+	}
+	client := rest.NewClient(ctx, baseURL, map[string]string{
+		"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(":"+token)),
+	})
+	return &DevOpsService{client: client, ctx: ctx}
+}
+
+// SyncToVariableGroup writes the environment variables to a variable group of a project with one request,
+// creating the group if it does not exist. Keys listed in plain are stored as regular variables, all others as secrets.
+func (s *DevOpsService) SyncToVariableGroup(organization, project, groupName string, envVars map[string]string, plain []string, withCleanup bool) error {
+	group, err := s.getVariableGroup(organization, project, groupName)
+	if err != nil {
+		return err
+	}
+
+	if group == nil {
+		if group, err = s.newVariableGroup(organization, project, groupName); err != nil {
+			return err
+		}
+	} else if bookkeeping, ok := group.Variables[internal.OPENV_KEYS_AZURE_DEVOPS]; ok && bookkeeping.Value != nil && withCleanup {
+		for _, removed := range internal.ParseKeys(*bookkeeping.Value) {
+			if _, desired := envVars[removed]; !desired {
+				delete(group.Variables, removed)
+			}
+		}
+	}
+
+	// Secrets not synced by openv are sent back with a null value, which keeps their value
+	for key, value := range envVars {
+		isSecret := !slices.Contains(plain, key)
+		if key == internal.OPENV_KEYS {
+			key, isSecret = internal.OPENV_KEYS_AZURE_DEVOPS, false
+		}
+		group.Variables[key] = variable{Value: &value, IsSecret: isSecret}
+	}
+
+	logging.Logger.Debug("syncing to Azure DevOps variable group", "organization", organization, "project", project, "group", groupName)
+
+	if group.ID == 0 {
+		if err := s.client.Do("POST", devOpsPath(organization, "", ""), group, nil); err != nil {
+			return fmt.Errorf("failed to create Azure DevOps variable group %s: %w", groupName, err)
+		}
+		return nil
+	}
+	if err := s.client.Do("PUT", devOpsPath(organization, "", fmt.Sprintf("/%d", group.ID)), group, nil); err != nil {
+		return fmt.Errorf("failed to update Azure DevOps variable group %s: %w", groupName, err)
+	}
+	return nil
+}
+
+// PlanVariableGroup computes the changes SyncToVariableGroup would apply, secret values cannot be read
+func (s *DevOpsService) PlanVariableGroup(organization, project, groupName string, envVars map[string]string) (*plan.Plan, error) {
+	group, err := s.getVariableGroup(organization, project, groupName)
+	if err != nil {
+		return nil, err
+	}
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_AZURE_DEVOPS
+		}
+		desired[key] = value
+	}
+	current := map[string]*string{}
+	owned := []string{}
+	if group != nil {
+		for key, v := range group.Variables {
+			current[key] = v.Value
+		}
+		if bookkeeping, ok := group.Variables[internal.OPENV_KEYS_AZURE_DEVOPS]; ok && bookkeeping.Value != nil {
+			owned = internal.ParseKeys(*bookkeeping.Value)
+		}
+	}
+	return plan.Compute(fmt.Sprintf("Azure DevOps variable group %s/%s", project, groupName), desired, plan.State{Values: current, Owned: owned}), nil
+}
+
+// getVariableGroup returns the variable group named like groupName, or nil if it does not exist
+func (s *DevOpsService) getVariableGroup(organization, project, groupName string) (*variableGroup, error) {
+	if organization == "" || project == "" || groupName == "" {
+		return nil, fmt.Errorf("Azure DevOps organization, project and variable group are required")
+	}
+	response := struct {
+		Value []variableGroup `json:"value"`
+	}{}
+	path := devOpsPath(organization, project, "") + "&groupName=" + url.QueryEscape(groupName)
+	if err := s.client.Do("GET", path, nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get Azure DevOps variable group %s: %w", groupName, err)
+	}
+	for _, group := range response.Value {
+		if strings.EqualFold(group.Name, groupName) {
+			if group.Variables == nil {
+				group.Variables = map[string]variable{}
+			}
+			return &group, nil
+		}
+	}
+	return nil, nil
+}
+
+// newVariableGroup returns an empty variable group referencing the project
+func (s *DevOpsService) newVariableGroup(organization, project, groupName string) (*variableGroup, error) {
+	response := struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}{}
+	path := fmt.Sprintf("/%s/_apis/projects/%s?api-version=%s", url.PathEscape(organization), url.PathEscape(project), devOpsAPIVersion)
+	if err := s.client.Do("GET", path, nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get Azure DevOps project %s: %w", project, err)
+	}
+	reference := projectReference{Name: groupName}
+	reference.ProjectReference.ID = response.ID
+	reference.ProjectReference.Name = response.Name
+	return &variableGroup{
+		Name:                           groupName,
+		Type:                           "Vsts",
+		Variables:                      map[string]variable{},
+		VariableGroupProjectReferences: []projectReference{reference},
+	}, nil
+}
+
+// devOpsPath returns the path of the variable group API, creates and updates are addressed at the organization level
+func devOpsPath(organization, project, suffix string) string {
+	path := "/" + url.PathEscape(organization)
+	if project != "" {
+		path += "/" + url.PathEscape(project)
+	}
+	return path + "/_apis/distributedtask/variablegroups" + suffix + "?api-version=" + devOpsAPIVersion
+}
+
+// ParseDevOpsLocation splits a profile URL of the form [dev.azure.com/]<organization>/<project>[/<group>] into
+// organization, project and variable group, the group defaults to the environment name
+func ParseDevOpsLocation(location, env string) (string, string, string, error) {
+	location = strings.TrimPrefix(strings.TrimPrefix(location, "https://"), "dev.azure.com/")
+	parts := strings.SplitN(strings.Trim(location, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", fmt.Errorf("invalid Azure DevOps location %s, set the profile URL to <organization>/<project>[/<group>]", location)
+	}
+	if len(parts) == 3 && parts[2] != "" {
+		return parts[0], parts[1], parts[2], nil
+	}
+	return parts[0], parts[1], env, nil
+}
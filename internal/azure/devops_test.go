@@ -0,0 +1,278 @@
+package azure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+const (
+	projectGroupsPath = "/my-org/my-project/_apis/distributedtask/variablegroups"
+	orgGroupsPath     = "/my-org/_apis/distributedtask/variablegroups"
+)
+
+// fakeDevOps serves the variable groups of the project my-org/my-project. Like Azure DevOps,
+// it never returns secret values and keeps the value of a secret that is sent back as null.
+type fakeDevOps struct {
+	t        *testing.T
+	mu       sync.Mutex
+	groups   []variableGroup
+	requests []string
+	// sent is the group of the last create or update request
+	sent variableGroup
+}
+
+func newFakeDevOps(t *testing.T, groups ...variableGroup) (*fakeDevOps, *DevOpsService) {
+	f := &fakeDevOps{t: t, groups: groups}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, NewDevOpsService("token", server.URL)
+}
+
+func (f *fakeDevOps) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, password, ok := r.BasicAuth(); !ok || password != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Query().Get("api-version") != devOpsAPIVersion {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if r.Method != "GET" {
+		f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	}
+	var body variableGroup
+	if r.Method == "POST" || r.Method == "PUT" {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Errorf("invalid variable group: %v", err)
+		}
+		f.sent = body
+	}
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/my-org/_apis/projects/my-project":
+		writeJSON(w, http.StatusOK, map[string]string{"id": "project-id", "name": "my-project"})
+	case r.Method == "GET" && r.URL.Path == projectGroupsPath:
+		groups := []variableGroup{}
+		for _, group := range f.groups {
+			if !strings.EqualFold(group.Name, r.URL.Query().Get("groupName")) {
+				continue
+			}
+			variables := map[string]variable{}
+			for key, v := range group.Variables {
+				if v.IsSecret {
+					v.Value = nil
+				}
+				variables[key] = v
+			}
+			group.Variables = variables
+			groups = append(groups, group)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"count": len(groups), "value": groups})
+	case r.Method == "POST" && r.URL.Path == orgGroupsPath:
+		body.ID = len(f.groups) + 1
+		f.groups = append(f.groups, body)
+		writeJSON(w, http.StatusOK, body)
+	case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, orgGroupsPath+"/"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, orgGroupsPath+"/"))
+		i := slices.IndexFunc(f.groups, func(group variableGroup) bool { return group.ID == id })
+		if i < 0 {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "variable group not found"})
+			return
+		}
+		stored := body
+		stored.ID = id
+		stored.Variables = map[string]variable{}
+		for key, v := range body.Variables {
+			if current, exists := f.groups[i].Variables[key]; exists && v.IsSecret && v.Value == nil {
+				v = current
+			}
+			stored.Variables[key] = v
+		}
+		f.groups[i] = stored
+		writeJSON(w, http.StatusOK, stored)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "not found"})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestSyncToVariableGroupCreates(t *testing.T) {
+	f, service := newFakeDevOps(t)
+
+	err := service.SyncToVariableGroup("my-org", "my-project", "production", map[string]string{
+		"SECRET":            "secret",
+		"PUBLIC":            "plain",
+		internal.OPENV_KEYS: `["PUBLIC","SECRET"]`,
+	}, []string{"PUBLIC"}, true)
+	if err != nil {
+		t.Fatalf("SyncToVariableGroup() error = %v", err)
+	}
+
+	if want := []string{"POST " + orgGroupsPath}; !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %v, want %v", f.requests, want)
+	}
+	if f.sent.Name != "production" || f.sent.Type != "Vsts" {
+		t.Errorf("created group %s of type %s, want production of type Vsts", f.sent.Name, f.sent.Type)
+	}
+	if references := f.sent.VariableGroupProjectReferences; len(references) != 1 || references[0].ProjectReference.ID != "project-id" {
+		t.Errorf("project references = %+v, want a reference to project-id", references)
+	}
+	want := map[string]variable{
+		"SECRET":                         {Value: ptr("secret"), IsSecret: true},
+		"PUBLIC":                         {Value: ptr("plain")},
+		internal.OPENV_KEYS_AZURE_DEVOPS: {Value: ptr(`["PUBLIC","SECRET"]`)},
+	}
+	assertVariables(t, f.sent.Variables, want)
+}
+
+func TestSyncToVariableGroupUpdates(t *testing.T) {
+	existing := variableGroup{
+		ID:   7,
+		Name: "Production",
+		Type: "Vsts",
+		Variables: map[string]variable{
+			internal.OPENV_KEYS_AZURE_DEVOPS: {Value: ptr(`["REMOVED","SECRET"]`)},
+			"REMOVED":                        {Value: ptr("gone")},
+			"SECRET":                         {Value: ptr("old"), IsSecret: true},
+			"MANUAL_SECRET":                  {Value: ptr("manual"), IsSecret: true},
+			"MANUAL":                         {Value: ptr("keep")},
+		},
+	}
+	envVars := map[string]string{
+		"SECRET":            "new",
+		internal.OPENV_KEYS: `["SECRET"]`,
+	}
+
+	tests := []struct {
+		name        string
+		withCleanup bool
+		removed     bool
+	}{
+		{name: "cleanup", withCleanup: true, removed: true},
+		{name: "no cleanup"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, service := newFakeDevOps(t, existing)
+
+			if err := service.SyncToVariableGroup("my-org", "my-project", "production", envVars, nil, tt.withCleanup); err != nil {
+				t.Fatalf("SyncToVariableGroup() error = %v", err)
+			}
+
+			if want := []string{"PUT " + orgGroupsPath + "/7"}; !slices.Equal(f.requests, want) {
+				t.Errorf("requests = %v, want %v", f.requests, want)
+			}
+			want := map[string]variable{
+				internal.OPENV_KEYS_AZURE_DEVOPS: {Value: ptr(`["SECRET"]`)},
+				"SECRET":                         {Value: ptr("new"), IsSecret: true},
+				// Secrets not synced by openv are sent back without value, which keeps it
+				"MANUAL_SECRET": {IsSecret: true},
+				"MANUAL":        {Value: ptr("keep")},
+			}
+			if !tt.removed {
+				want["REMOVED"] = variable{Value: ptr("gone")}
+			}
+			assertVariables(t, f.sent.Variables, want)
+			if got := f.groups[0].Variables["MANUAL_SECRET"]; got.Value == nil || *got.Value != "manual" {
+				t.Errorf("MANUAL_SECRET = %+v, want its value kept", got)
+			}
+		})
+	}
+}
+
+func TestPlanVariableGroup(t *testing.T) {
+	_, service := newFakeDevOps(t, variableGroup{
+		ID:   7,
+		Name: "production",
+		Variables: map[string]variable{
+			internal.OPENV_KEYS_AZURE_DEVOPS: {Value: ptr(`["PUBLIC","REMOVED","SECRET"]`)},
+			"PUBLIC":                         {Value: ptr("plain")},
+			"REMOVED":                        {Value: ptr("gone")},
+			"SECRET":                         {Value: ptr("secret"), IsSecret: true},
+			"MANUAL":                         {Value: ptr("keep")},
+		},
+	})
+
+	p, err := service.PlanVariableGroup("my-org", "my-project", "production", map[string]string{"PUBLIC": "plain", "SECRET": "secret"})
+	if err != nil {
+		t.Fatalf("PlanVariableGroup() error = %v", err)
+	}
+	want := []plan.Change{
+		{Key: "PUBLIC", Action: plan.ActionUnchanged},
+		{Key: "REMOVED", Action: plan.ActionDelete},
+		{Key: "SECRET", Action: plan.ActionUpdate, Reason: "current value cannot be read"},
+	}
+	if !slices.Equal(p.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", p.Changes, want)
+	}
+
+	p, err = service.PlanVariableGroup("my-org", "my-project", "staging", map[string]string{"KEY": "value"})
+	if err != nil {
+		t.Fatalf("PlanVariableGroup() error = %v", err)
+	}
+	if want := []plan.Change{{Key: "KEY", Action: plan.ActionCreate}}; !slices.Equal(p.Changes, want) {
+		t.Errorf("changes of a missing group = %+v, want %+v", p.Changes, want)
+	}
+}
+
+func TestParseDevOpsLocation(t *testing.T) {
+	tests := []struct {
+		location string
+		want     []string
+	}{
+		{"my-org/my-project", []string{"my-org", "my-project", "production"}},
+		{"dev.azure.com/my-org/my-project/", []string{"my-org", "my-project", "production"}},
+		{"https://dev.azure.com/my-org/my-project/shared", []string{"my-org", "my-project", "shared"}},
+		{"my-org/my-project/group/with/slashes", []string{"my-org", "my-project", "group/with/slashes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			organization, project, group, err := ParseDevOpsLocation(tt.location, "production")
+			if err != nil {
+				t.Fatalf("ParseDevOpsLocation() error = %v", err)
+			}
+			if got := []string{organization, project, group}; !slices.Equal(got, tt.want) {
+				t.Errorf("ParseDevOpsLocation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	for _, location := range []string{"my-org", "dev.azure.com/my-org", "/my-project"} {
+		if _, _, _, err := ParseDevOpsLocation(location, "production"); err == nil {
+			t.Errorf("ParseDevOpsLocation(%s) error = nil, want invalid location", location)
+		}
+	}
+}
+
+// assertVariables compares variables by value and secret flag
+func assertVariables(t *testing.T, got, want map[string]variable) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("variables = %v, want %v", got, want)
+	}
+	for key, w := range want {
+		g, exists := got[key]
+		switch {
+		case !exists:
+			t.Errorf("variable %s is missing", key)
+		case g.IsSecret != w.IsSecret:
+			t.Errorf("%s isSecret = %t, want %t", key, g.IsSecret, w.IsSecret)
+		case (g.Value == nil) != (w.Value == nil) || (g.Value != nil && *g.Value != *w.Value):
+			t.Errorf("%s value = %v, want %v", key, g.Value, w.Value)
+		}
+	}
+}
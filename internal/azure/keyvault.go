@@ -0,0 +1,265 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+const keyVaultAPIVersion = "7.4"
+
+const (
+	// recoverAttempts is how often a recovered secret is polled before it is set
+	recoverAttempts = 10
+	// recoverPollInterval is the time between two polls of a recovered secret
+	recoverPollInterval = time.Second
+)
+
+// invalidSecretName matches the characters Key Vault does not allow in secret names
+var invalidSecretName = regexp.MustCompile(`[^0-9a-zA-Z-]`)
+
+// bookkeepingSecret is the secret holding the variable names synced by openv
+var bookkeepingSecret = SecretName(internal.OPENV_KEYS_KEY_VAULT)
+
+// KeyVaultService handles syncing environment variables to Azure Key Vault secrets
+type KeyVaultService struct {
+	client *rest.Client
+	ctx    context.Context
+	vault  string
+}
+
+// NewKeyVaultService creates a new KeyVaultService for a vault given by name or URL, authenticating with an access token
+func NewKeyVaultService(vault, accessToken string) *KeyVaultService {
+	ctx := context.Background()
+	baseURL := vault
+	if !strings.Contains(vault, "://") {
+		baseURL = "https://" + vault + ".vault.azure.net"
+	}
+	client := rest.NewClient(ctx, baseURL, map[string]string{
+		"Authorization": "Bearer " + accessToken,
+	})
+	return &KeyVaultService{client: client, ctx: ctx, vault: vault}
+}
+
+// SecretName normalizes a variable name to a Key Vault secret name, which only allows alphanumerics and dashes,
+// e.g. DATABASE_URL becomes DATABASE-URL
+func SecretName(key string) string {
+	return invalidSecretName.ReplaceAllString(key, "-")
+}
+
+// SyncToVault writes each variable as a secret with the normalized name. The variable names are recorded
+// in the OPENV-KEYS-KEY-VAULT secret, secrets of removed names are deleted.
+// Secret names are case-insensitive, a secret deleted by an earlier sync is recovered before it is set again.
+func (s *KeyVaultService) SyncToVault(envVars map[string]string, withCleanup bool) error {
+	desired, err := secretValues(envVars)
+	if err != nil {
+		return err
+	}
+	existing, err := s.listSecrets()
+	if err != nil {
+		return err
+	}
+
+	logging.Logger.Debug("syncing to Azure Key Vault", "vault", s.vault)
+
+	if stored, exists := existing[strings.ToLower(bookkeepingSecret)]; withCleanup && exists {
+		bookkeeping, err := s.getSecret(stored)
+		if err != nil {
+			return err
+		}
+		desiredNames := nameIndex(slices.Collect(maps.Keys(desired)))
+		for _, removed := range internal.ParseKeys(bookkeeping) {
+			name := strings.ToLower(SecretName(removed))
+			stored, exists := existing[name]
+			if _, kept := desiredNames[name]; kept || !exists {
+				continue
+			}
+			// Key Vault soft deletes secrets, setting the name again recovers the secret
+			if err := s.client.Do("DELETE", secretPath(stored), nil, nil); err != nil {
+				logging.Logger.Warn("failed to delete Key Vault secret", "name", stored, "error", err)
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(desired)) {
+		// Every update creates a new version, so unchanged secrets are skipped
+		if stored, exists := existing[strings.ToLower(name)]; exists {
+			current, err := s.getSecret(stored)
+			if err != nil {
+				return err
+			}
+			if current == desired[name] {
+				continue
+			}
+		}
+		if err := s.setSecret(name, desired[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PlanVault computes the changes SyncToVault would apply, listed by secret name
+func (s *KeyVaultService) PlanVault(envVars map[string]string) (*plan.Plan, error) {
+	desired, err := secretValues(envVars)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.listSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	// Names are case-insensitive, so existing secrets are listed under the name of the matching variable
+	desiredNames := nameIndex(slices.Collect(maps.Keys(desired)))
+	planName := func(name string) string {
+		if desiredName, ok := desiredNames[strings.ToLower(name)]; ok {
+			return desiredName
+		}
+		if stored, ok := existing[strings.ToLower(name)]; ok {
+			return stored
+		}
+		return name
+	}
+
+	current := map[string]*string{}
+	for lower, stored := range existing {
+		name := planName(stored)
+		current[name] = nil
+		if _, synced := desiredNames[lower]; !synced && lower != strings.ToLower(bookkeepingSecret) {
+			continue
+		}
+		value, err := s.getSecret(stored)
+		if err != nil {
+			return nil, err
+		}
+		current[name] = &value
+	}
+	owned := []string{}
+	if value := current[planName(bookkeepingSecret)]; value != nil {
+		for _, key := range internal.ParseKeys(*value) {
+			owned = append(owned, planName(SecretName(key)))
+		}
+	}
+	return plan.Compute(fmt.Sprintf("Azure Key Vault %s", s.vault), desired, plan.State{Values: current, Owned: owned}), nil
+}
+
+// secretValues returns the values by secret name, names that normalize to the same secret are rejected
+func secretValues(envVars map[string]string) (map[string]string, error) {
+	values := map[string]string{}
+	keys := map[string]string{}
+	for _, key := range slices.Sorted(maps.Keys(envVars)) {
+		name := SecretName(key)
+		if key == internal.OPENV_KEYS {
+			name = bookkeepingSecret
+		}
+		if other, exists := keys[strings.ToLower(name)]; exists {
+			return nil, fmt.Errorf("variables %s and %s both map to Key Vault secret %s", other, key, name)
+		}
+		keys[strings.ToLower(name)] = key
+		values[name] = envVars[key]
+	}
+	return values, nil
+}
+
+// nameIndex maps the lower case secret names to the names
+func nameIndex(names []string) map[string]string {
+	index := make(map[string]string, len(names))
+	for _, name := range names {
+		index[strings.ToLower(name)] = name
+	}
+	return index
+}
+
+// setSecret sets the value of a secret. A soft-deleted secret of the same name is recovered first,
+// Key Vault rejects setting it until then.
+func (s *KeyVaultService) setSecret(name, value string) error {
+	err := s.client.Do("PUT", secretPath(name), map[string]string{"value": value}, nil)
+	if isDeletedButRecoverable(err) {
+		logging.Logger.Debug("recovering deleted Key Vault secret", "name", name)
+		if err := s.recoverSecret(name); err != nil {
+			return err
+		}
+		err = s.client.Do("PUT", secretPath(name), map[string]string{"value": value}, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set Key Vault secret %s: %w", name, err)
+	}
+	return nil
+}
+
+// recoverSecret recovers a soft-deleted secret and waits until it can be read, recovery completes asynchronously
+func (s *KeyVaultService) recoverSecret(name string) error {
+	path := "/deletedsecrets/" + url.PathEscape(name) + "/recover?api-version=" + keyVaultAPIVersion
+	if err := s.client.Do("POST", path, nil, nil); err != nil {
+		return fmt.Errorf("failed to recover deleted Key Vault secret %s: %w", name, err)
+	}
+	for attempt := 1; ; attempt++ {
+		err := s.client.Do("GET", secretPath(name), nil, nil)
+		if !rest.IsNotFound(err) {
+			return nil
+		}
+		if attempt == recoverAttempts {
+			return fmt.Errorf("Key Vault secret %s was not recovered in time", name)
+		}
+		time.Sleep(recoverPollInterval)
+	}
+}
+
+// isDeletedButRecoverable reports whether err is the conflict returned for a name of a soft-deleted secret
+func isDeletedButRecoverable(err error) bool {
+	var statusErr *rest.StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict &&
+		strings.Contains(statusErr.Body, "ObjectIsDeletedButRecoverable")
+}
+
+// listSecrets returns the names of the secrets in the vault by their lower case name
+func (s *KeyVaultService) listSecrets() (map[string]string, error) {
+	if s.vault == "" {
+		return nil, fmt.Errorf("no Azure Key Vault configured, set the profile URL to the vault name")
+	}
+	names := []string{}
+	path := "/secrets?api-version=" + keyVaultAPIVersion
+	for path != "" {
+		page := struct {
+			Value []struct {
+				ID string `json:"id"`
+			} `json:"value"`
+			NextLink string `json:"nextLink"`
+		}{}
+		if err := s.client.Do("GET", path, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list Key Vault secrets: %w", err)
+		}
+		for _, item := range page.Value {
+			names = append(names, item.ID[strings.LastIndex(item.ID, "/")+1:])
+		}
+		path = strings.TrimPrefix(page.NextLink, s.client.BaseURL())
+	}
+	return nameIndex(names), nil
+}
+
+func (s *KeyVaultService) getSecret(name string) (string, error) {
+	secret := struct {
+		Value string `json:"value"`
+	}{}
+	if err := s.client.Do("GET", secretPath(name), nil, &secret); err != nil {
+		return "", fmt.Errorf("failed to get Key Vault secret %s: %w", name, err)
+	}
+	return secret.Value, nil
+}
+
+func secretPath(name string) string {
+	return "/secrets/" + url.PathEscape(name) + "?api-version=" + keyVaultAPIVersion
+}
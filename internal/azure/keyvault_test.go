@@ -0,0 +1,275 @@
+package azure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// fakeKeyVault serves the secret endpoints of a vault with case-insensitive names and soft delete,
+// listing one secret per page
+type fakeKeyVault struct {
+	t        *testing.T
+	mu       sync.Mutex
+	server   *httptest.Server
+	secrets  map[string]storedSecret
+	deleted  map[string]storedSecret
+	requests []string
+}
+
+type storedSecret struct {
+	name  string
+	value string
+}
+
+func newFakeKeyVault(t *testing.T, secrets map[string]string) (*fakeKeyVault, *KeyVaultService) {
+	f := &fakeKeyVault{t: t, secrets: map[string]storedSecret{}, deleted: map[string]storedSecret{}}
+	for name, value := range secrets {
+		f.secrets[strings.ToLower(name)] = storedSecret{name: name, value: value}
+	}
+	f.server = httptest.NewServer(f)
+	t.Cleanup(f.server.Close)
+	return f, NewKeyVaultService(f.server.URL, "token")
+}
+
+func (f *fakeKeyVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Query().Get("api-version") != keyVaultAPIVersion {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Path == "/secrets" {
+		names := []string{}
+		for _, secret := range f.secrets {
+			names = append(names, secret.name)
+		}
+		slices.Sort(names)
+		index := 0
+		if skip := r.URL.Query().Get("skip"); skip != "" {
+			index = slices.Index(names, skip)
+		}
+		page := map[string]any{"value": []any{}}
+		if index >= 0 && index < len(names) {
+			page["value"] = []any{map[string]string{"id": f.server.URL + "/secrets/" + names[index]}}
+			if index+1 < len(names) {
+				page["nextLink"] = f.server.URL + "/secrets?api-version=" + keyVaultAPIVersion + "&skip=" + names[index+1]
+			}
+		}
+		writeJSON(w, http.StatusOK, page)
+		return
+	}
+
+	if name, ok := strings.CutPrefix(r.URL.Path, "/deletedsecrets/"); ok && r.Method == "POST" {
+		name = strings.TrimSuffix(name, "/recover")
+		secret, deleted := f.deleted[strings.ToLower(name)]
+		if !deleted {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": map[string]string{"code": "SecretNotFound"}})
+			return
+		}
+		delete(f.deleted, strings.ToLower(name))
+		f.secrets[strings.ToLower(name)] = secret
+		writeJSON(w, http.StatusOK, map[string]string{"id": f.server.URL + "/secrets/" + secret.name})
+		return
+	}
+
+	name, ok := strings.CutPrefix(r.URL.Path, "/secrets/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	lower := strings.ToLower(name)
+	secret, exists := f.secrets[lower]
+	switch r.Method {
+	case "GET":
+		if !exists {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": map[string]string{"code": "SecretNotFound"}})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"value": secret.value})
+	case "PUT":
+		if _, deleted := f.deleted[lower]; deleted {
+			writeJSON(w, http.StatusConflict, map[string]any{"error": map[string]any{
+				"code":       "Conflict",
+				"message":    "Secret " + name + " is currently in a deleted but recoverable state",
+				"innererror": map[string]string{"code": "ObjectIsDeletedButRecoverable"},
+			}})
+			return
+		}
+		var body struct {
+			Value string `json:"value"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Errorf("invalid set body: %v", err)
+		}
+		if !exists {
+			secret.name = name
+		}
+		secret.value = body.Value
+		f.secrets[lower] = secret
+		writeJSON(w, http.StatusOK, map[string]string{"value": body.Value})
+	case "DELETE":
+		if !exists {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": map[string]string{"code": "SecretNotFound"}})
+			return
+		}
+		delete(f.secrets, lower)
+		f.deleted[lower] = secret
+		writeJSON(w, http.StatusOK, map[string]string{})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeKeyVault) value(name string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secret, ok := f.secrets[strings.ToLower(name)]
+	return secret.value, ok
+}
+
+func TestSyncToVault(t *testing.T) {
+	f, service := newFakeKeyVault(t, map[string]string{
+		"UNCHANGED": "same",
+		"UPDATED":   "old",
+		"UNMANAGED": "keep",
+	})
+
+	err := service.SyncToVault(map[string]string{
+		"UNCHANGED":         "same",
+		"UPDATED":           "new",
+		"DATABASE_URL":      "postgres://",
+		internal.OPENV_KEYS: `["DATABASE_URL","UNCHANGED","UPDATED"]`,
+	}, true)
+	if err != nil {
+		t.Fatalf("SyncToVault() error = %v", err)
+	}
+
+	if value, _ := f.value("DATABASE-URL"); value != "postgres://" {
+		t.Errorf("DATABASE-URL = %q, want the value of DATABASE_URL", value)
+	}
+	if value, _ := f.value("UPDATED"); value != "new" {
+		t.Errorf("UPDATED = %q, want new", value)
+	}
+	if value, _ := f.value(bookkeepingSecret); value != `["DATABASE_URL","UNCHANGED","UPDATED"]` {
+		t.Errorf("%s = %q, want the synced keys", bookkeepingSecret, value)
+	}
+	if slices.Contains(f.requests, "PUT /secrets/UNCHANGED") {
+		t.Error("UNCHANGED was set although its value did not change")
+	}
+}
+
+func TestSyncToVaultRecoversDeletedSecret(t *testing.T) {
+	f, service := newFakeKeyVault(t, map[string]string{
+		"KEPT":            "1",
+		"READDED":         "old",
+		bookkeepingSecret: `["KEPT","READDED"]`,
+	})
+
+	if err := service.SyncToVault(map[string]string{"KEPT": "1", internal.OPENV_KEYS: `["KEPT"]`}, true); err != nil {
+		t.Fatalf("SyncToVault() error = %v", err)
+	}
+	if _, ok := f.value("READDED"); ok {
+		t.Fatal("READDED was not deleted")
+	}
+
+	if err := service.SyncToVault(map[string]string{"KEPT": "1", "READDED": "new", internal.OPENV_KEYS: `["KEPT","READDED"]`}, true); err != nil {
+		t.Fatalf("SyncToVault() error = %v", err)
+	}
+	if value, _ := f.value("READDED"); value != "new" {
+		t.Errorf("READDED = %q, want the recovered secret set to new", value)
+	}
+	if !slices.Contains(f.requests, "POST /deletedsecrets/READDED/recover") {
+		t.Errorf("requests = %v, want the deleted secret recovered", f.requests)
+	}
+}
+
+func TestSyncToVaultIgnoresNameCase(t *testing.T) {
+	f, service := newFakeKeyVault(t, map[string]string{
+		"api-key":         "old",
+		bookkeepingSecret: `["api_key"]`,
+	})
+
+	if err := service.SyncToVault(map[string]string{"API_KEY": "new", internal.OPENV_KEYS: `["API_KEY"]`}, true); err != nil {
+		t.Fatalf("SyncToVault() error = %v", err)
+	}
+	if value, ok := f.value("API-KEY"); !ok || value != "new" {
+		t.Errorf("API-KEY = %q, %v, want the secret stored as api-key updated, not deleted", value, ok)
+	}
+	for _, request := range f.requests {
+		if strings.HasPrefix(request, "DELETE") {
+			t.Errorf("unexpected request %s", request)
+		}
+	}
+}
+
+func TestSecretValuesRejectsCollisions(t *testing.T) {
+	for _, envVars := range []map[string]string{
+		{"DB_URL": "a", "DB-URL": "b"},
+		{"db_url": "a", "DB_URL": "b"},
+	} {
+		if _, err := secretValues(envVars); err == nil {
+			t.Errorf("secretValues(%v) error = nil, want a collision error", envVars)
+		}
+	}
+}
+
+func TestPlanVault(t *testing.T) {
+	_, service := newFakeKeyVault(t, map[string]string{
+		"same":            "value",
+		"REMOVED":         "gone",
+		"UNMANAGED":       "keep",
+		bookkeepingSecret: `["SAME","REMOVED"]`,
+	})
+
+	p, err := service.PlanVault(map[string]string{
+		"SAME":              "value",
+		"ADDED":             "new",
+		internal.OPENV_KEYS: `["ADDED","SAME"]`,
+	})
+	if err != nil {
+		t.Fatalf("PlanVault() error = %v", err)
+	}
+
+	actions := map[string]plan.Action{}
+	for _, change := range p.Changes {
+		actions[change.Key] = change.Action
+	}
+	want := map[string]plan.Action{
+		"SAME":            plan.ActionUnchanged,
+		"ADDED":           plan.ActionCreate,
+		"REMOVED":         plan.ActionDelete,
+		bookkeepingSecret: plan.ActionUpdate,
+	}
+	for key, action := range want {
+		if actions[key] != action {
+			t.Errorf("action of %s = %v, want %v", key, actions[key], action)
+		}
+	}
+	if _, ok := actions["UNMANAGED"]; ok {
+		t.Error("plan includes UNMANAGED, which openv does not own")
+	}
+	if _, ok := actions["same"]; ok {
+		t.Error("plan lists same apart from SAME, names are case-insensitive")
+	}
+}
@@ -0,0 +1,243 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// DefaultBaseURL is the base URL of the Bitbucket Cloud API
+const DefaultBaseURL = "https://api.bitbucket.org/2.0"
+
+const pageSize = 100
+
+type variable struct {
+	UUID    string `json:"uuid,omitempty"`
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Secured bool   `json:"secured"`
+}
+
+type environment struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type page[T any] struct {
+	Values []T    `json:"values"`
+	Next   string `json:"next"`
+}
+
+// BitbucketService handles syncing environment variables to Bitbucket Pipelines variables
+type BitbucketService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewBitbucketService creates a new BitbucketService, an empty baseURL uses DefaultBaseURL.
+// A token of the form username:app-password authenticates with an app password, any other token as access token.
+func NewBitbucketService(token, baseURL string) *BitbucketService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	authorization := "Bearer " + token
+	if strings.Contains(token, ":") {
+		authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(token))
+	}
+	client := rest.NewClient(ctx, baseURL, map[string]string{
+		"Authorization": authorization,
+	})
+	return &BitbucketService{client: client, ctx: ctx}
+}
+
+// SyncToRepoVariable syncs environment variables to the Pipelines repository variables.
+// Keys listed in plain are stored unsecured, all others secured.
+func (s *BitbucketService) SyncToRepoVariable(workspace, repo string, envVars map[string]string, plain []string, withCleanup bool) error {
+	return s.syncVariables(repoPath(workspace, repo, "/pipelines_config/variables"), envVars, plain, withCleanup)
+}
+
+// SyncToDeploymentVariable syncs environment variables to the variables of the deployment environment matching env
+func (s *BitbucketService) SyncToDeploymentVariable(workspace, repo, env string, envVars map[string]string, plain []string, withCleanup bool) error {
+	path, err := s.deploymentPath(workspace, repo, env)
+	if err != nil {
+		return err
+	}
+	return s.syncVariables(path, envVars, plain, withCleanup)
+}
+
+// PlanRepoVariable computes the changes SyncToRepoVariable would apply
+func (s *BitbucketService) PlanRepoVariable(workspace, repo string, envVars map[string]string) (*plan.Plan, error) {
+	return s.planVariables(fmt.Sprintf("Bitbucket repository variables of %s/%s", workspace, repo), repoPath(workspace, repo, "/pipelines_config/variables"), envVars)
+}
+
+// PlanDeploymentVariable computes the changes SyncToDeploymentVariable would apply
+func (s *BitbucketService) PlanDeploymentVariable(workspace, repo, env string, envVars map[string]string) (*plan.Plan, error) {
+	path, err := s.deploymentPath(workspace, repo, env)
+	if err != nil {
+		return nil, err
+	}
+	return s.planVariables(fmt.Sprintf("Bitbucket deployment variables of %s/%s (%s)", workspace, repo, env), path, envVars)
+}
+
+func (s *BitbucketService) syncVariables(path string, envVars map[string]string, plain []string, withCleanup bool) error {
+	existing, err := s.listVariables(path)
+	if err != nil {
+		return err
+	}
+
+	logging.Logger.Debug("syncing to Bitbucket variables", "path", path)
+
+	if withCleanup {
+		s.cleanupVariables(path, existing, envVars)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(envVars)) {
+		desired := variable{Key: key, Value: envVars[key], Secured: !slices.Contains(plain, key)}
+		if key == internal.OPENV_KEYS {
+			desired = variable{Key: internal.OPENV_KEYS_BITBUCKET, Value: envVars[key]}
+		}
+
+		current, exists := existing[desired.Key]
+		switch {
+		case exists && current.Secured && !desired.Secured:
+			// Secured variables cannot be unsecured, replace them instead
+			if err := s.client.Do("DELETE", variablePath(path, current.UUID), nil, nil); err != nil {
+				return fmt.Errorf("failed to replace Bitbucket variable %s: %w", desired.Key, err)
+			}
+		case exists:
+			// Secured values cannot be read and are always updated
+			if !current.Secured && !desired.Secured && current.Value == desired.Value {
+				continue
+			}
+			desired.UUID = current.UUID
+			if err := s.client.Do("PUT", variablePath(path, current.UUID), desired, nil); err != nil {
+				return fmt.Errorf("failed to update Bitbucket variable %s: %w", desired.Key, err)
+			}
+			continue
+		}
+
+		if err := s.client.Do("POST", path, desired, nil); err != nil {
+			return fmt.Errorf("failed to create Bitbucket variable %s: %w", desired.Key, err)
+		}
+	}
+	return nil
+}
+
+func (s *BitbucketService) planVariables(target, path string, envVars map[string]string) (*plan.Plan, error) {
+	existing, err := s.listVariables(path)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_BITBUCKET
+		}
+		desired[key] = value
+	}
+
+	current := map[string]*string{}
+	for key, v := range existing {
+		current[key] = nil
+		if !v.Secured {
+			current[key] = &v.Value
+		}
+	}
+
+	owned := []string{}
+	if bookkeeping, ok := existing[internal.OPENV_KEYS_BITBUCKET]; ok {
+		owned = internal.ParseKeys(bookkeeping.Value)
+	}
+	return plan.Compute(target, desired, plan.State{Values: current, Owned: owned}), nil
+}
+
+// cleanupVariables deletes variables that were synced before but are no longer present in envVars
+func (s *BitbucketService) cleanupVariables(path string, existing map[string]variable, envVars map[string]string) {
+	bookkeeping, ok := existing[internal.OPENV_KEYS_BITBUCKET]
+	if !ok {
+		return
+	}
+	for _, removed := range internal.ParseKeys(bookkeeping.Value) {
+		if _, exists := envVars[removed]; exists {
+			continue
+		}
+		current, exists := existing[removed]
+		if !exists {
+			continue
+		}
+		if err := s.client.Do("DELETE", variablePath(path, current.UUID), nil, nil); err != nil {
+			logging.Logger.Warn("failed to delete Bitbucket variable", "key", removed, "error", err)
+		}
+	}
+}
+
+// deploymentPath returns the variables path of the deployment environment with the name or slug env
+func (s *BitbucketService) deploymentPath(workspace, repo, env string) (string, error) {
+	environments := []environment{}
+	if err := paginate(s, repoPath(workspace, repo, "/environments"), func(p *page[environment]) {
+		environments = append(environments, p.Values...)
+	}); err != nil {
+		return "", fmt.Errorf("failed to list Bitbucket deployment environments: %w", err)
+	}
+	for _, candidate := range environments {
+		if strings.EqualFold(candidate.Name, env) || candidate.Slug == env {
+			return repoPath(workspace, repo, "/deployments_config/environments/"+url.PathEscape(candidate.UUID)+"/variables"), nil
+		}
+	}
+	return "", fmt.Errorf("Bitbucket deployment environment %s not found in %s/%s", env, workspace, repo)
+}
+
+func (s *BitbucketService) listVariables(path string) (map[string]variable, error) {
+	existing := map[string]variable{}
+	if err := paginate(s, path, func(p *page[variable]) {
+		for _, v := range p.Values {
+			existing[v.Key] = v
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list Bitbucket variables: %w", err)
+	}
+	return existing, nil
+}
+
+// paginate follows the next links of a paginated response
+func paginate[T any](s *BitbucketService, path string, handle func(*page[T])) error {
+	next := fmt.Sprintf("%s?pagelen=%d", path, pageSize)
+	for next != "" {
+		p := &page[T]{}
+		if err := s.client.Do("GET", next, nil, p); err != nil {
+			return err
+		}
+		handle(p)
+		next = strings.TrimPrefix(p.Next, s.client.BaseURL())
+	}
+	return nil
+}
+
+func repoPath(workspace, repo, suffix string) string {
+	return fmt.Sprintf("/repositories/%s/%s%s", url.PathEscape(workspace), url.PathEscape(repo), suffix)
+}
+
+func variablePath(path, uuid string) string {
+	return path + "/" + url.PathEscape(uuid)
+}
+
+// DeriveLocationFromURL returns the workspace and repository of a URL like bitbucket.org/workspace/repo
+func DeriveLocationFromURL(url string) (string, string, error) {
+	parts := strings.Split(strings.TrimSuffix(strings.Trim(url, "/"), ".git"), "/")
+	if len(parts) < 2 {
+		return "", "", fmt.Errorf("invalid Bitbucket URL: %s", url)
+	}
+	return parts[len(parts)-2], parts[len(parts)-1], nil
+}
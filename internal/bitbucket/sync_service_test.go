@@ -0,0 +1,284 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+const (
+	repoVariablesPath = "/repositories/my-workspace/my-repo/pipelines_config/variables"
+	environmentsPath  = "/repositories/my-workspace/my-repo/environments"
+	// fakePageSize is smaller than pageSize, so listing follows the next links
+	fakePageSize = 2
+)
+
+// fakeBitbucket serves the Pipelines variables and deployment environments of one repository
+type fakeBitbucket struct {
+	t   *testing.T
+	url string
+	mu  sync.Mutex
+	// variables holds the variables by the path of their collection
+	variables    map[string][]variable
+	environments []environment
+	// requests lists the requests changing variables
+	requests []string
+	lastUUID int
+}
+
+func newFakeBitbucket(t *testing.T, environments []environment, variables map[string][]variable) (*fakeBitbucket, *BitbucketService) {
+	f := &fakeBitbucket{t: t, variables: variables, environments: environments}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	f.url = server.URL
+	return f, NewBitbucketService("user:app-password", server.URL)
+}
+
+func (f *fakeBitbucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "app-password" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.Method == "GET" && r.URL.Path == environmentsPath {
+		writePage(w, r, f.url, f.environments)
+		return
+	}
+
+	collection, uuid := r.URL.Path, ""
+	if _, exists := f.variables[collection]; !exists {
+		collection = r.URL.Path[:strings.LastIndex(r.URL.Path, "/")]
+		uuid = r.URL.Path[len(collection)+1:]
+	}
+	variables, exists := f.variables[collection]
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method != "GET" {
+		f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	}
+	matches := func(v variable) bool { return v.UUID == uuid }
+
+	switch {
+	case r.Method == "GET" && uuid == "":
+		listed := []variable{}
+		for _, v := range variables {
+			if v.Secured {
+				v.Value = ""
+			}
+			listed = append(listed, v)
+		}
+		writePage(w, r, f.url, listed)
+	case r.Method == "POST" && uuid == "":
+		var created variable
+		if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+			f.t.Errorf("invalid create body: %v", err)
+		}
+		if slices.ContainsFunc(variables, func(v variable) bool { return v.Key == created.Key }) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.lastUUID++
+		created.UUID = fmt.Sprintf("{new-%d}", f.lastUUID)
+		f.variables[collection] = append(variables, created)
+		writeJSON(w, created)
+	case r.Method == "PUT":
+		var updated variable
+		if err := json.NewDecoder(r.Body).Decode(&updated); err != nil {
+			f.t.Errorf("invalid update body: %v", err)
+		}
+		i := slices.IndexFunc(variables, matches)
+		if i < 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if variables[i].Secured && !updated.Secured {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		variables[i] = updated
+		writeJSON(w, updated)
+	case r.Method == "DELETE":
+		if !slices.ContainsFunc(variables, matches) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f.variables[collection] = slices.DeleteFunc(variables, matches)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// writePage writes the requested page of values, linking the next page with an absolute URL like Bitbucket
+func writePage[T any](w http.ResponseWriter, r *http.Request, baseURL string, values []T) {
+	number := 1
+	if r.URL.Query().Has("page") {
+		number, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	}
+	start := min((number-1)*fakePageSize, len(values))
+	end := min(start+fakePageSize, len(values))
+	p := page[T]{Values: values[start:end]}
+	if end < len(values) {
+		p.Next = fmt.Sprintf("%s%s?pagelen=%d&page=%d", baseURL, r.URL.Path, fakePageSize, number+1)
+	}
+	writeJSON(w, p)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// find returns the variable with the key in the collection
+func (f *fakeBitbucket) find(collection, key string) (variable, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := slices.IndexFunc(f.variables[collection], func(v variable) bool { return v.Key == key })
+	if i < 0 {
+		return variable{}, false
+	}
+	return f.variables[collection][i], true
+}
+
+func TestSyncToRepoVariable(t *testing.T) {
+	f, service := newFakeBitbucket(t, nil, map[string][]variable{
+		repoVariablesPath: {
+			{UUID: "{1}", Key: "NOW_PLAIN", Value: "secret", Secured: true},
+			{UUID: "{2}", Key: "SECRET", Value: "secret", Secured: true},
+			{UUID: "{3}", Key: "UNCHANGED", Value: "same"},
+			{UUID: "{4}", Key: "UPDATED", Value: "old"},
+			{UUID: "{5}", Key: internal.OPENV_KEYS_BITBUCKET, Value: `["NOW_PLAIN","REMOVED","SECRET","UNCHANGED","UPDATED"]`},
+			// On the last page, so the cleanup has to follow the next links to find it
+			{UUID: "{6}", Key: "REMOVED", Value: "gone"},
+		},
+	})
+
+	err := service.SyncToRepoVariable("my-workspace", "my-repo", map[string]string{
+		"NOW_PLAIN":         "public",
+		"SECRET":            "secret",
+		"UNCHANGED":         "same",
+		"UPDATED":           "new",
+		internal.OPENV_KEYS: `["NOW_PLAIN","SECRET","UNCHANGED","UPDATED"]`,
+	}, []string{"NOW_PLAIN", "UNCHANGED", "UPDATED"}, true)
+	if err != nil {
+		t.Fatalf("SyncToRepoVariable() error = %v", err)
+	}
+
+	want := []string{
+		"DELETE " + repoVariablesPath + "/{6}",
+		"DELETE " + repoVariablesPath + "/{1}",
+		"POST " + repoVariablesPath,
+		"PUT " + repoVariablesPath + "/{5}",
+		"PUT " + repoVariablesPath + "/{2}",
+		"PUT " + repoVariablesPath + "/{4}",
+	}
+	if !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %v, want %v", f.requests, want)
+	}
+	if v, _ := f.find(repoVariablesPath, "NOW_PLAIN"); v.Secured || v.Value != "public" {
+		t.Errorf("NOW_PLAIN = %+v, want it re-created unsecured", v)
+	}
+	if v, _ := f.find(repoVariablesPath, "SECRET"); !v.Secured {
+		t.Errorf("SECRET = %+v, want it secured", v)
+	}
+	if v, _ := f.find(repoVariablesPath, internal.OPENV_KEYS_BITBUCKET); v.Secured {
+		t.Errorf("bookkeeping variable = %+v, want it unsecured", v)
+	}
+	if _, exists := f.find(repoVariablesPath, "REMOVED"); exists {
+		t.Error("REMOVED was not deleted")
+	}
+}
+
+func TestSyncToDeploymentVariable(t *testing.T) {
+	environments := []environment{
+		{UUID: "{env-1}", Name: "Test", Slug: "test"},
+		{UUID: "{env-2}", Name: "Staging", Slug: "staging"},
+		{UUID: "{env-3}", Name: "Production", Slug: "production"},
+	}
+	productionPath := "/repositories/my-workspace/my-repo/deployments_config/environments/{env-3}/variables"
+	stagingPath := "/repositories/my-workspace/my-repo/deployments_config/environments/{env-2}/variables"
+
+	tests := []struct {
+		env  string
+		path string
+	}{
+		// On the second page of environments
+		{"production", productionPath},
+		{"PRODUCTION", productionPath},
+		{"Staging", stagingPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			f, service := newFakeBitbucket(t, environments, map[string][]variable{productionPath: {}, stagingPath: {}})
+
+			if err := service.SyncToDeploymentVariable("my-workspace", "my-repo", tt.env, map[string]string{"KEY": "value"}, nil, true); err != nil {
+				t.Fatalf("SyncToDeploymentVariable() error = %v", err)
+			}
+			if want := []string{"POST " + tt.path}; !slices.Equal(f.requests, want) {
+				t.Errorf("requests = %v, want %v", f.requests, want)
+			}
+		})
+	}
+
+	t.Run("unknown environment", func(t *testing.T) {
+		_, service := newFakeBitbucket(t, environments, map[string][]variable{})
+		if err := service.SyncToDeploymentVariable("my-workspace", "my-repo", "dev", map[string]string{"KEY": "value"}, nil, true); err == nil {
+			t.Error("SyncToDeploymentVariable() error = nil, want environment not found")
+		}
+	})
+}
+
+func TestPlanRepoVariable(t *testing.T) {
+	_, service := newFakeBitbucket(t, nil, map[string][]variable{
+		repoVariablesPath: {
+			{UUID: "{1}", Key: internal.OPENV_KEYS_BITBUCKET, Value: `["REMOVED","SECRET","UNCHANGED"]`},
+			{UUID: "{2}", Key: "SECRET", Value: "secret", Secured: true},
+			{UUID: "{3}", Key: "UNCHANGED", Value: "same"},
+			{UUID: "{4}", Key: "REMOVED", Value: "gone"},
+			{UUID: "{5}", Key: "UNMANAGED", Value: "keep"},
+		},
+	})
+
+	p, err := service.PlanRepoVariable("my-workspace", "my-repo", map[string]string{
+		"SECRET":    "secret",
+		"UNCHANGED": "same",
+		"ADDED":     "new",
+	})
+	if err != nil {
+		t.Fatalf("PlanRepoVariable() error = %v", err)
+	}
+	want := []plan.Change{
+		{Key: "ADDED", Action: plan.ActionCreate},
+		{Key: "REMOVED", Action: plan.ActionDelete},
+		{Key: "SECRET", Action: plan.ActionUpdate, Reason: "current value cannot be read"},
+		{Key: "UNCHANGED", Action: plan.ActionUnchanged},
+	}
+	if !slices.Equal(p.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", p.Changes, want)
+	}
+}
+
+func TestDeriveLocationFromURL(t *testing.T) {
+	for _, location := range []string{"bitbucket.org/my-workspace/my-repo", "https://bitbucket.org/my-workspace/my-repo.git", "my-workspace/my-repo/"} {
+		workspace, repo, err := DeriveLocationFromURL(location)
+		if err != nil || workspace != "my-workspace" || repo != "my-repo" {
+			t.Errorf("DeriveLocationFromURL(%s) = %s, %s, %v, want my-workspace, my-repo", location, workspace, repo, err)
+		}
+	}
+	if _, _, err := DeriveLocationFromURL("my-repo"); err == nil {
+		t.Error("DeriveLocationFromURL(my-repo) error = nil, want invalid URL")
+	}
+}
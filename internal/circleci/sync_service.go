@@ -0,0 +1,267 @@
+package circleci
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// DefaultBaseURL is the base URL of the CircleCI API v2
+const DefaultBaseURL = "https://circleci.com/api/v2"
+
+// vcsSlugs maps repository hosts to the VCS prefix of CircleCI slugs
+var vcsSlugs = map[string]string{
+	"github.com":    "gh",
+	"bitbucket.org": "bb",
+}
+
+type contextItem struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// page is a page of a CircleCI list response
+type page[T any] struct {
+	Items         []T    `json:"items"`
+	NextPageToken string `json:"next_page_token"`
+}
+
+// CircleCIService handles syncing environment variables to CircleCI projects and contexts.
+// Values cannot be read back, so the caller keeps track of the keys openv owns.
+type CircleCIService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewCircleCIService creates a new CircleCIService authenticating with a personal API token, an empty baseURL uses DefaultBaseURL
+func NewCircleCIService(token, baseURL string) *CircleCIService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client := rest.NewClient(ctx, baseURL, map[string]string{
+		"Circle-Token": token,
+	})
+	return &CircleCIService{client: client, ctx: ctx}
+}
+
+// SyncToProject sets the environment variables of a project.
+// With cleanup, the owned keys no longer in envVars are deleted.
+func (s *CircleCIService) SyncToProject(projectSlug string, envVars map[string]string, owned []string, withCleanup bool) error {
+	existing, err := s.listProjectVariables(projectSlug)
+	if err != nil {
+		return err
+	}
+	basePath := "/project/" + projectSlug + "/envvar"
+
+	logging.Logger.Debug("syncing to CircleCI project environment variables", "project", projectSlug)
+
+	if withCleanup {
+		for _, key := range removedKeys(existing, envVars, owned) {
+			if err := s.client.Do("DELETE", basePath+"/"+url.PathEscape(key), nil, nil); err != nil {
+				logging.Logger.Warn("failed to delete CircleCI environment variable", "key", key, "error", err)
+			}
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(envVars)) {
+		if key == internal.OPENV_KEYS {
+			continue
+		}
+		// Creating an existing variable replaces its value
+		if err := s.client.Do("POST", basePath, map[string]string{"name": key, "value": envVars[key]}, nil); err != nil {
+			return fmt.Errorf("failed to set CircleCI environment variable %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// SyncToContext sets the environment variables of the context named like contextName in an organization,
+// creating the context if it does not exist. With cleanup, the owned keys no longer in envVars are deleted.
+func (s *CircleCIService) SyncToContext(orgSlug, contextName string, envVars map[string]string, owned []string, withCleanup bool) error {
+	contextID, err := s.findContext(orgSlug, contextName)
+	if err != nil {
+		return err
+	}
+	if contextID == "" {
+		created := contextItem{}
+		if err := s.client.Do("POST", "/context", map[string]any{
+			"name":  contextName,
+			"owner": map[string]string{"slug": orgSlug, "type": "organization"},
+		}, &created); err != nil {
+			return fmt.Errorf("failed to create CircleCI context %s: %w", contextName, err)
+		}
+		logging.Logger.Info("created CircleCI context", "context", contextName, "organization", orgSlug)
+		contextID = created.ID
+	}
+	existing, err := s.listContextVariables(contextID)
+	if err != nil {
+		return err
+	}
+	basePath := "/context/" + url.PathEscape(contextID) + "/environment-variable/"
+
+	logging.Logger.Debug("syncing to CircleCI context", "organization", orgSlug, "context", contextName)
+
+	if withCleanup {
+		for _, key := range removedKeys(existing, envVars, owned) {
+			if err := s.client.Do("DELETE", basePath+url.PathEscape(key), nil, nil); err != nil {
+				logging.Logger.Warn("failed to delete CircleCI context variable", "key", key, "error", err)
+			}
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(envVars)) {
+		if key == internal.OPENV_KEYS {
+			continue
+		}
+		if err := s.client.Do("PUT", basePath+url.PathEscape(key), map[string]string{"value": envVars[key]}, nil); err != nil {
+			return fmt.Errorf("failed to set CircleCI context variable %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// PlanProject computes the changes SyncToProject would apply, existing values cannot be read
+func (s *CircleCIService) PlanProject(projectSlug string, envVars map[string]string, owned []string) (*plan.Plan, error) {
+	existing, err := s.listProjectVariables(projectSlug)
+	if err != nil {
+		return nil, err
+	}
+	return planSync(fmt.Sprintf("CircleCI project %s", projectSlug), existing, envVars, owned), nil
+}
+
+// PlanContext computes the changes SyncToContext would apply, existing values cannot be read
+func (s *CircleCIService) PlanContext(orgSlug, contextName string, envVars map[string]string, owned []string) (*plan.Plan, error) {
+	contextID, err := s.findContext(orgSlug, contextName)
+	if err != nil {
+		return nil, err
+	}
+	existing := []string{}
+	if contextID != "" {
+		if existing, err = s.listContextVariables(contextID); err != nil {
+			return nil, err
+		}
+	}
+	return planSync(fmt.Sprintf("CircleCI context %s", contextName), existing, envVars, owned), nil
+}
+
+func (s *CircleCIService) listProjectVariables(projectSlug string) ([]string, error) {
+	if projectSlug == "" {
+		return nil, fmt.Errorf("no CircleCI project configured, set the profile URL to the project slug")
+	}
+	variables, err := paginate[struct {
+		Name string `json:"name"`
+	}](s, "/project/"+projectSlug+"/envvar")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environment variables of CircleCI project %s: %w", projectSlug, err)
+	}
+	names := []string{}
+	for _, v := range variables {
+		names = append(names, v.Name)
+	}
+	return names, nil
+}
+
+func (s *CircleCIService) listContextVariables(contextID string) ([]string, error) {
+	variables, err := paginate[struct {
+		Variable string `json:"variable"`
+	}](s, "/context/"+url.PathEscape(contextID)+"/environment-variable")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CircleCI context variables: %w", err)
+	}
+	names := []string{}
+	for _, v := range variables {
+		names = append(names, v.Variable)
+	}
+	return names, nil
+}
+
+// findContext returns the ID of the context named like contextName, or an empty string if it does not exist
+func (s *CircleCIService) findContext(orgSlug, contextName string) (string, error) {
+	if orgSlug == "" || contextName == "" {
+		return "", fmt.Errorf("CircleCI organization and context name are required")
+	}
+	contexts, err := paginate[contextItem](s, "/context?owner-type=organization&owner-slug="+url.QueryEscape(orgSlug))
+	if err != nil {
+		return "", fmt.Errorf("failed to list CircleCI contexts of %s: %w", orgSlug, err)
+	}
+	for _, c := range contexts {
+		if c.Name == contextName {
+			return c.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// paginate follows the next page tokens of a list endpoint
+func paginate[T any](s *CircleCIService, path string) ([]T, error) {
+	items := []T{}
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	token := ""
+	for {
+		pagePath := path
+		if token != "" {
+			pagePath += separator + "page-token=" + url.QueryEscape(token)
+		}
+		response := page[T]{}
+		if err := s.client.Do("GET", pagePath, nil, &response); err != nil {
+			return nil, err
+		}
+		items = append(items, response.Items...)
+		if response.NextPageToken == "" {
+			return items, nil
+		}
+		token = response.NextPageToken
+	}
+}
+
+// removedKeys returns the owned keys that exist on CircleCI and are no longer in envVars
+func removedKeys(existing []string, envVars map[string]string, owned []string) []string {
+	removed := []string{}
+	for _, key := range owned {
+		if _, desired := envVars[key]; !desired && slices.Contains(existing, key) {
+			removed = append(removed, key)
+		}
+	}
+	return removed
+}
+
+func planSync(target string, existing []string, envVars map[string]string, owned []string) *plan.Plan {
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key != internal.OPENV_KEYS {
+			desired[key] = value
+		}
+	}
+	current := map[string]*string{}
+	for _, key := range existing {
+		current[key] = nil
+	}
+	return plan.Compute(target, desired, plan.State{Values: current, Owned: owned})
+}
+
+// Slug converts a repository URL like github.com/org/repo into a CircleCI slug like gh/org/repo,
+// keeping the VCS prefix and the given number of segments after it. Slugs like gh/org or circleci/<id> are kept as they are.
+func Slug(location string, segments int) (string, error) {
+	location = strings.Trim(location, "/")
+	if _, rest, found := strings.Cut(location, "://"); found {
+		location = rest
+	}
+	parts := strings.Split(location, "/")
+	if vcs, ok := vcsSlugs[parts[0]]; ok {
+		parts[0] = vcs
+	}
+	if len(parts) < segments+1 || slices.Contains(parts[:segments+1], "") {
+		return "", fmt.Errorf("invalid CircleCI location %s", location)
+	}
+	return strings.Join(parts[:segments+1], "/"), nil
+}
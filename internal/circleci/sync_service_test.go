@@ -0,0 +1,232 @@
+package circleci
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+const projectPath = "/project/gh/org/repo/envvar"
+
+// fakeCircleCI serves the project environment variable and context endpoints for one project,
+// listing one variable per page to exercise pagination
+type fakeCircleCI struct {
+	t           *testing.T
+	mu          sync.Mutex
+	projectVars []string
+	contexts    map[string]string
+	contextVars map[string][]string
+	requests    []string
+}
+
+func newFakeCircleCI(t *testing.T, projectVars ...string) (*fakeCircleCI, *CircleCIService) {
+	f := &fakeCircleCI{t: t, projectVars: projectVars, contexts: map[string]string{}, contextVars: map[string][]string{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, NewCircleCIService("token", server.URL)
+}
+
+func (f *fakeCircleCI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	if r.Header.Get("Circle-Token") != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.URL.Path == projectPath && r.Method == "GET":
+		writePage(w, r, f.projectVars, func(name string) any { return map[string]string{"name": name} })
+	case r.URL.Path == projectPath && r.Method == "POST":
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if !slices.Contains(f.projectVars, body["name"]) {
+			f.projectVars = append(f.projectVars, body["name"])
+		}
+		writeJSON(w, body)
+	case strings.HasPrefix(r.URL.Path, projectPath+"/") && r.Method == "DELETE":
+		key := strings.TrimPrefix(r.URL.Path, projectPath+"/")
+		f.projectVars = slices.DeleteFunc(f.projectVars, func(name string) bool { return name == key })
+		writeJSON(w, map[string]string{})
+	case r.URL.Path == "/context" && r.Method == "GET":
+		if r.URL.Query().Get("owner-slug") != "gh/org" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		names := slices.Sorted(maps.Keys(f.contexts))
+		writePage(w, r, names, func(name string) any { return contextItem{ID: f.contexts[name], Name: name} })
+	case r.URL.Path == "/context" && r.Method == "POST":
+		var body struct {
+			Name string `json:"name"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.contexts[body.Name] = "ctx-" + body.Name
+		writeJSON(w, contextItem{ID: f.contexts[body.Name], Name: body.Name})
+	case strings.HasPrefix(r.URL.Path, "/context/"):
+		id, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/context/"), "/environment-variable")
+		key = strings.TrimPrefix(key, "/")
+		switch r.Method {
+		case "GET":
+			writePage(w, r, f.contextVars[id], func(name string) any { return map[string]string{"variable": name} })
+		case "PUT":
+			if !slices.Contains(f.contextVars[id], key) {
+				f.contextVars[id] = append(f.contextVars[id], key)
+			}
+			writeJSON(w, map[string]string{"variable": key})
+		case "DELETE":
+			f.contextVars[id] = slices.DeleteFunc(f.contextVars[id], func(name string) bool { return name == key })
+			writeJSON(w, map[string]string{})
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// writePage returns the item at the page token, or the first one, with a token for the next item
+func writePage(w http.ResponseWriter, r *http.Request, names []string, item func(string) any) {
+	index := 0
+	if token := r.URL.Query().Get("page-token"); token != "" {
+		index = slices.Index(names, token)
+	}
+	response := map[string]any{"items": []any{}, "next_page_token": ""}
+	if index >= 0 && index < len(names) {
+		response["items"] = []any{item(names[index])}
+		if index+1 < len(names) {
+			response["next_page_token"] = names[index+1]
+		}
+	}
+	writeJSON(w, response)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestSyncToProjectDeletesOwnedKeys(t *testing.T) {
+	f, service := newFakeCircleCI(t, "KEPT", "REMOVED", "FOREIGN")
+
+	envVars := map[string]string{"KEPT": "1", "ADDED": "2", internal.OPENV_KEYS: `["ADDED","KEPT"]`}
+	if err := service.SyncToProject("gh/org/repo", envVars, []string{"KEPT", "REMOVED"}, true); err != nil {
+		t.Fatalf("SyncToProject() error = %v", err)
+	}
+
+	slices.Sort(f.projectVars)
+	if want := []string{"ADDED", "FOREIGN", "KEPT"}; !slices.Equal(f.projectVars, want) {
+		t.Errorf("project variables = %v, want %v", f.projectVars, want)
+	}
+}
+
+func TestSyncToProjectWithoutCleanup(t *testing.T) {
+	f, service := newFakeCircleCI(t, "KEPT", "REMOVED")
+
+	if err := service.SyncToProject("gh/org/repo", map[string]string{"KEPT": "1"}, []string{"KEPT", "REMOVED"}, false); err != nil {
+		t.Fatalf("SyncToProject() error = %v", err)
+	}
+
+	if !slices.Contains(f.projectVars, "REMOVED") {
+		t.Errorf("project variables = %v, want REMOVED kept without cleanup", f.projectVars)
+	}
+	for _, request := range f.requests {
+		if strings.HasPrefix(request, "DELETE") {
+			t.Errorf("unexpected request %s", request)
+		}
+	}
+}
+
+func TestSyncToContextCreatesContext(t *testing.T) {
+	f, service := newFakeCircleCI(t)
+	f.contexts["other"] = "ctx-other"
+
+	envVars := map[string]string{"KEY": "value", internal.OPENV_KEYS: `["KEY"]`}
+	if err := service.SyncToContext("gh/org", "production", envVars, nil, true); err != nil {
+		t.Fatalf("SyncToContext() error = %v", err)
+	}
+
+	if f.contexts["production"] != "ctx-production" {
+		t.Fatalf("contexts = %v, want production created", f.contexts)
+	}
+	if want := []string{"KEY"}; !slices.Equal(f.contextVars["ctx-production"], want) {
+		t.Errorf("context variables = %v, want %v", f.contextVars["ctx-production"], want)
+	}
+}
+
+func TestSyncToContextDeletesOwnedKeys(t *testing.T) {
+	f, service := newFakeCircleCI(t)
+	f.contexts["production"] = "ctx-production"
+	f.contextVars["ctx-production"] = []string{"KEPT", "REMOVED", "FOREIGN"}
+
+	if err := service.SyncToContext("gh/org", "production", map[string]string{"KEPT": "1"}, []string{"KEPT", "REMOVED"}, true); err != nil {
+		t.Fatalf("SyncToContext() error = %v", err)
+	}
+
+	if want := []string{"KEPT", "FOREIGN"}; !slices.Equal(f.contextVars["ctx-production"], want) {
+		t.Errorf("context variables = %v, want %v", f.contextVars["ctx-production"], want)
+	}
+}
+
+func TestPlanProject(t *testing.T) {
+	_, service := newFakeCircleCI(t, "KEPT", "REMOVED", "FOREIGN")
+
+	syncPlan, err := service.PlanProject("gh/org/repo", map[string]string{"KEPT": "1", "ADDED": "2"}, []string{"KEPT", "REMOVED"})
+	if err != nil {
+		t.Fatalf("PlanProject() error = %v", err)
+	}
+
+	actions := map[string]plan.Action{}
+	for _, change := range syncPlan.Changes {
+		actions[change.Key] = change.Action
+	}
+	want := map[string]plan.Action{"ADDED": plan.ActionCreate, "KEPT": plan.ActionUpdate, "REMOVED": plan.ActionDelete}
+	for key, action := range want {
+		if actions[key] != action {
+			t.Errorf("action of %s = %v, want %v", key, actions[key], action)
+		}
+	}
+	if _, ok := actions["FOREIGN"]; ok {
+		t.Errorf("plan includes FOREIGN, which openv does not own")
+	}
+}
+
+func TestPlanContextMissing(t *testing.T) {
+	_, service := newFakeCircleCI(t)
+
+	syncPlan, err := service.PlanContext("gh/org", "production", map[string]string{"KEY": "value"}, nil)
+	if err != nil {
+		t.Fatalf("PlanContext() error = %v", err)
+	}
+	if len(syncPlan.Changes) != 1 || syncPlan.Changes[0].Action != plan.ActionCreate {
+		t.Errorf("changes = %v, want KEY created", syncPlan.Changes)
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		location string
+		segments int
+		want     string
+		wantErr  bool
+	}{
+		{location: "github.com/org/repo", segments: 2, want: "gh/org/repo"},
+		{location: "https://bitbucket.org/org/repo/", segments: 2, want: "bb/org/repo"},
+		{location: "github.com/org/repo", segments: 1, want: "gh/org"},
+		{location: "circleci/abc/def", segments: 2, want: "circleci/abc/def"},
+		{location: "github.com/org", segments: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Slug(tt.location, tt.segments)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Slug(%q, %d) = %q, %v, want %q", tt.location, tt.segments, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package cloudflare
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+const (
+	// PagesProduction is the deployment configuration of the production branch
+	PagesProduction = "production"
+	// PagesPreview is the deployment configuration of all other branches
+	PagesPreview = "preview"
+)
+
+type pagesEnvVar struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type pagesProject struct {
+	DeploymentConfigs map[string]struct {
+		EnvVars map[string]*pagesEnvVar `json:"env_vars"`
+	} `json:"deployment_configs"`
+}
+
+// SyncToPagesProject writes the environment variables to the production or preview deployment configuration
+// of a Pages project with one update. Keys listed in plain are stored as plain text, all others encrypted.
+func (s *CloudflareService) SyncToPagesProject(accountID, project, config string, envVars map[string]string, plain []string, withCleanup bool) error {
+	existing, err := s.getPagesEnvVars(accountID, project, config)
+	if err != nil {
+		return err
+	}
+
+	// A null value deletes the variable
+	changes := map[string]*pagesEnvVar{}
+	if withCleanup {
+		for _, key := range pagesOwnedKeys(existing) {
+			if _, desired := envVars[key]; !desired && existing[key] != nil {
+				changes[key] = nil
+			}
+		}
+	}
+	for key, value := range envVars {
+		variable := &pagesEnvVar{Type: typeSecretText, Value: value}
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_CLOUDFLARE
+		}
+		if key == internal.OPENV_KEYS_CLOUDFLARE || slices.Contains(plain, key) {
+			variable.Type = typePlainText
+		}
+		if current := existing[key]; current != nil && current.Type == typePlainText && *current == *variable {
+			continue
+		}
+		changes[key] = variable
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	logging.Logger.Debug("syncing to Cloudflare Pages environment variables", "account", accountID, "project", project, "config", config)
+
+	body := map[string]any{
+		"deployment_configs": map[string]any{
+			config: map[string]any{"env_vars": changes},
+		},
+	}
+	if err := s.client.Do("PATCH", pagesProjectPath(accountID, project), body, nil); err != nil {
+		return fmt.Errorf("failed to update environment variables of Pages project %s: %w", project, err)
+	}
+	return nil
+}
+
+// PlanPagesProject computes the changes SyncToPagesProject would apply, encrypted values cannot be read
+func (s *CloudflareService) PlanPagesProject(accountID, project, config string, envVars map[string]string) (*plan.Plan, error) {
+	existing, err := s.getPagesEnvVars(accountID, project, config)
+	if err != nil {
+		return nil, err
+	}
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_CLOUDFLARE
+		}
+		desired[key] = value
+	}
+	values := map[string]*string{}
+	for key, variable := range existing {
+		if variable == nil {
+			continue
+		}
+		if variable.Type == typePlainText {
+			values[key] = &variable.Value
+		} else {
+			values[key] = nil
+		}
+	}
+	return plan.Compute(fmt.Sprintf("Cloudflare Pages project %s (%s)", project, config), desired, plan.State{
+		Values: values,
+		Owned:  pagesOwnedKeys(existing),
+	}), nil
+}
+
+func (s *CloudflareService) getPagesEnvVars(accountID, project, config string) (map[string]*pagesEnvVar, error) {
+	if config != PagesProduction && config != PagesPreview {
+		return nil, fmt.Errorf("invalid Pages deployment configuration %s", config)
+	}
+	response := envelope[pagesProject]{}
+	if err := s.client.Do("GET", pagesProjectPath(accountID, project), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get Pages project %s: %w", project, err)
+	}
+	envVars := response.Result.DeploymentConfigs[config].EnvVars
+	if envVars == nil {
+		return map[string]*pagesEnvVar{}, nil
+	}
+	return envVars, nil
+}
+
+// pagesOwnedKeys returns the keys recorded in the OPENV_KEYS_CLOUDFLARE variable
+func pagesOwnedKeys(existing map[string]*pagesEnvVar) []string {
+	if bookkeeping := existing[internal.OPENV_KEYS_CLOUDFLARE]; bookkeeping != nil && bookkeeping.Type == typePlainText {
+		return internal.ParseKeys(bookkeeping.Value)
+	}
+	return []string{}
+}
+
+func pagesProjectPath(accountID, project string) string {
+	return fmt.Sprintf("/accounts/%s/pages/projects/%s", url.PathEscape(accountID), url.PathEscape(project))
+}
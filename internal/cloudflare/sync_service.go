@@ -0,0 +1,49 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// DefaultBaseURL is the base URL of the Cloudflare API
+const DefaultBaseURL = "https://api.cloudflare.com/client/v4"
+
+const (
+	typePlainText  = "plain_text"
+	typeSecretText = "secret_text"
+)
+
+// envelope is the response wrapper of the Cloudflare API
+type envelope[T any] struct {
+	Result T `json:"result"`
+}
+
+// CloudflareService handles syncing environment variables to Cloudflare Workers and Pages
+type CloudflareService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewCloudflareService creates a new CloudflareService authenticating with an API token, an empty baseURL uses DefaultBaseURL
+func NewCloudflareService(token, baseURL string) *CloudflareService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client := rest.NewClient(ctx, baseURL, map[string]string{
+		"Authorization": "Bearer " + token,
+	})
+	return &CloudflareService{client: client, ctx: ctx}
+}
+
+// ParseLocation splits a profile URL of the form <account id>/<name> into account ID and Worker script or Pages project name
+func ParseLocation(location string) (string, string, error) {
+	accountID, name, found := strings.Cut(strings.Trim(location, "/"), "/")
+	if !found || accountID == "" || name == "" {
+		return "", "", fmt.Errorf("invalid Cloudflare location %s, set the profile URL to <account id>/<name>", location)
+	}
+	return accountID, name, nil
+}
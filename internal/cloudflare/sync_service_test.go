@@ -0,0 +1,315 @@
+package cloudflare
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+const (
+	workerSettingsPath = "/accounts/acc-1/workers/scripts/my-worker/settings"
+	pagesPath          = "/accounts/acc-1/pages/projects/my-site"
+)
+
+// fakeCloudflare serves the settings of one Worker script and one Pages project
+type fakeCloudflare struct {
+	t        *testing.T
+	mu       sync.Mutex
+	bindings []binding
+	// pages holds the environment variables of the Pages project by deployment configuration
+	pages map[string]map[string]*pagesEnvVar
+	// patches holds the JSON bodies of the PATCH requests, the settings part for Workers
+	patches []string
+}
+
+func newFakeCloudflare(t *testing.T) (*fakeCloudflare, *CloudflareService) {
+	f := &fakeCloudflare{t: t, pages: map[string]map[string]*pagesEnvVar{PagesProduction: {}, PagesPreview: {}}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, NewCloudflareService("token", server.URL)
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == workerSettingsPath:
+		// Secret values are never returned
+		bindings := []binding{}
+		for _, b := range f.bindings {
+			if b.Type == typeSecretText {
+				b.Text = ""
+			}
+			bindings = append(bindings, b)
+		}
+		writeResult(w, settings{Bindings: bindings})
+	case r.Method == "PATCH" && r.URL.Path == workerSettingsPath:
+		payload := f.readSettingsPart(r)
+		f.patches = append(f.patches, string(payload))
+		var patch settings
+		if err := json.Unmarshal(payload, &patch); err != nil {
+			f.t.Errorf("invalid settings: %v", err)
+		}
+		bindings := []binding{}
+		for _, b := range patch.Bindings {
+			if b.Type == typeInherit {
+				i := slices.IndexFunc(f.bindings, func(existing binding) bool { return existing.Name == b.Name })
+				if i < 0 {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				b = f.bindings[i]
+			}
+			bindings = append(bindings, b)
+		}
+		f.bindings = bindings
+		writeResult(w, settings{Bindings: bindings})
+	case r.Method == "GET" && r.URL.Path == pagesPath:
+		// Encrypted values are never returned
+		configs := map[string]any{}
+		for config, envVars := range f.pages {
+			listed := map[string]*pagesEnvVar{}
+			for key, v := range envVars {
+				listed[key] = v
+				if v.Type == typeSecretText {
+					listed[key] = &pagesEnvVar{Type: typeSecretText}
+				}
+			}
+			configs[config] = map[string]any{"env_vars": listed}
+		}
+		writeResult(w, map[string]any{"deployment_configs": configs})
+	case r.Method == "PATCH" && r.URL.Path == pagesPath:
+		payload, _ := io.ReadAll(r.Body)
+		f.patches = append(f.patches, string(payload))
+		var patch pagesProject
+		if err := json.Unmarshal(payload, &patch); err != nil {
+			f.t.Errorf("invalid Pages project patch: %v", err)
+		}
+		for config, deploymentConfig := range patch.DeploymentConfigs {
+			for key, v := range deploymentConfig.EnvVars {
+				if v == nil {
+					delete(f.pages[config], key)
+				} else {
+					f.pages[config][key] = v
+				}
+			}
+		}
+		writeResult(w, map[string]any{})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// readSettingsPart returns the settings part of a multipart Worker settings request
+func (f *fakeCloudflare) readSettingsPart(r *http.Request) []byte {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		f.t.Errorf("content type = %s, want multipart/form-data", r.Header.Get("Content-Type"))
+		return nil
+	}
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	part, err := reader.NextPart()
+	if err != nil {
+		f.t.Errorf("failed to read settings part: %v", err)
+		return nil
+	}
+	if part.FormName() != "settings" || part.Header.Get("Content-Type") != "application/json" {
+		f.t.Errorf("part %s with content type %s, want settings as application/json", part.FormName(), part.Header.Get("Content-Type"))
+	}
+	payload, _ := io.ReadAll(part)
+	if _, err := reader.NextPart(); err != io.EOF {
+		f.t.Errorf("settings request has more than one part")
+	}
+	return payload
+}
+
+func writeResult(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"success": true, "result": result})
+}
+
+func TestSyncToWorker(t *testing.T) {
+	existing := []binding{
+		{Type: "kv_namespace", Name: "CACHE"},
+		{Type: typePlainText, Name: internal.OPENV_KEYS_CLOUDFLARE, Text: `["REMOVED","SECRET"]`},
+		{Type: typeSecretText, Name: "REMOVED", Text: "gone"},
+		{Type: typeSecretText, Name: "SECRET", Text: "old"},
+		{Type: typeSecretText, Name: "UNMANAGED", Text: "keep"},
+	}
+	envVars := map[string]string{
+		"PUBLIC":            "plain",
+		"SECRET":            "new",
+		internal.OPENV_KEYS: `["PUBLIC","SECRET"]`,
+	}
+	desired := []binding{
+		{Type: typePlainText, Name: internal.OPENV_KEYS_CLOUDFLARE, Text: `["PUBLIC","SECRET"]`},
+		{Type: typePlainText, Name: "PUBLIC", Text: "plain"},
+		{Type: typeSecretText, Name: "SECRET", Text: "new"},
+	}
+
+	tests := []struct {
+		name        string
+		withCleanup bool
+		want        []binding
+	}{
+		{
+			name:        "cleanup",
+			withCleanup: true,
+			want: append([]binding{
+				{Type: typeInherit, Name: "CACHE"},
+				{Type: typeInherit, Name: "UNMANAGED"},
+			}, desired...),
+		},
+		{
+			name: "no cleanup",
+			want: append([]binding{
+				{Type: typeInherit, Name: "CACHE"},
+				{Type: typeInherit, Name: "REMOVED"},
+				{Type: typeInherit, Name: "UNMANAGED"},
+			}, desired...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, service := newFakeCloudflare(t)
+			f.bindings = slices.Clone(existing)
+
+			if err := service.SyncToWorker("acc-1", "my-worker", envVars, []string{"PUBLIC"}, tt.withCleanup); err != nil {
+				t.Fatalf("SyncToWorker() error = %v", err)
+			}
+			if len(f.patches) != 1 {
+				t.Fatalf("patches = %v, want one settings update", f.patches)
+			}
+			var got settings
+			if err := json.Unmarshal([]byte(f.patches[0]), &got); err != nil {
+				t.Fatalf("invalid settings: %v", err)
+			}
+			if !slices.Equal(got.Bindings, tt.want) {
+				t.Errorf("bindings = %+v, want %+v", got.Bindings, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanWorker(t *testing.T) {
+	f, service := newFakeCloudflare(t)
+	f.bindings = []binding{
+		{Type: "kv_namespace", Name: "CACHE"},
+		{Type: typePlainText, Name: internal.OPENV_KEYS_CLOUDFLARE, Text: `["PUBLIC","REMOVED","SECRET"]`},
+		{Type: typePlainText, Name: "PUBLIC", Text: "plain"},
+		{Type: typeSecretText, Name: "REMOVED", Text: "gone"},
+		{Type: typeSecretText, Name: "SECRET", Text: "secret"},
+	}
+
+	p, err := service.PlanWorker("acc-1", "my-worker", map[string]string{"PUBLIC": "plain", "SECRET": "secret"})
+	if err != nil {
+		t.Fatalf("PlanWorker() error = %v", err)
+	}
+	want := []plan.Change{
+		{Key: "PUBLIC", Action: plan.ActionUnchanged},
+		{Key: "REMOVED", Action: plan.ActionDelete},
+		{Key: "SECRET", Action: plan.ActionUpdate, Reason: "current value cannot be read"},
+	}
+	if !slices.Equal(p.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", p.Changes, want)
+	}
+}
+
+func TestSyncToPagesProject(t *testing.T) {
+	for _, config := range []string{PagesProduction, PagesPreview} {
+		t.Run(config, func(t *testing.T) {
+			f, service := newFakeCloudflare(t)
+			other := PagesPreview
+			if config == PagesPreview {
+				other = PagesProduction
+			}
+			f.pages[config] = map[string]*pagesEnvVar{
+				internal.OPENV_KEYS_CLOUDFLARE: {Type: typePlainText, Value: `["PUBLIC","REMOVED","SECRET"]`},
+				"PUBLIC":                       {Type: typePlainText, Value: "plain"},
+				"REMOVED":                      {Type: typeSecretText, Value: "gone"},
+				"SECRET":                       {Type: typeSecretText, Value: "secret"},
+				"UNMANAGED":                    {Type: typeSecretText, Value: "keep"},
+			}
+			f.pages[other] = map[string]*pagesEnvVar{"REMOVED": {Type: typeSecretText, Value: "kept"}}
+
+			err := service.SyncToPagesProject("acc-1", "my-site", config, map[string]string{
+				"PUBLIC":            "plain",
+				"SECRET":            "secret",
+				internal.OPENV_KEYS: `["PUBLIC","SECRET"]`,
+			}, []string{"PUBLIC"}, true)
+			if err != nil {
+				t.Fatalf("SyncToPagesProject() error = %v", err)
+			}
+
+			if len(f.patches) != 1 {
+				t.Fatalf("patches = %v, want one project update", f.patches)
+			}
+			var patch map[string]map[string]map[string]map[string]*pagesEnvVar
+			if err := json.Unmarshal([]byte(f.patches[0]), &patch); err != nil {
+				t.Fatalf("invalid patch: %v", err)
+			}
+			configs := patch["deployment_configs"]
+			if _, exists := configs[other]; exists || len(configs) != 1 {
+				t.Errorf("patched configurations = %v, want only %s", configs, config)
+			}
+			envVars := configs[config]["env_vars"]
+			if removed, exists := envVars["REMOVED"]; !exists || removed != nil {
+				t.Errorf("REMOVED = %v, want null to delete it", removed)
+			}
+			if _, exists := envVars["PUBLIC"]; exists {
+				t.Error("PUBLIC was sent although its plain text value did not change")
+			}
+			if secret := envVars["SECRET"]; secret == nil || secret.Type != typeSecretText {
+				t.Errorf("SECRET = %v, want it sent encrypted as the current value cannot be read", secret)
+			}
+			if _, exists := f.pages[other]["REMOVED"]; !exists {
+				t.Errorf("REMOVED was deleted from %s", other)
+			}
+			if _, exists := f.pages[config]["UNMANAGED"]; !exists {
+				t.Error("UNMANAGED was deleted")
+			}
+		})
+	}
+}
+
+func TestSyncToPagesProjectUnchanged(t *testing.T) {
+	f, service := newFakeCloudflare(t)
+	f.pages[PagesProduction] = map[string]*pagesEnvVar{"PUBLIC": {Type: typePlainText, Value: "plain"}}
+
+	if err := service.SyncToPagesProject("acc-1", "my-site", PagesProduction, map[string]string{"PUBLIC": "plain"}, []string{"PUBLIC"}, true); err != nil {
+		t.Fatalf("SyncToPagesProject() error = %v", err)
+	}
+	if len(f.patches) != 0 {
+		t.Errorf("patches = %v, want none", f.patches)
+	}
+	if err := service.SyncToPagesProject("acc-1", "my-site", "staging", map[string]string{}, nil, true); err == nil {
+		t.Error("SyncToPagesProject() error = nil, want invalid deployment configuration")
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	accountID, name, err := ParseLocation("/acc-1/my-worker/")
+	if err != nil || accountID != "acc-1" || name != "my-worker" {
+		t.Errorf("ParseLocation() = %s, %s, %v, want acc-1, my-worker", accountID, name, err)
+	}
+	for _, location := range []string{"acc-1", "acc-1/", "/my-worker"} {
+		if _, _, err := ParseLocation(location); err == nil {
+			t.Errorf("ParseLocation(%s) error = nil, want invalid location", location)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package cloudflare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"slices"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// typeInherit keeps an existing binding unchanged when the settings are patched
+const typeInherit = "inherit"
+
+type binding struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Text string `json:"text,omitempty"`
+}
+
+type settings struct {
+	Bindings []binding `json:"bindings"`
+}
+
+// SyncToWorker writes the environment variables as secrets of a Worker script with one settings update.
+// Keys listed in plain become plain text variables. Bindings not synced by openv are inherited unchanged,
+// secrets synced before and no longer in envVars are dropped.
+func (s *CloudflareService) SyncToWorker(accountID, script string, envVars map[string]string, plain []string, withCleanup bool) error {
+	current, err := s.getSettings(accountID, script)
+	if err != nil {
+		return err
+	}
+
+	removed := map[string]bool{}
+	if withCleanup {
+		for _, key := range ownedKeys(current.Bindings) {
+			if _, exists := envVars[key]; !exists {
+				removed[key] = true
+			}
+		}
+	}
+
+	desired := workerBindings(envVars, plain)
+	bindings := []binding{}
+	for _, existing := range current.Bindings {
+		if removed[existing.Name] || slices.ContainsFunc(desired, func(b binding) bool { return b.Name == existing.Name }) {
+			continue
+		}
+		bindings = append(bindings, binding{Type: typeInherit, Name: existing.Name})
+	}
+	bindings = append(bindings, desired...)
+
+	logging.Logger.Debug("syncing to Cloudflare Worker secrets", "account", accountID, "script", script, "removed", slices.Sorted(maps.Keys(removed)))
+
+	payload, err := json.Marshal(settings{Bindings: bindings})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Worker settings: %w", err)
+	}
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="settings"`)
+	header.Set("Content-Type", "application/json")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return fmt.Errorf("failed to create Worker settings form: %w", err)
+	}
+	if _, err := part.Write(payload); err != nil {
+		return fmt.Errorf("failed to write Worker settings form: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write Worker settings form: %w", err)
+	}
+
+	if err := s.client.DoRaw("PATCH", settingsPath(accountID, script), writer.FormDataContentType(), body, nil); err != nil {
+		return fmt.Errorf("failed to update secrets of Worker %s: %w", script, err)
+	}
+	return nil
+}
+
+// PlanWorker computes the changes SyncToWorker would apply, secret values cannot be read
+func (s *CloudflareService) PlanWorker(accountID, script string, envVars map[string]string) (*plan.Plan, error) {
+	current, err := s.getSettings(accountID, script)
+	if err != nil {
+		return nil, err
+	}
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_CLOUDFLARE
+		}
+		desired[key] = value
+	}
+	values := map[string]*string{}
+	for _, b := range current.Bindings {
+		switch b.Type {
+		case typePlainText:
+			values[b.Name] = &b.Text
+		case typeSecretText:
+			values[b.Name] = nil
+		}
+	}
+	return plan.Compute(fmt.Sprintf("Cloudflare Worker %s", script), desired, plan.State{
+		Values: values,
+		Owned:  ownedKeys(current.Bindings),
+	}), nil
+}
+
+func (s *CloudflareService) getSettings(accountID, script string) (*settings, error) {
+	response := envelope[settings]{}
+	if err := s.client.Do("GET", settingsPath(accountID, script), nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to get settings of Worker %s: %w", script, err)
+	}
+	return &response.Result, nil
+}
+
+// workerBindings returns the bindings of the environment variables, the bookkeeping variable
+// is stored as plain text so the next sync can read it
+func workerBindings(envVars map[string]string, plain []string) []binding {
+	bindings := []binding{}
+	for _, key := range slices.Sorted(maps.Keys(envVars)) {
+		b := binding{Type: typeSecretText, Name: key, Text: envVars[key]}
+		if key == internal.OPENV_KEYS {
+			b.Name = internal.OPENV_KEYS_CLOUDFLARE
+		}
+		if key == internal.OPENV_KEYS || slices.Contains(plain, key) {
+			b.Type = typePlainText
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings
+}
+
+// ownedKeys returns the keys recorded in the OPENV_KEYS_CLOUDFLARE binding
+func ownedKeys(bindings []binding) []string {
+	for _, b := range bindings {
+		if b.Name == internal.OPENV_KEYS_CLOUDFLARE && b.Type == typePlainText {
+			return internal.ParseKeys(b.Text)
+		}
+	}
+	return []string{}
+}
+
+func settingsPath(accountID, script string) string {
+	return fmt.Sprintf("/accounts/%s/workers/scripts/%s/settings", url.PathEscape(accountID), url.PathEscape(script))
+}
@@ -8,6 +8,19 @@ const (
 	OPENV_KEYS_REPO_SECRETS               = "OPENV_KEYS_REPO_SECRET"
 	OPENV_KEYS_REPO_ENVIRONMENT_VARIABLES = "OPENV_KEYS_REPO_ENVIRONMENT_VARIABLES"
 	OPENV_KEYS_REPO_ENVIRONMENT_SECRETS   = "OPENV_KEYS_REPO_ENVIRONMENT_SECRETS"
+	OPENV_KEYS_NETLIFY                    = "OPENV_KEYS_NETLIFY"
+	OPENV_KEYS_VERCEL                     = "OPENV_KEYS_VERCEL"
+	OPENV_KEYS_SHOPIFY                    = "OPENV_KEYS_SHOPIFY"
+	OPENV_KEYS_GITLAB                     = "OPENV_KEYS_GITLAB"
+	OPENV_KEYS_BITBUCKET                  = "OPENV_KEYS_BITBUCKET"
+	OPENV_KEYS_SSM                        = "OPENV_KEYS_SSM"
+	OPENV_KEYS_SECRETS_MANAGER            = "OPENV_KEYS_SECRETS_MANAGER"
+	OPENV_KEYS_RENDER                     = "OPENV_KEYS_RENDER"
+	OPENV_KEYS_RAILWAY                    = "OPENV_KEYS_RAILWAY"
+	OPENV_KEYS_CLOUDFLARE                 = "OPENV_KEYS_CLOUDFLARE"
+	OPENV_KEYS_HEROKU                     = "OPENV_KEYS_HEROKU"
+	OPENV_KEYS_AZURE_DEVOPS               = "OPENV_KEYS_AZURE_DEVOPS"
+	OPENV_KEYS_KEY_VAULT                  = "OPENV_KEYS_KEY_VAULT"
 )
 
 var (
@@ -19,5 +32,18 @@ var (
 		OPENV_KEYS_REPO_SECRETS,
 		OPENV_KEYS_REPO_ENVIRONMENT_VARIABLES,
 		OPENV_KEYS_REPO_ENVIRONMENT_SECRETS,
+		OPENV_KEYS_NETLIFY,
+		OPENV_KEYS_VERCEL,
+		OPENV_KEYS_SHOPIFY,
+		OPENV_KEYS_GITLAB,
+		OPENV_KEYS_BITBUCKET,
+		OPENV_KEYS_SSM,
+		OPENV_KEYS_SECRETS_MANAGER,
+		OPENV_KEYS_RENDER,
+		OPENV_KEYS_RAILWAY,
+		OPENV_KEYS_CLOUDFLARE,
+		OPENV_KEYS_HEROKU,
+		OPENV_KEYS_AZURE_DEVOPS,
+		OPENV_KEYS_KEY_VAULT,
 	}
 )
@@ -0,0 +1,121 @@
+package deno
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+const (
+	// DefaultBaseURL is the base URL of the Deno Deploy API managing project settings
+	DefaultBaseURL = "https://dash.deno.com/api"
+	// DefaultDeployBaseURL is the base URL of the Deno Deploy REST API managing deployments
+	DefaultDeployBaseURL = "https://api.deno.com/v1"
+)
+
+type deployment struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// DenoService handles syncing environment variables to Deno Deploy projects.
+// Environment variable values cannot be read back, so the caller keeps track of the keys openv owns.
+type DenoService struct {
+	client       *rest.Client
+	deployClient *rest.Client
+	ctx          context.Context
+}
+
+// NewDenoService creates a new DenoService, empty base URLs use the defaults
+func NewDenoService(token, baseURL, deployBaseURL string) *DenoService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if deployBaseURL == "" {
+		deployBaseURL = DefaultDeployBaseURL
+	}
+	headers := map[string]string{
+		"Authorization": "Bearer " + token,
+	}
+	return &DenoService{
+		client:       rest.NewClient(ctx, baseURL, headers),
+		deployClient: rest.NewClient(ctx, deployBaseURL, headers),
+		ctx:          ctx,
+	}
+}
+
+// SyncToProject sets the environment variables of a Deno Deploy project.
+// With cleanup, the owned keys no longer in envVars are deleted.
+// Running deployments keep their environment, see Redeploy.
+func (s *DenoService) SyncToProject(project string, envVars map[string]string, owned []string, withCleanup bool) error {
+	if project == "" {
+		return fmt.Errorf("no Deno Deploy project configured, set the profile URL to the project name or ID")
+	}
+
+	// A null value deletes the variable
+	changes := map[string]*string{}
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			continue
+		}
+		changes[key] = &value
+	}
+	if withCleanup {
+		for _, key := range owned {
+			if _, exists := changes[key]; !exists {
+				changes[key] = nil
+			}
+		}
+	}
+
+	if err := s.client.Do("PATCH", fmt.Sprintf("/projects/%s/env", url.PathEscape(project)), changes, nil); err != nil {
+		return fmt.Errorf("failed to update Deno Deploy environment variables: %w", err)
+	}
+	return nil
+}
+
+// PlanProject computes the changes SyncToProject would apply.
+// Owned keys are known to exist, their values cannot be read.
+func (s *DenoService) PlanProject(project string, envVars map[string]string, owned []string) *plan.Plan {
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key != internal.OPENV_KEYS {
+			desired[key] = value
+		}
+	}
+	current := map[string]*string{}
+	for _, key := range owned {
+		current[key] = nil
+	}
+	return plan.Compute(fmt.Sprintf("Deno Deploy project %s", project), desired, plan.State{Values: current, Owned: owned})
+}
+
+// Redeploy redeploys the latest successful deployment of the project, so it picks up changed environment variables
+func (s *DenoService) Redeploy(project string) error {
+	deployments := []deployment{}
+	if err := s.deployClient.Do("GET", fmt.Sprintf("/projects/%s/deployments?limit=20", url.PathEscape(project)), nil, &deployments); err != nil {
+		return fmt.Errorf("failed to list Deno Deploy deployments: %w", err)
+	}
+
+	index := slices.IndexFunc(deployments, func(d deployment) bool {
+		return d.Status == "success"
+	})
+	if index < 0 {
+		return fmt.Errorf("no successful Deno Deploy deployment of %s to redeploy", project)
+	}
+
+	latest := deployments[index]
+	redeployed := deployment{}
+	if err := s.deployClient.Do("POST", fmt.Sprintf("/deployments/%s/redeploy", url.PathEscape(latest.ID)), map[string]any{}, &redeployed); err != nil {
+		return fmt.Errorf("failed to redeploy Deno Deploy deployment %s: %w", latest.ID, err)
+	}
+	logging.Logger.Info("Deno Deploy project redeployed", "project", project, "deployment", redeployed.ID)
+	return nil
+}
@@ -0,0 +1,57 @@
+package deno
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+)
+
+func patchedEnv(t *testing.T, sync func(*DenoService) error) map[string]*string {
+	t.Helper()
+	var changes map[string]*string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Path != "/projects/app/env" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	if err := sync(NewDenoService("token", server.URL, server.URL)); err != nil {
+		t.Fatalf("SyncToProject() error = %v", err)
+	}
+	return changes
+}
+
+func TestSyncToProjectDeletesOwnedKeys(t *testing.T) {
+	envVars := map[string]string{"KEPT": "1", internal.OPENV_KEYS: `["KEPT"]`}
+	changes := patchedEnv(t, func(s *DenoService) error {
+		return s.SyncToProject("app", envVars, []string{"KEPT", "REMOVED"}, true)
+	})
+
+	if len(changes) != 2 || changes["KEPT"] == nil || *changes["KEPT"] != "1" {
+		t.Errorf("changes = %v, want KEPT set and REMOVED deleted", changes)
+	}
+	if value, ok := changes["REMOVED"]; !ok || value != nil {
+		t.Errorf("REMOVED change = %v, want null", value)
+	}
+}
+
+func TestSyncToProjectWithoutCleanup(t *testing.T) {
+	changes := patchedEnv(t, func(s *DenoService) error {
+		return s.SyncToProject("app", map[string]string{"KEPT": "1"}, []string{"KEPT", "REMOVED"}, false)
+	})
+
+	if _, ok := changes["REMOVED"]; ok || len(changes) != 1 {
+		t.Errorf("changes = %v, want only KEPT", changes)
+	}
+}
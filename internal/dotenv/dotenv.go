@@ -0,0 +1,337 @@
+package dotenv
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ParseError describes a malformed line of a .env file
+type ParseError struct {
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Options configures how values are parsed
+type Options struct {
+	// Expand enables variable references in unquoted and double-quoted values, see Expand.
+	// References resolve to variables defined earlier in the file before falling back to Lookup.
+	Expand bool
+	// Lookup resolves references to variables not defined in the file, usually os.LookupEnv
+	Lookup LookupFunc
+	// Resolve is applied to every unquoted and double-quoted value after expansion,
+	// e.g. to resolve secret references. Single-quoted values are kept as written.
+	Resolve func(value string) (string, error)
+}
+
+// ParseFile reads and parses the .env file at the given path
+func ParseFile(filePath string, opts Options) (map[string]string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	vars, err := Parse(string(content), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse env file %s: %w", filePath, err)
+	}
+	return vars, nil
+}
+
+// Parse parses the content of a .env file into a map of variables.
+// It follows the conventions of the Node and Ruby dotenv libraries:
+//   - blank lines and lines starting with # are ignored
+//   - keys may be prefixed with "export" and separated from the value with = or :
+//   - unquoted values end at an inline comment (# at the start or after whitespace) and are trimmed
+//   - single-quoted and backtick-quoted values are taken literally and may span lines
+//   - double-quoted values may span lines and support the escapes \n, \r, \t, \\, \", \', \` and \$
+//
+// Later definitions of a key override earlier ones.
+// Malformed lines are reported as *ParseError with the line number.
+func Parse(content string, opts Options) (map[string]string, error) {
+	p := &parser{
+		src:  strings.ReplaceAll(content, "\r\n", "\n"),
+		line: 1,
+		vars: make(map[string]string),
+		opts: opts,
+	}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.vars, nil
+}
+
+type parser struct {
+	src  string
+	pos  int
+	line int
+	vars map[string]string
+	opts Options
+}
+
+func (p *parser) parse() error {
+	for !p.eof() {
+		p.skipBlanks()
+		switch {
+		case p.eof():
+			return nil
+		case p.peek() == '\n':
+			p.advance()
+		case p.peek() == '#':
+			p.skipComment()
+		default:
+			if err := p.parseAssignment(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseAssignment() error {
+	startLine := p.line
+
+	key := p.readKey()
+	if key == "export" && isBlank(p.peek()) {
+		afterKey := p.pos
+		p.skipBlanks()
+		if isKeyStart(p.peek()) {
+			key = p.readKey()
+		} else {
+			// A variable called export
+			p.pos = afterKey
+		}
+	}
+	if key == "" {
+		return p.errorf(startLine, "expected variable name, found %q", p.restOfLine())
+	}
+	if !isKeyStart(key[0]) {
+		return p.errorf(startLine, "invalid variable name %q", key)
+	}
+
+	p.skipBlanks()
+	switch {
+	case p.peek() == '=':
+		p.advance()
+	case p.peek() == ':' && (p.pos+1 >= len(p.src) || isBlank(p.src[p.pos+1]) || p.src[p.pos+1] == '\n'):
+		p.advance()
+	default:
+		return p.errorf(startLine, "expected = after variable name %s", key)
+	}
+	p.skipBlanks()
+
+	var (
+		value   string
+		literal bool
+		err     error
+	)
+	switch p.peek() {
+	case '"':
+		value, err = p.readDoubleQuoted()
+	case '\'', '`':
+		value, err = p.readLiteral(p.peek())
+		literal = true
+	default:
+		value, err = p.readUnquoted()
+	}
+	if err != nil {
+		return err
+	}
+
+	if !literal && p.opts.Resolve != nil {
+		value, err = p.opts.Resolve(value)
+		if err != nil {
+			return p.errorf(startLine, "failed to resolve value of %s: %v", key, err)
+		}
+	}
+
+	if err := p.finishLine(key); err != nil {
+		return err
+	}
+	p.vars[key] = value
+	return nil
+}
+
+func (p *parser) readKey() string {
+	start := p.pos
+	for !p.eof() && isKeyChar(p.peek()) {
+		p.advance()
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *parser) readUnquoted() (string, error) {
+	start := p.pos
+	for !p.eof() && p.peek() != '\n' {
+		// A value starting with # is empty, like in the Node and Ruby libraries
+		if p.peek() == '#' && (p.pos == start || isBlank(p.src[p.pos-1])) {
+			break
+		}
+		p.advance()
+	}
+	value := strings.TrimRight(p.src[start:p.pos], " \t")
+
+	if !p.opts.Expand {
+		return value, nil
+	}
+	expanded, err := Expand(value, p.lookup)
+	if err != nil {
+		return "", p.errorf(p.line, "%v", err)
+	}
+	return expanded, nil
+}
+
+// readLiteral reads a value enclosed in single quotes or backticks without any unescaping
+func (p *parser) readLiteral(quote byte) (string, error) {
+	startLine := p.line
+	p.advance()
+
+	start := p.pos
+	for !p.eof() && p.peek() != quote {
+		p.advance()
+	}
+	if p.eof() {
+		return "", p.errorf(startLine, "unterminated %c-quoted value", quote)
+	}
+	value := p.src[start:p.pos]
+	p.advance()
+	return value, nil
+}
+
+func (p *parser) readDoubleQuoted() (string, error) {
+	startLine := p.line
+	p.advance()
+
+	var value strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf(startLine, "unterminated double-quoted value")
+		}
+		c := p.advance()
+		switch c {
+		case '"':
+			return value.String(), nil
+		case '$':
+			if !p.opts.Expand {
+				value.WriteByte(c)
+				continue
+			}
+			expanded, next, err := expandReference(p.src, p.pos-1, p.lookup)
+			if err != nil {
+				return "", p.errorf(p.line, "%v", err)
+			}
+			value.WriteString(expanded)
+			for p.pos < next {
+				p.advance()
+			}
+		case '\\':
+			if p.eof() {
+				return "", p.errorf(startLine, "unterminated double-quoted value")
+			}
+			escaped := p.peek()
+			switch escaped {
+			case 'n':
+				value.WriteByte('\n')
+			case 'r':
+				value.WriteByte('\r')
+			case 't':
+				value.WriteByte('\t')
+			case '\\', '"', '\'', '`', '$':
+				value.WriteByte(escaped)
+			default:
+				// Unknown escapes are kept as written
+				value.WriteByte('\\')
+				continue
+			}
+			p.advance()
+		default:
+			value.WriteByte(c)
+		}
+	}
+}
+
+// finishLine consumes trailing whitespace and an optional comment after a value
+func (p *parser) finishLine(key string) error {
+	line := p.line
+	p.skipBlanks()
+	switch {
+	case p.eof():
+	case p.peek() == '\n':
+		p.advance()
+	case p.peek() == '#':
+		p.skipComment()
+	default:
+		return p.errorf(line, "unexpected %q after value of %s", p.restOfLine(), key)
+	}
+	return nil
+}
+
+// lookup resolves references to variables defined earlier in the file, then in the environment
+func (p *parser) lookup(key string) (string, bool) {
+	if value, ok := p.vars[key]; ok {
+		return value, true
+	}
+	if p.opts.Lookup == nil {
+		return "", false
+	}
+	return p.opts.Lookup(key)
+}
+
+func (p *parser) skipComment() {
+	for !p.eof() && p.peek() != '\n' {
+		p.advance()
+	}
+}
+
+func (p *parser) skipBlanks() {
+	for !p.eof() && isBlank(p.peek()) {
+		p.advance()
+	}
+}
+
+func (p *parser) restOfLine() string {
+	end := strings.IndexByte(p.src[p.pos:], '\n')
+	if end < 0 {
+		return p.src[p.pos:]
+	}
+	return p.src[p.pos : p.pos+end]
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) advance() byte {
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *parser) errorf(line int, format string, args ...any) error {
+	return &ParseError{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isKeyStart(c byte) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+func isKeyChar(c byte) bool {
+	return isKeyStart(c) || (c >= '0' && c <= '9') || c == '.' || c == '-'
+}
@@ -0,0 +1,177 @@
+package dotenv
+
+import (
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{
+			name:    "blank lines and comments",
+			content: "# comment\n\n  # indented comment\nKEY=value\n",
+			want:    map[string]string{"KEY": "value"},
+		},
+		{
+			name:    "export prefix",
+			content: "export KEY=value\nexport\tOTHER = other\n",
+			want:    map[string]string{"KEY": "value", "OTHER": "other"},
+		},
+		{
+			name:    "variable called export",
+			content: "export=value\n",
+			want:    map[string]string{"export": "value"},
+		},
+		{
+			name:    "colon separator",
+			content: "KEY: value\n",
+			want:    map[string]string{"KEY": "value"},
+		},
+		{
+			name:    "unquoted value is trimmed",
+			content: "KEY =   value with spaces  \t\n",
+			want:    map[string]string{"KEY": "value with spaces"},
+		},
+		{
+			name:    "inline comment after unquoted value",
+			content: "KEY=value # comment\nOTHER=value\t# comment\n",
+			want:    map[string]string{"KEY": "value", "OTHER": "value"},
+		},
+		{
+			name:    "hash inside unquoted value",
+			content: "KEY=value#not-a-comment\n",
+			want:    map[string]string{"KEY": "value#not-a-comment"},
+		},
+		{
+			name:    "comment as unquoted value",
+			content: "KEY= # comment\nOTHER=#x\n",
+			want:    map[string]string{"KEY": "", "OTHER": ""},
+		},
+		{
+			name:    "empty value",
+			content: "KEY=\nOTHER=",
+			want:    map[string]string{"KEY": "", "OTHER": ""},
+		},
+		{
+			name:    "inline comment after quoted values",
+			content: "A=\"a # b\" # comment\nB='a # b' # comment\nC=`a # b` # comment\n",
+			want:    map[string]string{"A": "a # b", "B": "a # b", "C": "a # b"},
+		},
+		{
+			name:    "multi-line quoted values",
+			content: "A=\"first\nsecond\"\nB='first\nsecond'\nC=`first\nsecond`\n",
+			want:    map[string]string{"A": "first\nsecond", "B": "first\nsecond", "C": "first\nsecond"},
+		},
+		{
+			name:    "double-quoted escapes",
+			content: `KEY="a\nb\rc\td\\e\"f\'g\` + "`" + `h\$i\xj"`,
+			want:    map[string]string{"KEY": "a\nb\rc\td\\e\"f'g`h$i\\xj"},
+		},
+		{
+			name:    "single-quoted escapes are kept",
+			content: `A='a\nb\$c'` + "\nB=`a\\nb`\n",
+			want:    map[string]string{"A": `a\nb\$c`, "B": `a\nb`},
+		},
+		{
+			name:    "windows line endings",
+			content: "A=1\r\nB=\"2\"\r\n",
+			want:    map[string]string{"A": "1", "B": "2"},
+		},
+		{
+			name:    "later definitions override earlier ones",
+			content: "KEY=first\nKEY=second\n",
+			want:    map[string]string{"KEY": "second"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.content, Options{})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		msg     string
+	}{
+		{
+			name:    "missing separator",
+			content: "A=1\n\nINVALID\n",
+			line:    3,
+			msg:     "expected = after variable name INVALID",
+		},
+		{
+			name:    "invalid variable name",
+			content: "A=1\n1KEY=value\n",
+			line:    2,
+			msg:     `invalid variable name "1KEY"`,
+		},
+		{
+			name:    "unterminated double quote",
+			content: "A=1\nB=\"open\nstill open\n",
+			line:    2,
+			msg:     "unterminated double-quoted value",
+		},
+		{
+			name:    "unterminated single quote",
+			content: "A='open",
+			line:    1,
+			msg:     "unterminated '-quoted value",
+		},
+		{
+			name:    "text after quoted value",
+			content: "A=\"multi\nline\" trailing\n",
+			line:    2,
+			msg:     `unexpected "trailing" after value of A`,
+		},
+		{
+			name:    "line after multi-line value",
+			content: "A='multi\nline'\nB\n",
+			line:    3,
+			msg:     "expected = after variable name B",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.content, Options{})
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Parse() error = %v, want *ParseError", err)
+			}
+			if parseErr.Line != tt.line || !strings.Contains(parseErr.Msg, tt.msg) {
+				t.Errorf("Parse() error = %v, want line %d: %s", err, tt.line, tt.msg)
+			}
+		})
+	}
+}
+
+func TestParseResolve(t *testing.T) {
+	content := "A=op://vault/item/a\nB=\"op://vault/item/b\"\nC='op://vault/item/c'\n"
+	got, err := Parse(content, Options{
+		Resolve: func(value string) (string, error) {
+			return strings.Replace(value, "op://", "resolved://", 1), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := map[string]string{"A": "resolved://vault/item/a", "B": "resolved://vault/item/b", "C": "op://vault/item/c"}
+	if !maps.Equal(got, want) {
+		t.Errorf("Parse() = %q, want %q", got, want)
+	}
+}
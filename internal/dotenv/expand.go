@@ -0,0 +1,229 @@
+package dotenv
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// LookupFunc returns the value of a variable and whether it is set, like os.LookupEnv
+type LookupFunc func(key string) (string, bool)
+
+// Expand replaces ${VAR}, $VAR, ${VAR:-default}, ${VAR-default}, ${VAR:?error} and ${VAR?error}
+// references in value using lookup. Unset variables expand to an empty string.
+// A backslash before $ prevents the expansion.
+func Expand(value string, lookup LookupFunc) (string, error) {
+	var result strings.Builder
+	for i := 0; i < len(value); {
+		switch {
+		case value[i] == '\\' && i+1 < len(value) && value[i+1] == '$':
+			result.WriteByte('$')
+			i += 2
+		case value[i] == '$':
+			expanded, next, err := expandReference(value, i, lookup)
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(expanded)
+			i = next
+		default:
+			result.WriteByte(value[i])
+			i++
+		}
+	}
+	return result.String(), nil
+}
+
+// ExpandAll expands the references in all values of vars.
+// References to other keys of vars are expanded recursively before falling back to lookup.
+// Returns an error if the references form a cycle.
+func ExpandAll(vars map[string]string, lookup LookupFunc) (map[string]string, error) {
+	e := &mapExpander{
+		vars:     vars,
+		lookup:   lookup,
+		expanded: make(map[string]string, len(vars)),
+		visiting: map[string]bool{},
+	}
+	for key := range vars {
+		if _, err := e.expand(key); err != nil {
+			return nil, fmt.Errorf("failed to expand %s: %w", key, err)
+		}
+	}
+	return e.expanded, nil
+}
+
+// ExpandAndResolveAll applies resolve to all values of vars, e.g. to resolve secret references,
+// and expands the references in the values resolve returned unchanged like ExpandAll.
+// Resolved values are taken literally, so a $ in a resolved secret is never expanded,
+// but the other values may reference them.
+func ExpandAndResolveAll(vars map[string]string, lookup LookupFunc, resolve func(value string) (string, error)) (map[string]string, error) {
+	resolved := map[string]string{}
+	unresolved := make(map[string]string, len(vars))
+	for key, value := range vars {
+		result, err := resolve(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve value of %s: %w", key, err)
+		}
+		if result == value {
+			unresolved[key] = value
+		} else {
+			resolved[key] = result
+		}
+	}
+
+	expanded, err := ExpandAll(unresolved, func(key string) (string, bool) {
+		if value, ok := resolved[key]; ok {
+			return value, true
+		}
+		return lookupValue(lookup, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(expanded, resolved)
+	return expanded, nil
+}
+
+type mapExpander struct {
+	vars     map[string]string
+	lookup   LookupFunc
+	expanded map[string]string
+	visiting map[string]bool
+	err      error
+}
+
+func (e *mapExpander) expand(key string) (string, error) {
+	if value, ok := e.expanded[key]; ok {
+		return value, nil
+	}
+	if e.visiting[key] {
+		return "", fmt.Errorf("reference cycle at %s", key)
+	}
+	e.visiting[key] = true
+	defer delete(e.visiting, key)
+
+	value, err := Expand(e.vars[key], e.lookupVar)
+	if err == nil {
+		err = e.err
+	}
+	if err != nil {
+		return "", err
+	}
+	e.expanded[key] = value
+	return value, nil
+}
+
+func (e *mapExpander) lookupVar(key string) (string, bool) {
+	if _, ok := e.vars[key]; ok {
+		value, err := e.expand(key)
+		if err != nil {
+			// LookupFunc cannot fail, keep the first error for expand to report
+			if e.err == nil {
+				e.err = err
+			}
+			return "", false
+		}
+		return value, true
+	}
+	if e.lookup == nil {
+		return "", false
+	}
+	return e.lookup(key)
+}
+
+// expandReference expands the reference starting with the $ at value[start].
+// Returns the expanded text and the index after the reference.
+func expandReference(value string, start int, lookup LookupFunc) (string, int, error) {
+	i := start + 1
+	if i >= len(value) {
+		return "$", i, nil
+	}
+
+	if value[i] != '{' {
+		end := i
+		for end < len(value) && isNameChar(value[end], end == i) {
+			end++
+		}
+		if end == i {
+			// A lone $ is taken literally
+			return "$", i, nil
+		}
+		resolved, _ := lookupValue(lookup, value[i:end])
+		return resolved, end, nil
+	}
+
+	// Find the matching closing brace, allowing nested references in the default value
+	depth := 0
+	end := -1
+	for j := i; j < len(value); j++ {
+		switch value[j] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		if depth == 0 {
+			end = j
+			break
+		}
+	}
+	if end < 0 {
+		return "", 0, fmt.Errorf("unterminated reference %q", value[start:])
+	}
+
+	body := value[i+1 : end]
+	nameEnd := 0
+	for nameEnd < len(body) && isNameChar(body[nameEnd], nameEnd == 0) {
+		nameEnd++
+	}
+	name := body[:nameEnd]
+	if name == "" {
+		return "", 0, fmt.Errorf("invalid reference %q", value[start:end+1])
+	}
+	resolved, isSet := lookupValue(lookup, name)
+
+	operator := body[nameEnd:]
+	if operator == "" {
+		return resolved, end + 1, nil
+	}
+
+	// With a colon, empty values are treated like unset ones
+	checkEmpty := strings.HasPrefix(operator, ":")
+	unset := !isSet || (checkEmpty && resolved == "")
+	operator = strings.TrimPrefix(operator, ":")
+
+	switch {
+	case strings.HasPrefix(operator, "-"):
+		if !unset {
+			return resolved, end + 1, nil
+		}
+		defaultValue, err := Expand(operator[1:], lookup)
+		return defaultValue, end + 1, err
+	case strings.HasPrefix(operator, "?"):
+		if !unset {
+			return resolved, end + 1, nil
+		}
+		message := operator[1:]
+		if message == "" {
+			message = "is not set"
+		}
+		return "", 0, errors.New(name + ": " + message)
+	default:
+		return "", 0, fmt.Errorf("unsupported reference %q", value[start:end+1])
+	}
+}
+
+func lookupValue(lookup LookupFunc, name string) (string, bool) {
+	if lookup == nil {
+		return "", false
+	}
+	return lookup(name)
+}
+
+func isNameChar(c byte, first bool) bool {
+	if first {
+		return isKeyStart(c)
+	}
+	return isKeyStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,99 @@
+package dotenv
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	onepassword "github.com/hinterland-software/openv/internal/1password"
+)
+
+// Marshal renders vars as .env content sorted by key.
+// The output is guaranteed to parse back to the same variables with Parse,
+// with or without expansion, for arbitrary byte strings as values.
+func Marshal(vars map[string]string) (string, error) {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		if !IsValidKey(key) {
+			return "", fmt.Errorf("cannot write variable %q: invalid variable name", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var content strings.Builder
+	for _, key := range keys {
+		content.WriteString(key)
+		content.WriteByte('=')
+		content.WriteString(Quote(vars[key]))
+		content.WriteByte('\n')
+	}
+	return content.String(), nil
+}
+
+// Quote returns value as written in a .env file.
+// Secret references like op://vault/item/field are single-quoted, or backtick-quoted if they
+// contain a single quote, so they are not resolved when imported again. References containing both
+// quote characters or a \r\n line break, which Parse reads as \n in quotes, are double-quoted like other values.
+// Values consisting only of safe characters are written unquoted,
+// all others are double-quoted with \, ", $, newlines and carriage returns escaped.
+func Quote(value string) string {
+	if onepassword.IsSecretReference(value) && !strings.Contains(value, "\r\n") {
+		switch {
+		case !strings.Contains(value, "'"):
+			return "'" + value + "'"
+		case !strings.Contains(value, "`"):
+			return "`" + value + "`"
+		}
+	}
+	if isSafeUnquoted(value) {
+		return value
+	}
+
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\':
+			quoted.WriteString(`\\`)
+		case '"':
+			quoted.WriteString(`\"`)
+		case '$':
+			quoted.WriteString(`\$`)
+		case '\n':
+			quoted.WriteString(`\n`)
+		case '\r':
+			quoted.WriteString(`\r`)
+		default:
+			quoted.WriteByte(c)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+// IsValidKey reports whether key can be used as a variable name in a .env file
+func IsValidKey(key string) bool {
+	if key == "" || !isKeyStart(key[0]) {
+		return false
+	}
+	for i := 1; i < len(key); i++ {
+		if !isKeyChar(key[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isSafeUnquoted(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("_-.,/:@%+=", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package dotenv
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func FuzzMarshalRoundTrip(f *testing.F) {
+	f.Add("API_KEY", "secret", "URL", "https://example.com/?a=1&b=2")
+	f.Add("MULTI_LINE", "first\nsecond\r\nthird", "QUOTES", `it's "quoted" and `+"`ticked`")
+	f.Add("PRICE", "$5 or ${PRICE:-6} \\$", "COMMENT", "value # not a comment")
+	f.Add("REFERENCE", "op://vault/item/field", "BOTH_QUOTES", "op://vault/it's/`field`")
+	f.Add("EMPTY", "", "SPACES", "  padded  ")
+	f.Add("export", "x", "1INVALID", "y")
+
+	f.Fuzz(func(t *testing.T, key1, value1, key2, value2 string) {
+		vars := map[string]string{key1: value1, key2: value2}
+		content, err := Marshal(vars)
+		if !IsValidKey(key1) || !IsValidKey(key2) {
+			if err == nil {
+				t.Fatalf("Marshal() of invalid key %q or %q succeeded", key1, key2)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+
+		for _, opts := range []Options{
+			{},
+			{Expand: true, Lookup: func(string) (string, bool) { return "from-env", true }},
+		} {
+			got, err := Parse(content, opts)
+			if err != nil {
+				t.Fatalf("Parse(%q, expand %t) error = %v", content, opts.Expand, err)
+			}
+			if !maps.Equal(got, vars) {
+				t.Fatalf("Parse(Marshal(%q), expand %t) = %q", vars, opts.Expand, got)
+			}
+		}
+	})
+}
+
+func TestMarshalDoesNotResolveSecretReferences(t *testing.T) {
+	vars := map[string]string{
+		"PLAIN":        "op://vault/item/field",
+		"SINGLE_QUOTE": "op://vault/it's/field",
+		"WITH_DOLLAR":  "op://vault/item/$field",
+	}
+	content, err := Marshal(vars)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	got, err := Parse(content, Options{
+		Expand: true,
+		Resolve: func(value string) (string, error) {
+			if strings.HasPrefix(value, "op://") {
+				t.Errorf("secret reference %q resolved on re-import", value)
+			}
+			return value, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !maps.Equal(got, vars) {
+		t.Errorf("Parse(Marshal()) = %q, want %q", got, vars)
+	}
+
+}
+
+func TestMarshalValuesContainingURLs(t *testing.T) {
+	vars := map[string]string{
+		"JSON":   "{\r\n  \"url\": \"https://example.com/?q=$1\"\r\n}",
+		"QUOTES": "see https://example.com/it's/`here`",
+	}
+	content, err := Marshal(vars)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `JSON="{\r\n  \"url\": \"https://example.com/?q=\$1\"\r\n}"` + "\n" + "QUOTES=\"see https://example.com/it's/`here`\"\n"
+	if content != want {
+		t.Errorf("Marshal() = %q, want %q", content, want)
+	}
+	got, err := Parse(content, Options{Expand: true})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !maps.Equal(got, vars) {
+		t.Errorf("Parse(Marshal()) = %q, want %q", got, vars)
+	}
+}
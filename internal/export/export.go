@@ -0,0 +1,256 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal/dotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format for exported environment variables
+type Format string
+
+const (
+	FormatDotenv     Format = "dotenv"
+	FormatJSON       Format = "json"
+	FormatYAML       Format = "yaml"
+	FormatTOML       Format = "toml"
+	FormatShell      Format = "shell"
+	FormatFish       Format = "fish"
+	FormatPowerShell Format = "powershell"
+	FormatDocker     Format = "docker"
+	FormatSystemd    Format = "systemd"
+	FormatTFVars     Format = "tfvars"
+
+	FormatKubernetes          Format = "kubernetes"
+	FormatKubernetesSecret    Format = "kubernetes-secret"
+	FormatKubernetesConfigMap Format = "kubernetes-configmap"
+)
+
+// Formats lists all supported output formats
+var Formats = []Format{
+	FormatDotenv,
+	FormatJSON,
+	FormatYAML,
+	FormatTOML,
+	FormatShell,
+	FormatFish,
+	FormatPowerShell,
+	FormatDocker,
+	FormatSystemd,
+	FormatTFVars,
+	FormatKubernetes,
+	FormatKubernetesSecret,
+	FormatKubernetesConfigMap,
+}
+
+// Options configures the rendered output
+type Options struct {
+	// Header lines are written as comments for formats that support them
+	Header []string
+	// Plain lists the variables that are not sensitive
+	Plain []string
+	// Kubernetes configures the manifests of the kubernetes formats
+	Kubernetes KubernetesOptions
+}
+
+var (
+	// identifierPattern matches variable names usable in shells
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// terraformPattern matches Terraform variable names
+	terraformPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+)
+
+// FormatsToStrings returns the names of all supported formats
+func FormatsToStrings() []string {
+	strings := []string{}
+	for _, f := range Formats {
+		strings = append(strings, string(f))
+	}
+	return strings
+}
+
+// ValidateFormat checks whether the given format is supported
+func ValidateFormat(format Format) error {
+	if !slices.Contains(Formats, format) {
+		return fmt.Errorf("%s is not a valid format (%s)", format, strings.Join(FormatsToStrings(), ", "))
+	}
+	return nil
+}
+
+// Render renders vars in the given format
+func Render(format Format, vars map[string]string, opts Options) ([]byte, error) {
+	if err := ValidateFormat(format); err != nil {
+		return nil, err
+	}
+
+	// JSON has no comments
+	var content bytes.Buffer
+	if format != FormatJSON && format != FormatTFVars {
+		writeComments(&content, opts.Header)
+	}
+
+	var (
+		body []byte
+		err  error
+	)
+	switch format {
+	case FormatDotenv:
+		var rendered string
+		rendered, err = dotenv.Marshal(vars)
+		body = []byte(rendered)
+	case FormatJSON:
+		body, err = renderJSON(vars)
+	case FormatYAML:
+		body, err = yaml.Marshal(vars)
+	case FormatTOML:
+		body, err = toml.Marshal(vars)
+	case FormatShell:
+		body, err = renderLines(vars, identifierPattern, func(key, value string) string {
+			return fmt.Sprintf("export %s=%s", key, quoteShell(value))
+		})
+	case FormatFish:
+		body, err = renderLines(vars, identifierPattern, func(key, value string) string {
+			return fmt.Sprintf("set -gx %s %s", key, quoteFish(value))
+		})
+	case FormatPowerShell:
+		body, err = renderLines(vars, identifierPattern, func(key, value string) string {
+			return fmt.Sprintf("$env:%s = %s", key, quotePowerShell(value))
+		})
+	case FormatDocker:
+		body, err = renderDocker(vars)
+	case FormatSystemd:
+		body, err = renderLines(vars, identifierPattern, func(key, value string) string {
+			return fmt.Sprintf("%s=%s", key, quoteSystemd(value))
+		})
+	case FormatTFVars:
+		body, err = renderTFVars(vars)
+	case FormatKubernetes:
+		body, err = renderKubernetes(vars, sensitive(opts.Plain), opts.Kubernetes, true, true)
+	case FormatKubernetesSecret:
+		body, err = renderKubernetes(vars, sensitive(opts.Plain), opts.Kubernetes, true, false)
+	case FormatKubernetesConfigMap:
+		body, err = renderKubernetes(vars, sensitive(opts.Plain), opts.Kubernetes, false, true)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", format, err)
+	}
+
+	content.Write(body)
+	return content.Bytes(), nil
+}
+
+func writeComments(content *bytes.Buffer, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	for _, line := range lines {
+		for _, l := range strings.Split(line, "\n") {
+			content.WriteString(fmt.Sprintf("# %s\n", l))
+		}
+	}
+	content.WriteString("\n")
+}
+
+func sensitive(plain []string) func(string) bool {
+	return func(key string) bool {
+		return !slices.Contains(plain, key)
+	}
+}
+
+func sortedKeys(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func renderJSON(vars map[string]string) ([]byte, error) {
+	body, err := json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
+
+// renderLines renders one line per variable, rejecting names that do not match pattern
+func renderLines(vars map[string]string, pattern *regexp.Regexp, line func(key, value string) string) ([]byte, error) {
+	var content bytes.Buffer
+	for _, key := range sortedKeys(vars) {
+		if !pattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid variable name %q", key)
+		}
+		content.WriteString(line(key, vars[key]))
+		content.WriteByte('\n')
+	}
+	return content.Bytes(), nil
+}
+
+// renderDocker renders a file for docker run --env-file, which takes values literally and cannot hold newlines
+func renderDocker(vars map[string]string) ([]byte, error) {
+	var content bytes.Buffer
+	for _, key := range sortedKeys(vars) {
+		value := vars[key]
+		if strings.ContainsAny(key, "= \t\n") || key == "" {
+			return nil, fmt.Errorf("invalid variable name %q", key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("value of %s contains a newline, which docker env files do not support", key)
+		}
+		content.WriteString(fmt.Sprintf("%s=%s\n", key, value))
+	}
+	return content.Bytes(), nil
+}
+
+// renderTFVars renders a Terraform .tfvars.json file
+func renderTFVars(vars map[string]string) ([]byte, error) {
+	for key := range vars {
+		if !terraformPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid Terraform variable name %q", key)
+		}
+	}
+	return renderJSON(vars)
+}
+
+// quoteShell quotes a value for POSIX shells using single quotes
+func quoteShell(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// quoteFish quotes a value for fish, where single quotes only support \\ and \' escapes
+func quoteFish(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// quotePowerShell quotes a value using a PowerShell verbatim string
+func quotePowerShell(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// quoteSystemd quotes a value for a systemd EnvironmentFile
+func quoteSystemd(value string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '"', '$', '`':
+			quoted.WriteByte('\\')
+			quoted.WriteByte(c)
+		default:
+			quoted.WriteByte(c)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
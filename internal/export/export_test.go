@@ -0,0 +1,123 @@
+package export
+
+import (
+	"strings"
+	"testing"
+)
+
+// quotingValues are values that need quoting or escaping in most formats
+var quotingValues = map[string]string{
+	"QUOTES":    `it's "quoted"`,
+	"DOLLAR":    "$HOME and ${PATH}",
+	"NEWLINE":   "first\nsecond",
+	"BACKSLASH": `C:\path\`,
+}
+
+func TestRenderQuoting(t *testing.T) {
+	tests := []struct {
+		format Format
+		want   map[string]string
+	}{
+		{
+			format: FormatShell,
+			want: map[string]string{
+				"QUOTES":    `export QUOTES='it'\''s "quoted"'`,
+				"DOLLAR":    `export DOLLAR='$HOME and ${PATH}'`,
+				"NEWLINE":   "export NEWLINE='first\nsecond'",
+				"BACKSLASH": `export BACKSLASH='C:\path\'`,
+			},
+		},
+		{
+			format: FormatFish,
+			want: map[string]string{
+				"QUOTES":    `set -gx QUOTES 'it\'s "quoted"'`,
+				"DOLLAR":    `set -gx DOLLAR '$HOME and ${PATH}'`,
+				"NEWLINE":   "set -gx NEWLINE 'first\nsecond'",
+				"BACKSLASH": `set -gx BACKSLASH 'C:\\path\\'`,
+			},
+		},
+		{
+			format: FormatPowerShell,
+			want: map[string]string{
+				"QUOTES":    `$env:QUOTES = 'it''s "quoted"'`,
+				"DOLLAR":    `$env:DOLLAR = '$HOME and ${PATH}'`,
+				"NEWLINE":   "$env:NEWLINE = 'first\nsecond'",
+				"BACKSLASH": `$env:BACKSLASH = 'C:\path\'`,
+			},
+		},
+		{
+			format: FormatSystemd,
+			want: map[string]string{
+				"QUOTES":    `QUOTES="it's \"quoted\""`,
+				"DOLLAR":    `DOLLAR="\$HOME and \${PATH}"`,
+				"NEWLINE":   "NEWLINE=\"first\nsecond\"",
+				"BACKSLASH": `BACKSLASH="C:\\path\\"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			for key, value := range quotingValues {
+				got, err := Render(tt.format, map[string]string{key: value}, Options{})
+				if err != nil {
+					t.Fatalf("Render(%s) error = %v", key, err)
+				}
+				if want := tt.want[key] + "\n"; string(got) != want {
+					t.Errorf("Render(%s) = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderDocker(t *testing.T) {
+	// docker run --env-file takes values literally
+	got, err := Render(FormatDocker, map[string]string{
+		"QUOTES":    quotingValues["QUOTES"],
+		"DOLLAR":    quotingValues["DOLLAR"],
+		"BACKSLASH": quotingValues["BACKSLASH"],
+	}, Options{})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := "BACKSLASH=C:\\path\\\nDOLLAR=$HOME and ${PATH}\nQUOTES=it's \"quoted\"\n"
+	if string(got) != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+
+	for _, value := range []string{"first\nsecond", "first\rsecond"} {
+		if _, err := Render(FormatDocker, map[string]string{"NEWLINE": value}, Options{}); err == nil {
+			t.Errorf("Render(%q) succeeded, docker env files cannot hold newlines", value)
+		}
+	}
+}
+
+func TestRenderInvalidNames(t *testing.T) {
+	for _, format := range []Format{FormatShell, FormatFish, FormatPowerShell, FormatSystemd, FormatDocker} {
+		if _, err := Render(format, map[string]string{"INVALID NAME": "value"}, Options{}); err == nil {
+			t.Errorf("Render(%s) of an invalid name succeeded", format)
+		}
+	}
+	if _, err := Render(FormatShell, map[string]string{"my-key": "value"}, Options{}); err == nil {
+		t.Error("Render(shell) of a name with a dash succeeded")
+	}
+}
+
+func TestRenderHeader(t *testing.T) {
+	got, err := Render(FormatShell, map[string]string{"KEY": "value"}, Options{Header: []string{"Generated by openv", "multi\nline"}})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := "# Generated by openv\n# multi\n# line\n\nexport KEY='value'\n"
+	if string(got) != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+
+	got, err = Render(FormatJSON, map[string]string{"KEY": "value"}, Options{Header: []string{"Generated by openv"}})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if strings.Contains(string(got), "#") {
+		t.Errorf("Render(json) = %q, want no comments", got)
+	}
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KubernetesOptions configures the rendered Kubernetes manifests
+type KubernetesOptions struct {
+	Name      string
+	Namespace string
+	Labels    map[string]string
+}
+
+var (
+	// kubernetesKeyPattern matches valid Secret and ConfigMap data keys
+	kubernetesKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	// invalidNameChars matches characters not allowed in DNS-1123 subdomain names
+	invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+)
+
+type kubernetesManifest struct {
+	APIVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   kubernetesMetadata `yaml:"metadata"`
+	Type       string             `yaml:"type,omitempty"`
+	Data       map[string]string  `yaml:"data"`
+}
+
+type kubernetesMetadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+// KubernetesName derives a valid resource name from parts, e.g. the repository and environment
+func KubernetesName(parts ...string) string {
+	name := strings.ToLower(strings.Join(parts, "-"))
+	name = invalidNameChars.ReplaceAllString(name, "-")
+	name = strings.Trim(name, "-.")
+	if len(name) > 253 {
+		name = strings.TrimRight(name[:253], "-.")
+	}
+	return name
+}
+
+// renderKubernetes renders a Secret holding the sensitive variables and a ConfigMap holding the plain ones.
+// withSecret and withConfigMap select which of the resources receive variables, if only one is selected it receives all.
+// Map keys are marshaled in sorted order, so the output is stable for GitOps diffs.
+func renderKubernetes(vars map[string]string, sensitive func(string) bool, opts KubernetesOptions, withSecret, withConfigMap bool) ([]byte, error) {
+	if opts.Name == "" {
+		return nil, fmt.Errorf("kubernetes resource name is required")
+	}
+
+	secretData := map[string]string{}
+	configData := map[string]string{}
+	for key, value := range vars {
+		if !kubernetesKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid Kubernetes data key %q", key)
+		}
+		switch {
+		case withSecret && (!withConfigMap || sensitive(key)):
+			secretData[key] = base64.StdEncoding.EncodeToString([]byte(value))
+		default:
+			configData[key] = value
+		}
+	}
+
+	metadata := kubernetesMetadata{
+		Name:      opts.Name,
+		Namespace: opts.Namespace,
+		Labels:    opts.Labels,
+	}
+
+	manifests := []kubernetesManifest{}
+	if withSecret && (len(secretData) > 0 || !withConfigMap) {
+		manifests = append(manifests, kubernetesManifest{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata:   metadata,
+			Type:       "Opaque",
+			Data:       secretData,
+		})
+	}
+	if withConfigMap && (len(configData) > 0 || !withSecret) {
+		manifests = append(manifests, kubernetesManifest{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Metadata:   metadata,
+			Data:       configData,
+		})
+	}
+
+	var content bytes.Buffer
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	for _, manifest := range manifests {
+		if err := encoder.Encode(manifest); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
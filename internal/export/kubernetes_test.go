@@ -0,0 +1,139 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func decodeManifests(t *testing.T, content []byte) []kubernetesManifest {
+	t.Helper()
+	manifests := []kubernetesManifest{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var manifest kubernetesManifest
+		err := decoder.Decode(&manifest)
+		if errors.Is(err, io.EOF) {
+			return manifests
+		} else if err != nil {
+			t.Fatalf("invalid manifest: %v", err)
+		}
+		manifests = append(manifests, manifest)
+	}
+}
+
+func TestRenderKubernetes(t *testing.T) {
+	vars := map[string]string{
+		"API_KEY":    "secret\nvalue",
+		"PUBLIC_URL": "https://example.com",
+		"LOG_LEVEL":  "debug",
+	}
+	opts := Options{
+		Plain:      []string{"PUBLIC_URL", "LOG_LEVEL"},
+		Kubernetes: KubernetesOptions{Name: "app-production", Namespace: "apps", Labels: map[string]string{"app": "app"}},
+	}
+	content, err := Render(FormatKubernetes, vars, opts)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	manifests := decodeManifests(t, content)
+	if len(manifests) != 2 || manifests[0].Kind != "Secret" || manifests[1].Kind != "ConfigMap" {
+		t.Fatalf("Render() = %s, want a Secret and a ConfigMap", content)
+	}
+	secret, configMap := manifests[0], manifests[1]
+	if secret.Type != "Opaque" || secret.Metadata.Name != "app-production" || secret.Metadata.Namespace != "apps" || secret.Metadata.Labels["app"] != "app" {
+		t.Errorf("Secret metadata = %+v type %s", secret.Metadata, secret.Type)
+	}
+	if len(secret.Data) != 1 {
+		t.Errorf("Secret data = %v, want only API_KEY", secret.Data)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(secret.Data["API_KEY"])
+	if err != nil || string(decoded) != vars["API_KEY"] {
+		t.Errorf("Secret API_KEY = %q (%v), want the base64 encoded value", secret.Data["API_KEY"], err)
+	}
+	if len(configMap.Data) != 2 || configMap.Data["PUBLIC_URL"] != vars["PUBLIC_URL"] || configMap.Data["LOG_LEVEL"] != vars["LOG_LEVEL"] {
+		t.Errorf("ConfigMap data = %v, want the plain values unencoded", configMap.Data)
+	}
+}
+
+func TestRenderKubernetesDeterministic(t *testing.T) {
+	vars := map[string]string{}
+	for _, key := range []string{"ZETA", "ALPHA", "MIDDLE", "BETA", "OMEGA", "GAMMA", "DELTA", "EPSILON"} {
+		vars[key] = key + "-value"
+	}
+	opts := Options{
+		Plain:      []string{"ALPHA", "OMEGA"},
+		Kubernetes: KubernetesOptions{Name: "app", Labels: map[string]string{"b": "2", "a": "1", "c": "3"}},
+	}
+
+	for _, format := range []Format{FormatKubernetes, FormatKubernetesSecret, FormatKubernetesConfigMap} {
+		first, err := Render(format, vars, opts)
+		if err != nil {
+			t.Fatalf("Render(%s) error = %v", format, err)
+		}
+		for range 20 {
+			next, err := Render(format, vars, opts)
+			if err != nil {
+				t.Fatalf("Render(%s) error = %v", format, err)
+			}
+			if !bytes.Equal(first, next) {
+				t.Fatalf("Render(%s) is not deterministic:\n%s\n---\n%s", format, first, next)
+			}
+		}
+	}
+}
+
+func TestRenderKubernetesSingleResource(t *testing.T) {
+	vars := map[string]string{"API_KEY": "secret", "PUBLIC_URL": "https://example.com"}
+	opts := Options{Plain: []string{"PUBLIC_URL"}, Kubernetes: KubernetesOptions{Name: "app"}}
+
+	content, err := Render(FormatKubernetesSecret, vars, opts)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	manifests := decodeManifests(t, content)
+	if len(manifests) != 1 || manifests[0].Kind != "Secret" || len(manifests[0].Data) != 2 {
+		t.Errorf("Render(kubernetes-secret) = %s, want one Secret with all variables", content)
+	}
+
+	content, err = Render(FormatKubernetesConfigMap, vars, opts)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	manifests = decodeManifests(t, content)
+	if len(manifests) != 1 || manifests[0].Kind != "ConfigMap" || manifests[0].Data["API_KEY"] != "secret" {
+		t.Errorf("Render(kubernetes-configmap) = %s, want one ConfigMap with all variables", content)
+	}
+}
+
+func TestRenderKubernetesValidation(t *testing.T) {
+	for _, key := range []string{"INVALID KEY", "path/key", "key=value", ""} {
+		if _, err := Render(FormatKubernetes, map[string]string{key: "value"}, Options{Kubernetes: KubernetesOptions{Name: "app"}}); err == nil {
+			t.Errorf("Render() of data key %q succeeded", key)
+		}
+	}
+	if _, err := Render(FormatKubernetes, map[string]string{"KEY": "value"}, Options{}); err == nil {
+		t.Error("Render() without a resource name succeeded")
+	}
+}
+
+func TestKubernetesName(t *testing.T) {
+	tests := []struct {
+		parts []string
+		want  string
+	}{
+		{parts: []string{"My_App", "Production"}, want: "my-app-production"},
+		{parts: []string{"-app", "staging."}, want: "app-staging"},
+		{parts: []string{"app@org", "dev"}, want: "app-org-dev"},
+	}
+	for _, tt := range tests {
+		if got := KubernetesName(tt.parts...); got != tt.want {
+			t.Errorf("KubernetesName(%q) = %q, want %q", tt.parts, got, tt.want)
+		}
+	}
+}
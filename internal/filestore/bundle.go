@@ -0,0 +1,213 @@
+package filestore
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// bundleVersion is the version of the encrypted file format.
+// Version 2 repeats the recipients in the encrypted payload.
+const bundleVersion = 2
+
+// Recipient is a public key allowed to decrypt a bundle
+type Recipient struct {
+	Name      string `json:"name,omitempty"`
+	PublicKey string `json:"public_key"`
+}
+
+// bundle is the decrypted content of an encrypted environment file
+type bundle struct {
+	Recipients   []Recipient
+	Environments map[string]entry
+}
+
+// entry is a single stored environment
+type entry struct {
+	Name         string            `json:"name"`
+	URL          string            `json:"url"`
+	Env          string            `json:"env"`
+	SyncProfiles []string          `json:"sync_profiles,omitempty"`
+	Variables    map[string]string `json:"variables"`
+	Plain        []string          `json:"plain,omitempty"`
+	Expanded     bool              `json:"expanded,omitempty"`
+	// Owned maps write-only sync targets to the keys openv synced to them
+	Owned map[string][]string `json:"owned,omitempty"`
+}
+
+// encryptedFile is the on-disk representation of a bundle.
+// The payload is encrypted with a random data key, which is sealed for every recipient.
+// The recipients are listed in plain text so they can be shown without an identity,
+// the payload holds the authoritative copy.
+type encryptedFile struct {
+	Version    int                  `json:"version"`
+	Recipients []encryptedRecipient `json:"recipients"`
+	Nonce      string               `json:"nonce"`
+	Payload    string               `json:"payload"`
+}
+
+type encryptedRecipient struct {
+	Recipient
+	Key string `json:"key"`
+}
+
+type payload struct {
+	Recipients   []Recipient      `json:"recipients"`
+	Environments map[string]entry `json:"environments"`
+}
+
+func readEncryptedFile(path string) (*encryptedFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file encryptedFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted file %s: %w", path, err)
+	}
+	if file.Version != bundleVersion {
+		return nil, fmt.Errorf("unsupported encrypted file version %d", file.Version)
+	}
+	return &file, nil
+}
+
+// readBundle reads and decrypts the bundle at path using the given identity
+func readBundle(path string, identity *Identity) (*bundle, error) {
+	file, err := readEncryptedFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient := identity.Recipient()
+	var sealedKey string
+	listed := make([]Recipient, 0, len(file.Recipients))
+	for _, r := range file.Recipients {
+		listed = append(listed, r.Recipient)
+		if r.PublicKey == recipient {
+			sealedKey = r.Key
+		}
+	}
+	if sealedKey == "" {
+		return nil, fmt.Errorf("identity %s is not a recipient of %s", recipient, path)
+	}
+
+	sealedKeyBytes, err := base64.StdEncoding.DecodeString(sealedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data key: %w", err)
+	}
+	dataKeyBytes, ok := box.OpenAnonymous(nil, sealedKeyBytes, identity.publicKey, identity.privateKey)
+	if !ok || len(dataKeyBytes) != 32 {
+		return nil, fmt.Errorf("failed to decrypt data key of %s", path)
+	}
+	var dataKey [32]byte
+	copy(dataKey[:], dataKeyBytes)
+
+	nonceBytes, err := base64.StdEncoding.DecodeString(file.Nonce)
+	if err != nil || len(nonceBytes) != 24 {
+		return nil, fmt.Errorf("invalid nonce in %s", path)
+	}
+	var nonce [24]byte
+	copy(nonce[:], nonceBytes)
+
+	encrypted, err := base64.StdEncoding.DecodeString(file.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode payload: %w", err)
+	}
+	decrypted, ok := secretbox.Open(nil, encrypted, &nonce, &dataKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt payload of %s", path)
+	}
+
+	var content payload
+	if err := json.Unmarshal(decrypted, &content); err != nil {
+		return nil, fmt.Errorf("failed to parse payload of %s: %w", path, err)
+	}
+	if content.Environments == nil {
+		content.Environments = map[string]entry{}
+	}
+	// A recipient edited into the plain text list must never receive the data key on the next write.
+	// This is a consistency check only: sealed keys are anonymous, so anyone able to write the file
+	// can replace it with a consistent bundle for other recipients.
+	if !slices.Equal(listed, content.Recipients) {
+		return nil, fmt.Errorf("recipients listed in %s do not match its encrypted recipients", path)
+	}
+
+	return &bundle{Recipients: content.Recipients, Environments: content.Environments}, nil
+}
+
+// writeBundle encrypts the bundle with a fresh data key and writes it to path
+func writeBundle(path string, b *bundle) error {
+	if len(b.Recipients) == 0 {
+		return errors.New("encrypted file needs at least one recipient")
+	}
+
+	var dataKey [32]byte
+	if _, err := rand.Read(dataKey[:]); err != nil {
+		return fmt.Errorf("failed to generate data key: %w", err)
+	}
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	file := encryptedFile{
+		Version: bundleVersion,
+		Nonce:   base64.StdEncoding.EncodeToString(nonce[:]),
+	}
+	for _, r := range b.Recipients {
+		publicKey, err := ParseRecipient(r.PublicKey)
+		if err != nil {
+			return err
+		}
+		sealedKey, err := box.SealAnonymous(nil, dataKey[:], publicKey, rand.Reader)
+		if err != nil {
+			return fmt.Errorf("failed to seal data key for %s: %w", r.PublicKey, err)
+		}
+		file.Recipients = append(file.Recipients, encryptedRecipient{
+			Recipient: r,
+			Key:       base64.StdEncoding.EncodeToString(sealedKey),
+		})
+	}
+
+	plaintext, err := json.Marshal(payload{Recipients: b.Recipients, Environments: b.Environments})
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	file.Payload = base64.StdEncoding.EncodeToString(secretbox.Seal(nil, plaintext, &nonce, &dataKey))
+
+	content, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal encrypted file: %w", err)
+	}
+
+	// Write to a temporary file first so a failed write never corrupts the bundle
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write encrypted file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write encrypted file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set permissions of encrypted file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace encrypted file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package filestore
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+)
+
+const (
+	// identityPrefix marks an encoded private key
+	identityPrefix = "OPENV-SECRET-KEY-"
+	// recipientPrefix marks an encoded public key
+	recipientPrefix = "openv-"
+)
+
+// Identity is a key pair able to decrypt bundles it is a recipient of
+type Identity struct {
+	publicKey  *[32]byte
+	privateKey *[32]byte
+}
+
+// GenerateIdentity creates a new random identity
+func GenerateIdentity() (*Identity, error) {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key pair: %w", err)
+	}
+	return &Identity{publicKey: publicKey, privateKey: privateKey}, nil
+}
+
+// ParseIdentity parses an identity previously encoded with Identity.String
+func ParseIdentity(s string) (*Identity, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, identityPrefix) {
+		return nil, fmt.Errorf("invalid identity: missing %s prefix", identityPrefix)
+	}
+	privateKey, err := decodeKey(strings.TrimPrefix(s, identityPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity: %w", err)
+	}
+
+	publicKeyBytes, err := curve25519.X25519(privateKey[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity: %w", err)
+	}
+	publicKey := new([32]byte)
+	copy(publicKey[:], publicKeyBytes)
+
+	return &Identity{publicKey: publicKey, privateKey: privateKey}, nil
+}
+
+// ReadIdentityFile reads an identity from a file
+func ReadIdentityFile(path string) (*Identity, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity file: %w", err)
+	}
+	return ParseIdentity(string(content))
+}
+
+// WriteIdentityFile writes an identity to a file readable only by the current user
+func WriteIdentityFile(path string, identity *Identity) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("identity file %s already exists", path)
+	}
+	if err := os.WriteFile(path, []byte(identity.String()+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write identity file: %w", err)
+	}
+	return nil
+}
+
+// String encodes the private key of the identity
+func (i *Identity) String() string {
+	return identityPrefix + encodeKey(i.privateKey)
+}
+
+// Recipient returns the encoded public key of the identity
+func (i *Identity) Recipient() string {
+	return EncodeRecipient(i.publicKey)
+}
+
+// ParseRecipient parses a public key previously encoded with EncodeRecipient
+func ParseRecipient(s string) (*[32]byte, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, recipientPrefix) {
+		return nil, fmt.Errorf("invalid recipient: missing %s prefix", recipientPrefix)
+	}
+	publicKey, err := decodeKey(strings.TrimPrefix(s, recipientPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+	return publicKey, nil
+}
+
+// EncodeRecipient encodes a public key
+func EncodeRecipient(publicKey *[32]byte) string {
+	return recipientPrefix + encodeKey(publicKey)
+}
+
+func encodeKey(key *[32]byte) string {
+	return base64.RawURLEncoding.EncodeToString(key[:])
+}
+
+func decodeKey(s string) (*[32]byte, error) {
+	keyBytes, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyBytes) != 32 {
+		return nil, fmt.Errorf("expected 32 byte key, got %d bytes", len(keyBytes))
+	}
+	key := new([32]byte)
+	copy(key[:], keyBytes)
+	return key, nil
+}
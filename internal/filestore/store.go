@@ -0,0 +1,250 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/hinterland-software/openv/internal/store"
+)
+
+// DefaultPath is the default location of the encrypted environment file
+const DefaultPath = ".openv.enc"
+
+// Store is a SecretStore that keeps environments in a local encrypted file.
+// Each environment is keyed by its URL and env tag, like the 1Password items.
+type Store struct {
+	path     string
+	identity *Identity
+}
+
+var _ store.SecretStore = (*Store)(nil)
+
+// NewStore creates a new encrypted file store at path, decrypting with the given identity
+func NewStore(path string, identity *Identity) *Store {
+	return &Store{path: path, identity: identity}
+}
+
+// GetEnvironment retrieves the environment stored for a URL and environment name
+func (s *Store) GetEnvironment(opts store.GetEnvironmentOptions) (*store.Environment, error) {
+	b, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	key := entryKey(opts.URL, opts.Env)
+	e, ok := b.Environments[key]
+	if !ok {
+		return nil, store.NotFoundError(opts.URL, opts.Env)
+	}
+	return e.toEnvironment(key, true), nil
+}
+
+// Put creates or replaces the environment for the given URL and environment name
+func (s *Store) Put(opts store.PutOptions) (*store.Environment, error) {
+	b, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop a previous entry with the same name and env stored under another URL, keeping its owned keys
+	var owned map[string][]string
+	for key, e := range b.Environments {
+		if e.Name == opts.Name && e.Env == opts.Env {
+			owned = e.Owned
+			delete(b.Environments, key)
+		}
+	}
+
+	key := entryKey(opts.URL, opts.Env)
+	e := entry{
+		Name:         opts.Name,
+		URL:          opts.URL,
+		Env:          opts.Env,
+		SyncProfiles: slices.Clone(opts.SyncProfiles),
+		Variables:    maps.Clone(opts.Variables),
+		Plain:        slices.Clone(opts.Plain),
+		Expanded:     opts.Expanded,
+		Owned:        owned,
+	}
+	if e.Variables == nil {
+		e.Variables = map[string]string{}
+	}
+	b.Environments[key] = e
+
+	if err := writeBundle(s.path, b); err != nil {
+		return nil, err
+	}
+	return e.toEnvironment(key, true), nil
+}
+
+// FindExistingItem looks for an environment with the same name and environment
+func (s *Store) FindExistingItem(name, env string) (*store.Environment, error) {
+	b, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for key, e := range b.Environments {
+		if e.Name == name && e.Env == env {
+			return e.toEnvironment(key, true), nil
+		}
+	}
+	return nil, nil
+}
+
+// ListEnvironments returns the metadata of all stored environments
+func (s *Store) ListEnvironments() ([]store.Environment, error) {
+	b, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := slices.Collect(maps.Keys(b.Environments))
+	sort.Strings(keys)
+
+	environments := make([]store.Environment, 0, len(keys))
+	for _, key := range keys {
+		environments = append(environments, *b.Environments[key].toEnvironment(key, false))
+	}
+	return environments, nil
+}
+
+// Delete removes the environment stored for a URL and environment name
+func (s *Store) Delete(opts store.GetEnvironmentOptions) error {
+	b, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	key := entryKey(opts.URL, opts.Env)
+	if _, ok := b.Environments[key]; !ok {
+		return store.NotFoundError(opts.URL, opts.Env)
+	}
+	delete(b.Environments, key)
+	return writeBundle(s.path, b)
+}
+
+// SetOwned records the keys synced to a target
+func (s *Store) SetOwned(opts store.GetEnvironmentOptions, target string, keys []string) error {
+	b, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	key := entryKey(opts.URL, opts.Env)
+	e, ok := b.Environments[key]
+	if !ok {
+		return store.NotFoundError(opts.URL, opts.Env)
+	}
+	if e.Owned == nil {
+		e.Owned = map[string][]string{}
+	}
+	e.Owned[target] = store.OwnedKeys(keys)
+	b.Environments[key] = e
+	return writeBundle(s.path, b)
+}
+
+// Recipients returns the recipients of the encrypted file.
+// Reading recipients does not require an identity, so the list is not verified against the encrypted copy.
+func (s *Store) Recipients() ([]Recipient, error) {
+	file, err := readEncryptedFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Recipient{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	recipients := make([]Recipient, 0, len(file.Recipients))
+	for _, r := range file.Recipients {
+		recipients = append(recipients, r.Recipient)
+	}
+	return recipients, nil
+}
+
+// AddRecipient allows another public key to decrypt the file and re-encrypts it
+func (s *Store) AddRecipient(name, publicKey string) error {
+	if _, err := ParseRecipient(publicKey); err != nil {
+		return err
+	}
+
+	b, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range b.Recipients {
+		if r.PublicKey == publicKey {
+			return fmt.Errorf("recipient %s already exists", publicKey)
+		}
+		if name != "" && r.Name == name {
+			return fmt.Errorf("recipient with name %s already exists", name)
+		}
+	}
+
+	b.Recipients = append(b.Recipients, Recipient{Name: name, PublicKey: publicKey})
+	return writeBundle(s.path, b)
+}
+
+// RemoveRecipient removes a recipient by name or public key and re-encrypts the file with a new data key
+func (s *Store) RemoveRecipient(nameOrPublicKey string) error {
+	b, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	index := slices.IndexFunc(b.Recipients, func(r Recipient) bool {
+		return r.PublicKey == nameOrPublicKey || (r.Name != "" && r.Name == nameOrPublicKey)
+	})
+	if index < 0 {
+		return fmt.Errorf("recipient %s not found", nameOrPublicKey)
+	}
+	if len(b.Recipients) == 1 {
+		return fmt.Errorf("cannot remove the last recipient of %s", s.path)
+	}
+
+	b.Recipients = slices.Delete(b.Recipients, index, index+1)
+	return writeBundle(s.path, b)
+}
+
+// load decrypts the file, returning an empty bundle owned by the current identity if it does not exist yet
+func (s *Store) load() (*bundle, error) {
+	if s.identity == nil {
+		return nil, errors.New("no identity configured to decrypt the environment file")
+	}
+
+	b, err := readBundle(s.path, s.identity)
+	if errors.Is(err, os.ErrNotExist) {
+		return &bundle{
+			Recipients:   []Recipient{{PublicKey: s.identity.Recipient()}},
+			Environments: map[string]entry{},
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func entryKey(url, env string) string {
+	return fmt.Sprintf("%s env:%s", url, env)
+}
+
+func (e entry) toEnvironment(key string, withVariables bool) *store.Environment {
+	environment := &store.Environment{
+		ID:           key,
+		Name:         e.Name,
+		URL:          e.URL,
+		Env:          e.Env,
+		SyncProfiles: slices.Clone(e.SyncProfiles),
+		Expanded:     e.Expanded,
+	}
+	if withVariables {
+		environment.Variables = maps.Clone(e.Variables)
+		environment.Plain = slices.Clone(e.Plain)
+		environment.Owned = store.CloneOwned(e.Owned)
+	}
+	return environment
+}
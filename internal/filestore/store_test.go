@@ -0,0 +1,157 @@
+package filestore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal/store"
+	"github.com/hinterland-software/openv/internal/store/storetest"
+)
+
+func newIdentity(t *testing.T) *Identity {
+	t.Helper()
+	identity, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity() error = %v", err)
+	}
+	return identity
+}
+
+func putEnvironment(t *testing.T, s *Store) {
+	t.Helper()
+	if _, err := s.Put(store.PutOptions{Name: "app", Env: "production", URL: "github.com/org/app", Variables: map[string]string{"API_KEY": "secret"}}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+}
+
+func getEnvironment(s *Store) (*store.Environment, error) {
+	return s.GetEnvironment(store.GetEnvironmentOptions{URL: "github.com/org/app", Env: "production"})
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.SecretStore {
+		return NewStore(filepath.Join(t.TempDir(), DefaultPath), newIdentity(t))
+	})
+}
+
+func TestRecipientRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultPath)
+	owner, runner := newIdentity(t), newIdentity(t)
+	ownerStore, runnerStore := NewStore(path, owner), NewStore(path, runner)
+	putEnvironment(t, ownerStore)
+
+	if _, err := getEnvironment(runnerStore); err == nil {
+		t.Fatal("GetEnvironment() of a non-recipient succeeded")
+	}
+
+	if err := ownerStore.AddRecipient("ci-runner", runner.Recipient()); err != nil {
+		t.Fatalf("AddRecipient() error = %v", err)
+	}
+	if err := ownerStore.AddRecipient("again", runner.Recipient()); err == nil {
+		t.Error("AddRecipient() of an existing recipient succeeded")
+	}
+	got, err := getEnvironment(runnerStore)
+	if err != nil {
+		t.Fatalf("GetEnvironment() of an added recipient error = %v", err)
+	}
+	if got.Variables["API_KEY"] != "secret" {
+		t.Errorf("Variables = %v", got.Variables)
+	}
+	// Writes by the added recipient keep all recipients
+	putEnvironment(t, runnerStore)
+	recipients, err := ownerStore.Recipients()
+	if err != nil || len(recipients) != 2 {
+		t.Fatalf("Recipients() = %v, %v, want 2 recipients", recipients, err)
+	}
+
+	if err := ownerStore.RemoveRecipient("ci-runner"); err != nil {
+		t.Fatalf("RemoveRecipient() error = %v", err)
+	}
+	if _, err := getEnvironment(runnerStore); err == nil {
+		t.Error("GetEnvironment() of a removed recipient succeeded")
+	}
+	if _, err := getEnvironment(ownerStore); err != nil {
+		t.Errorf("GetEnvironment() of the remaining recipient error = %v", err)
+	}
+	if err := ownerStore.RemoveRecipient(owner.Recipient()); err == nil {
+		t.Error("RemoveRecipient() of the last recipient succeeded")
+	}
+}
+
+func TestMismatchedRecipients(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(file *encryptedFile, attacker *Identity)
+	}{
+		{
+			name: "added recipient",
+			tamper: func(file *encryptedFile, attacker *Identity) {
+				file.Recipients = append(file.Recipients, encryptedRecipient{
+					Recipient: Recipient{Name: "attacker", PublicKey: attacker.Recipient()},
+					Key:       "Z2FyYmFnZQ==",
+				})
+			},
+		},
+		{
+			name: "replaced recipient",
+			tamper: func(file *encryptedFile, attacker *Identity) {
+				file.Recipients[1].PublicKey = attacker.Recipient()
+			},
+		},
+		{
+			name: "renamed recipient",
+			tamper: func(file *encryptedFile, attacker *Identity) {
+				file.Recipients[1].Name = "admin"
+			},
+		},
+		{
+			name: "removed recipient",
+			tamper: func(file *encryptedFile, attacker *Identity) {
+				file.Recipients = file.Recipients[:1]
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), DefaultPath)
+			owner, runner, attacker := newIdentity(t), newIdentity(t), newIdentity(t)
+			ownerStore := NewStore(path, owner)
+			putEnvironment(t, ownerStore)
+			if err := ownerStore.AddRecipient("ci-runner", runner.Recipient()); err != nil {
+				t.Fatalf("AddRecipient() error = %v", err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file := encryptedFile{}
+			if err := json.Unmarshal(content, &file); err != nil {
+				t.Fatal(err)
+			}
+			tt.tamper(&file, attacker)
+			content, err = json.Marshal(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = getEnvironment(ownerStore)
+			if err == nil || !strings.Contains(err.Error(), "do not match") {
+				t.Fatalf("GetEnvironment() error = %v, want mismatched recipients error", err)
+			}
+			// A write must not seal the data key for the attacker
+			if _, err := ownerStore.Put(store.PutOptions{Name: "app", Env: "staging", URL: "github.com/org/app"}); err == nil {
+				t.Fatal("Put() on a file with mismatched recipients succeeded")
+			}
+			if _, err := getEnvironment(NewStore(path, attacker)); err == nil {
+				t.Error("GetEnvironment() of the attacker succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package fly
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// DefaultBaseURL is the base URL of the Fly.io Machines API
+const DefaultBaseURL = "https://api.machines.dev"
+
+type secret struct {
+	Name   string `json:"name"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// FlyService handles syncing environment variables to Fly.io app secrets.
+// Secret values cannot be read back, so the caller keeps track of the keys openv owns.
+type FlyService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewFlyService creates a new FlyService, an empty baseURL uses DefaultBaseURL
+func NewFlyService(token, baseURL string) *FlyService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client := rest.NewClient(ctx, baseURL, map[string]string{
+		"Authorization": "Bearer " + token,
+	})
+	return &FlyService{client: client, ctx: ctx}
+}
+
+// SyncToApp sets the secrets of a Fly.io app and, with cleanup, unsets the owned keys no longer in envVars.
+// All changes are sent in one request, which does not create a release.
+// Machines pick up the secrets on their next deploy.
+func (s *FlyService) SyncToApp(appName string, envVars map[string]string, owned []string, withCleanup bool) error {
+	existing, err := s.listSecrets(appName)
+	if err != nil {
+		return err
+	}
+
+	// A null value unsets the secret
+	values := map[string]*string{}
+	for key, value := range envVars {
+		if key != internal.OPENV_KEYS {
+			values[key] = &value
+		}
+	}
+	removed := []string{}
+	if withCleanup {
+		for _, key := range owned {
+			if _, desired := envVars[key]; !desired && slices.Contains(existing, key) {
+				values[key] = nil
+				removed = append(removed, key)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	logging.Logger.Debug("syncing to Fly.io app secrets", "app", appName, "removed", removed)
+
+	if err := s.client.Do("POST", secretsPath(appName), map[string]any{"values": values}, nil); err != nil {
+		return fmt.Errorf("failed to update Fly.io secrets: %w", err)
+	}
+	return nil
+}
+
+// PlanApp computes the changes SyncToApp would apply, existing secrets cannot be read
+func (s *FlyService) PlanApp(appName string, envVars map[string]string, owned []string) (*plan.Plan, error) {
+	existing, err := s.listSecrets(appName)
+	if err != nil {
+		return nil, err
+	}
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key != internal.OPENV_KEYS {
+			desired[key] = value
+		}
+	}
+	current := map[string]*string{}
+	for _, name := range existing {
+		current[name] = nil
+	}
+	return plan.Compute(fmt.Sprintf("Fly.io secrets of %s", appName), desired, plan.State{Values: current, Owned: owned}), nil
+}
+
+// listSecrets returns the names of the secrets of an app
+func (s *FlyService) listSecrets(appName string) ([]string, error) {
+	if appName == "" {
+		return nil, fmt.Errorf("no Fly.io app configured, set the profile URL to the app name")
+	}
+	response := struct {
+		Secrets []secret `json:"secrets"`
+	}{}
+	if err := s.client.Do("GET", secretsPath(appName), nil, &response); err != nil {
+		if rest.IsNotFound(err) {
+			return nil, fmt.Errorf("Fly.io app %s not found", appName)
+		}
+		return nil, fmt.Errorf("failed to list Fly.io secrets of %s: %w", appName, err)
+	}
+	names := make([]string, 0, len(response.Secrets))
+	for _, existing := range response.Secrets {
+		names = append(names, existing.Name)
+	}
+	return names, nil
+}
+
+func secretsPath(appName string) string {
+	return fmt.Sprintf("/v1/apps/%s/secrets", url.PathEscape(appName))
+}
@@ -0,0 +1,131 @@
+package fly
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// fakeFly serves the app secrets endpoints of the Machines API for the app "app"
+type fakeFly struct {
+	t       *testing.T
+	secrets map[string]string
+	updates []map[string]*string
+}
+
+func newFakeFly(t *testing.T, secrets ...string) (*fakeFly, *FlyService) {
+	f := &fakeFly{t: t, secrets: map[string]string{}}
+	for _, name := range secrets {
+		f.secrets[name] = "existing"
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, NewFlyService("token", server.URL)
+}
+
+func (f *fakeFly) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/apps/app/secrets" || r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case "GET":
+		list := []secret{}
+		for name := range f.secrets {
+			list = append(list, secret{Name: name, Digest: "digest"})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"secrets": list})
+	case "POST":
+		body := struct {
+			Values map[string]*string `json:"values"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Errorf("invalid body: %v", err)
+		}
+		f.updates = append(f.updates, body.Values)
+		for name, value := range body.Values {
+			if value == nil {
+				delete(f.secrets, name)
+			} else {
+				f.secrets[name] = *value
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"version": len(f.updates)})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestSyncToApp(t *testing.T) {
+	f, service := newFakeFly(t, "KEPT", "REMOVED", "UNMANAGED")
+
+	err := service.SyncToApp("app", map[string]string{
+		"KEPT":              "1",
+		"CREATED":           "2",
+		internal.OPENV_KEYS: `["CREATED","KEPT"]`,
+	}, []string{"KEPT", "REMOVED", "ALREADY_GONE"}, true)
+	if err != nil {
+		t.Fatalf("SyncToApp() error = %v", err)
+	}
+
+	if len(f.updates) != 1 {
+		t.Fatalf("SyncToApp() sent %d updates, want all changes in one request", len(f.updates))
+	}
+	want := map[string]string{"KEPT": "1", "CREATED": "2", "UNMANAGED": "existing"}
+	if len(f.secrets) != len(want) {
+		t.Errorf("secrets = %v, want %v", f.secrets, want)
+	}
+	for name, value := range want {
+		if f.secrets[name] != value {
+			t.Errorf("secret %s = %q, want %q", name, f.secrets[name], value)
+		}
+	}
+	if _, sent := f.updates[0]["ALREADY_GONE"]; sent {
+		t.Error("SyncToApp() unset a secret that does not exist")
+	}
+}
+
+func TestSyncToAppWithoutCleanup(t *testing.T) {
+	f, service := newFakeFly(t, "REMOVED")
+
+	if err := service.SyncToApp("app", map[string]string{"KEPT": "1"}, []string{"REMOVED"}, false); err != nil {
+		t.Fatalf("SyncToApp() error = %v", err)
+	}
+	if _, ok := f.secrets["REMOVED"]; !ok {
+		t.Error("REMOVED was unset without cleanup")
+	}
+}
+
+func TestSyncToAppMissingApp(t *testing.T) {
+	_, service := newFakeFly(t)
+	if err := service.SyncToApp("other", map[string]string{"A": "1"}, nil, true); err == nil {
+		t.Error("SyncToApp() of a missing app succeeded")
+	}
+}
+
+func TestPlanApp(t *testing.T) {
+	_, service := newFakeFly(t, "KEPT", "REMOVED", "UNMANAGED")
+
+	p, err := service.PlanApp("app", map[string]string{"KEPT": "1", "CREATED": "2"}, []string{"KEPT", "REMOVED"})
+	if err != nil {
+		t.Fatalf("PlanApp() error = %v", err)
+	}
+	got := map[string]plan.Action{}
+	for _, change := range p.Changes {
+		got[change.Key] = change.Action
+	}
+	want := map[string]plan.Action{"KEPT": plan.ActionUpdate, "CREATED": plan.ActionCreate, "REMOVED": plan.ActionDelete}
+	if len(got) != len(want) {
+		t.Errorf("plan = %v, want %v", got, want)
+	}
+	for key, action := range want {
+		if got[key] != action {
+			t.Errorf("plan action of %s = %s, want %s", key, got[key], action)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+const (
+	apiPath  = "/api/v1"
+	pageSize = 50
+)
+
+type secret struct {
+	Name string `json:"name"`
+}
+
+type variable struct {
+	Name string `json:"name"`
+	Data string `json:"data"`
+}
+
+// actions is a repository or organization owning Actions secrets and variables
+type actions struct {
+	name string
+	path string
+}
+
+// GiteaService handles syncing environment variables to Gitea and Forgejo Actions
+type GiteaService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewGiteaService creates a new GiteaService for the Gitea or Forgejo instance at baseURL, e.g. https://codeberg.org
+func NewGiteaService(token, baseURL string) *GiteaService {
+	ctx := context.Background()
+	client := rest.NewClient(ctx, strings.TrimSuffix(baseURL, "/")+apiPath, map[string]string{
+		"Authorization": "token " + token,
+	})
+	return &GiteaService{client: client, ctx: ctx}
+}
+
+// SyncToRepoSecret syncs environment variables to Actions secrets of a repository
+func (s *GiteaService) SyncToRepoSecret(owner, repo string, envVars map[string]string, withCleanup bool) error {
+	return s.syncSecrets(repoActions(owner, repo), internal.OPENV_KEYS_REPO_SECRETS, envVars, withCleanup)
+}
+
+// SyncToOrgSecret syncs environment variables to Actions secrets of an organization
+func (s *GiteaService) SyncToOrgSecret(org string, envVars map[string]string, withCleanup bool) error {
+	return s.syncSecrets(orgActions(org), internal.OPENV_KEYS_ORG_SECRETS, envVars, withCleanup)
+}
+
+// SyncToRepoVariable syncs environment variables to Actions variables of a repository
+func (s *GiteaService) SyncToRepoVariable(owner, repo string, envVars map[string]string, withCleanup bool) error {
+	return s.syncVariables(repoActions(owner, repo), internal.OPENV_KEYS_REPO_VARIABLES, envVars, withCleanup)
+}
+
+// SyncToOrgVariable syncs environment variables to Actions variables of an organization
+func (s *GiteaService) SyncToOrgVariable(org string, envVars map[string]string, withCleanup bool) error {
+	return s.syncVariables(orgActions(org), internal.OPENV_KEYS_ORG_VARIABLES, envVars, withCleanup)
+}
+
+// PlanRepoSecret computes the changes SyncToRepoSecret would apply
+func (s *GiteaService) PlanRepoSecret(owner, repo string, envVars map[string]string) (*plan.Plan, error) {
+	return s.planSecrets(repoActions(owner, repo), internal.OPENV_KEYS_REPO_SECRETS, envVars)
+}
+
+// PlanOrgSecret computes the changes SyncToOrgSecret would apply
+func (s *GiteaService) PlanOrgSecret(org string, envVars map[string]string) (*plan.Plan, error) {
+	return s.planSecrets(orgActions(org), internal.OPENV_KEYS_ORG_SECRETS, envVars)
+}
+
+// PlanRepoVariable computes the changes SyncToRepoVariable would apply
+func (s *GiteaService) PlanRepoVariable(owner, repo string, envVars map[string]string) (*plan.Plan, error) {
+	return s.planVariables(repoActions(owner, repo), internal.OPENV_KEYS_REPO_VARIABLES, envVars)
+}
+
+// PlanOrgVariable computes the changes SyncToOrgVariable would apply
+func (s *GiteaService) PlanOrgVariable(org string, envVars map[string]string) (*plan.Plan, error) {
+	return s.planVariables(orgActions(org), internal.OPENV_KEYS_ORG_VARIABLES, envVars)
+}
+
+// syncSecrets writes secrets, the keys are recorded in the openvKey variable as secrets cannot be read
+func (s *GiteaService) syncSecrets(a actions, openvKey string, envVars map[string]string, withCleanup bool) error {
+	variables, err := s.listVariables(a)
+	if err != nil {
+		return err
+	}
+
+	logging.Logger.Debug("syncing to Gitea secrets", "target", a.name)
+
+	if withCleanup {
+		for _, removed := range removedKeys(variables[openvKey], envVars) {
+			if err := s.client.Do("DELETE", a.path+"/secrets/"+url.PathEscape(removed), nil, nil); err != nil && !rest.IsNotFound(err) {
+				logging.Logger.Warn("failed to delete Gitea secret", "key", removed, "error", err)
+			}
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(envVars)) {
+		if key == internal.OPENV_KEYS {
+			if err := s.setVariable(a, variables, openvKey, envVars[key]); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.client.Do("PUT", a.path+"/secrets/"+url.PathEscape(key), map[string]string{"data": envVars[key]}, nil); err != nil {
+			return fmt.Errorf("failed to sync Gitea secret %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (s *GiteaService) syncVariables(a actions, openvKey string, envVars map[string]string, withCleanup bool) error {
+	variables, err := s.listVariables(a)
+	if err != nil {
+		return err
+	}
+
+	logging.Logger.Debug("syncing to Gitea variables", "target", a.name)
+
+	if withCleanup {
+		for _, removed := range removedKeys(variables[openvKey], envVars) {
+			if _, exists := variables[strings.ToUpper(removed)]; !exists {
+				continue
+			}
+			if err := s.client.Do("DELETE", a.path+"/variables/"+url.PathEscape(removed), nil, nil); err != nil {
+				logging.Logger.Warn("failed to delete Gitea variable", "key", removed, "error", err)
+			}
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(envVars)) {
+		name := key
+		if key == internal.OPENV_KEYS {
+			name = openvKey
+		}
+		if err := s.setVariable(a, variables, name, envVars[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setVariable creates or updates a variable unless it already has the value
+func (s *GiteaService) setVariable(a actions, variables map[string]*string, name, value string) error {
+	path := a.path + "/variables/" + url.PathEscape(name)
+	current, exists := variables[strings.ToUpper(name)]
+	var err error
+	switch {
+	case !exists:
+		err = s.client.Do("POST", path, map[string]string{"value": value}, nil)
+	case *current != value:
+		err = s.client.Do("PUT", path, map[string]string{"name": name, "value": value}, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to sync Gitea variable %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *GiteaService) planSecrets(a actions, openvKey string, envVars map[string]string) (*plan.Plan, error) {
+	variables, err := s.listVariables(a)
+	if err != nil {
+		return nil, err
+	}
+	secrets := map[string]*string{}
+	for page := 1; ; page++ {
+		names := []secret{}
+		if err := s.client.Do("GET", fmt.Sprintf("%s/secrets?page=%d&limit=%d", a.path, page, pageSize), nil, &names); err != nil {
+			return nil, fmt.Errorf("failed to list Gitea secrets: %w", err)
+		}
+		for _, existing := range names {
+			secrets[strings.ToUpper(existing.Name)] = nil
+		}
+		if len(names) < pageSize {
+			break
+		}
+	}
+	return planSync(fmt.Sprintf("Gitea secrets of %s", a.name), openvKey, envVars, secrets, variables), nil
+}
+
+func (s *GiteaService) planVariables(a actions, openvKey string, envVars map[string]string) (*plan.Plan, error) {
+	variables, err := s.listVariables(a)
+	if err != nil {
+		return nil, err
+	}
+	return planSync(fmt.Sprintf("Gitea variables of %s", a.name), openvKey, envVars, variables, variables), nil
+}
+
+// planSync computes the changes of a sync, comparing names in upper case like Gitea does
+func planSync(target, openvKey string, envVars map[string]string, existing, variables map[string]*string) *plan.Plan {
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = openvKey
+		}
+		desired[strings.ToUpper(key)] = value
+	}
+
+	current := maps.Clone(existing)
+	owned := []string{}
+	if value, ok := variables[openvKey]; ok {
+		current[openvKey] = value
+		for _, key := range internal.ParseKeys(*value) {
+			owned = append(owned, strings.ToUpper(key))
+		}
+	}
+	return plan.Compute(target, desired, plan.State{Values: current, Owned: owned})
+}
+
+// listVariables returns the values of the variables by upper-case name, as Gitea stores names in upper case
+func (s *GiteaService) listVariables(a actions) (map[string]*string, error) {
+	if a.path == "" {
+		return nil, fmt.Errorf("no Gitea repository or organization configured, set the profile URL to <host>/<owner>[/<repo>]")
+	}
+	existing := map[string]*string{}
+	for page := 1; ; page++ {
+		variables := []variable{}
+		if err := s.client.Do("GET", fmt.Sprintf("%s/variables?page=%d&limit=%d", a.path, page, pageSize), nil, &variables); err != nil {
+			return nil, fmt.Errorf("failed to list Gitea variables: %w", err)
+		}
+		for _, v := range variables {
+			existing[strings.ToUpper(v.Name)] = &v.Data
+		}
+		if len(variables) < pageSize {
+			return existing, nil
+		}
+	}
+}
+
+func repoActions(owner, repo string) actions {
+	if owner == "" || repo == "" {
+		return actions{}
+	}
+	return actions{
+		name: owner + "/" + repo,
+		path: fmt.Sprintf("/repos/%s/%s/actions", url.PathEscape(owner), url.PathEscape(repo)),
+	}
+}
+
+func orgActions(org string) actions {
+	if org == "" {
+		return actions{}
+	}
+	return actions{name: org, path: fmt.Sprintf("/orgs/%s/actions", url.PathEscape(org))}
+}
+
+// DeriveLocationFromURL splits a URL like codeberg.org/owner/repo into the instance URL, the owner and the repository.
+// The repository is empty for URLs of an organization. URLs without a scheme use https.
+func DeriveLocationFromURL(location string) (string, string, string, error) {
+	if !strings.Contains(location, "://") {
+		location = "https://" + location
+	}
+	parsed, err := url.Parse(location)
+	if err != nil || parsed.Host == "" {
+		return "", "", "", fmt.Errorf("invalid Gitea URL: %s", location)
+	}
+	parts := strings.Split(strings.Trim(strings.TrimSuffix(parsed.Path, ".git"), "/"), "/")
+	baseURL := parsed.Scheme + "://" + parsed.Host
+	switch len(parts) {
+	case 1:
+		return baseURL, parts[0], "", nil
+	case 2:
+		return baseURL, parts[0], parts[1], nil
+	default:
+		return "", "", "", fmt.Errorf("invalid Gitea URL: %s", location)
+	}
+}
+
+// removedKeys returns the keys stored in the bookkeeping value that are not present in envVars.
+// Keys are compared in upper case, as a key that only changed its case still names the same secret or variable.
+func removedKeys(bookkeeping *string, envVars map[string]string) []string {
+	if bookkeeping == nil {
+		return []string{}
+	}
+	desired := map[string]bool{}
+	for key := range envVars {
+		desired[strings.ToUpper(key)] = true
+	}
+	removed := []string{}
+	for _, key := range internal.ParseKeys(*bookkeeping) {
+		if !desired[strings.ToUpper(key)] {
+			removed = append(removed, key)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,253 @@
+package gitea
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// fakeGitea serves the Actions secrets and variables of one repository or organization.
+// Like Gitea, it stores names in upper case and matches them case-insensitively.
+type fakeGitea struct {
+	t *testing.T
+	// path is the path of the Actions endpoints, e.g. /api/v1/repos/owner/repo/actions
+	path      string
+	mu        sync.Mutex
+	variables map[string]string
+	secrets   map[string]string
+	// requests lists the requests changing secrets or variables
+	requests []string
+}
+
+func newFakeGitea(t *testing.T, path string, variables map[string]string, secrets ...string) (*fakeGitea, *GiteaService) {
+	f := &fakeGitea{t: t, path: apiPath + path, variables: variables, secrets: map[string]string{}}
+	for _, name := range secrets {
+		f.secrets[name] = "secret"
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, NewGiteaService("token", server.URL+"/")
+}
+
+func (f *fakeGitea) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "token token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	rest, found := strings.CutPrefix(r.URL.Path, f.path+"/")
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	kind, name, _ := strings.Cut(rest, "/")
+	values := map[string]map[string]string{"variables": f.variables, "secrets": f.secrets}[kind]
+	if values == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method != "GET" {
+		f.requests = append(f.requests, r.Method+" "+kind+"/"+name)
+	}
+	name = strings.ToUpper(name)
+	_, exists := values[name]
+
+	var body struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		Data  string `json:"data"`
+	}
+	if r.Method == "POST" || r.Method == "PUT" {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Errorf("invalid request body: %v", err)
+		}
+	}
+
+	switch {
+	case r.Method == "GET" && name == "":
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		names := slices.Sorted(maps.Keys(values))
+		start := min((page-1)*limit, len(names))
+		listed := []variable{}
+		for _, name := range names[start:min(start+limit, len(names))] {
+			listed = append(listed, variable{Name: name, Data: values[name]})
+		}
+		writeJSON(w, listed)
+	case r.Method == "POST" && kind == "variables":
+		if exists {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		values[name] = body.Value
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "PUT" && kind == "variables":
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		values[name] = body.Value
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "PUT" && kind == "secrets":
+		values[name] = body.Data
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "DELETE":
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(values, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestSyncToRepoVariable(t *testing.T) {
+	f, service := newFakeGitea(t, "/repos/owner/repo/actions", map[string]string{
+		internal.OPENV_KEYS_REPO_VARIABLES: `["api_url","REMOVED","UNCHANGED","UPDATED"]`,
+		"API_URL":                          "https://example.com",
+		"REMOVED":                          "gone",
+		"UNCHANGED":                        "same",
+		"UPDATED":                          "old",
+		"UNMANAGED":                        "keep",
+	})
+
+	err := service.SyncToRepoVariable("owner", "repo", map[string]string{
+		"API_URL":           "https://example.com",
+		"UNCHANGED":         "same",
+		"updated":           "new",
+		"ADDED":             "value",
+		internal.OPENV_KEYS: `["ADDED","API_URL","UNCHANGED","updated"]`,
+	}, true)
+	if err != nil {
+		t.Fatalf("SyncToRepoVariable() error = %v", err)
+	}
+
+	// api_url only changed its case and updated exists in upper case, neither is deleted or created again
+	want := []string{
+		"DELETE variables/REMOVED",
+		"POST variables/ADDED",
+		"PUT variables/" + internal.OPENV_KEYS_REPO_VARIABLES,
+		"PUT variables/updated",
+	}
+	if !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %v, want %v", f.requests, want)
+	}
+	if got := f.variables["UPDATED"]; got != "new" {
+		t.Errorf("UPDATED = %s, want new", got)
+	}
+	if _, exists := f.variables["UNMANAGED"]; !exists {
+		t.Error("UNMANAGED was deleted")
+	}
+}
+
+func TestSyncToOrgSecret(t *testing.T) {
+	f, service := newFakeGitea(t, "/orgs/my-org/actions", map[string]string{
+		internal.OPENV_KEYS_ORG_SECRETS: `["Kept","REMOVED","DELETED_IN_GITEA"]`,
+	}, "KEPT", "REMOVED", "UNMANAGED")
+
+	err := service.SyncToOrgSecret("my-org", map[string]string{
+		"KEPT":              "new",
+		"ADDED":             "value",
+		internal.OPENV_KEYS: `["ADDED","KEPT"]`,
+	}, true)
+	if err != nil {
+		t.Fatalf("SyncToOrgSecret() error = %v", err)
+	}
+
+	want := []string{
+		"DELETE secrets/REMOVED",
+		"DELETE secrets/DELETED_IN_GITEA",
+		"PUT secrets/ADDED",
+		"PUT secrets/KEPT",
+		"PUT variables/" + internal.OPENV_KEYS_ORG_SECRETS,
+	}
+	if !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %v, want %v", f.requests, want)
+	}
+	if want := []string{"ADDED", "KEPT", "UNMANAGED"}; !slices.Equal(slices.Sorted(maps.Keys(f.secrets)), want) {
+		t.Errorf("secrets = %v, want %v", slices.Sorted(maps.Keys(f.secrets)), want)
+	}
+	if got := f.variables[internal.OPENV_KEYS_ORG_SECRETS]; got != `["ADDED","KEPT"]` {
+		t.Errorf("bookkeeping variable = %s, want [\"ADDED\",\"KEPT\"]", got)
+	}
+}
+
+func TestPlanRepoSecret(t *testing.T) {
+	_, service := newFakeGitea(t, "/repos/owner/repo/actions", map[string]string{
+		internal.OPENV_KEYS_REPO_SECRETS: `["token","REMOVED"]`,
+	}, "TOKEN", "REMOVED", "UNMANAGED")
+
+	p, err := service.PlanRepoSecret("owner", "repo", map[string]string{
+		"token":             "secret",
+		"added":             "value",
+		internal.OPENV_KEYS: `["added","token"]`,
+	})
+	if err != nil {
+		t.Fatalf("PlanRepoSecret() error = %v", err)
+	}
+	want := []plan.Change{
+		{Key: "ADDED", Action: plan.ActionCreate},
+		{Key: internal.OPENV_KEYS_REPO_SECRETS, Action: plan.ActionUpdate},
+		{Key: "REMOVED", Action: plan.ActionDelete},
+		{Key: "TOKEN", Action: plan.ActionUpdate, Reason: "current value cannot be read"},
+	}
+	if !slices.Equal(p.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", p.Changes, want)
+	}
+}
+
+func TestRemovedKeys(t *testing.T) {
+	bookkeeping := `["api_url","KEPT","REMOVED","removed_lower"]`
+	got := removedKeys(&bookkeeping, map[string]string{"API_URL": "", "KEPT": ""})
+	if want := []string{"REMOVED", "removed_lower"}; !slices.Equal(got, want) {
+		t.Errorf("removedKeys() = %v, want %v", got, want)
+	}
+	if got := removedKeys(nil, map[string]string{}); len(got) != 0 {
+		t.Errorf("removedKeys(nil) = %v, want none", got)
+	}
+}
+
+func TestDeriveLocationFromURL(t *testing.T) {
+	tests := []struct {
+		location string
+		baseURL  string
+		owner    string
+		repo     string
+	}{
+		{"codeberg.org/owner/repo", "https://codeberg.org", "owner", "repo"},
+		{"http://localhost:3000/owner/repo.git", "http://localhost:3000", "owner", "repo"},
+		{"https://gitea.example.com/my-org/", "https://gitea.example.com", "my-org", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			baseURL, owner, repo, err := DeriveLocationFromURL(tt.location)
+			if err != nil {
+				t.Fatalf("DeriveLocationFromURL() error = %v", err)
+			}
+			if baseURL != tt.baseURL || owner != tt.owner || repo != tt.repo {
+				t.Errorf("DeriveLocationFromURL() = %s, %s, %s, want %s, %s, %s", baseURL, owner, repo, tt.baseURL, tt.owner, tt.repo)
+			}
+		})
+	}
+	if _, _, _, err := DeriveLocationFromURL("codeberg.org/owner/repo/extra"); err == nil {
+		t.Error("DeriveLocationFromURL() error = nil, want invalid URL")
+	}
+}
@@ -0,0 +1,164 @@
+package github
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/google/go-github/v69/github"
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// PlanOrgSecret computes the changes SyncToOrgSecret would apply
+func (s *GitHubService) PlanOrgSecret(org string, envVars map[string]string) (*plan.Plan, error) {
+	secrets, err := listSecretNames(func(opts *github.ListOptions) (*github.Secrets, *github.Response, error) {
+		return s.client.Actions.ListOrgSecrets(s.ctx, org, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization secrets: %w", err)
+	}
+	variables, err := listVariableValues(func(opts *github.ListOptions) (*github.ActionsVariables, *github.Response, error) {
+		return s.client.Actions.ListOrgVariables(s.ctx, org, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization variables: %w", err)
+	}
+	target := fmt.Sprintf("GitHub organization secrets of %s", org)
+	return planSync(target, internal.OPENV_KEYS_ORG_SECRETS, envVars, secrets, variables), nil
+}
+
+// PlanRepoSecret computes the changes SyncToRepoSecret would apply
+func (s *GitHubService) PlanRepoSecret(owner, repo string, envVars map[string]string) (*plan.Plan, error) {
+	secrets, err := listSecretNames(func(opts *github.ListOptions) (*github.Secrets, *github.Response, error) {
+		return s.client.Actions.ListRepoSecrets(s.ctx, owner, repo, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository secrets: %w", err)
+	}
+	variables, err := listVariableValues(func(opts *github.ListOptions) (*github.ActionsVariables, *github.Response, error) {
+		return s.client.Actions.ListRepoVariables(s.ctx, owner, repo, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository variables: %w", err)
+	}
+	target := fmt.Sprintf("GitHub repository secrets of %s/%s", owner, repo)
+	return planSync(target, internal.OPENV_KEYS_REPO_SECRETS, envVars, secrets, variables), nil
+}
+
+// PlanRepoEnvironmentSecret computes the changes SyncToRepoEnvironmentSecret would apply
+func (s *GitHubService) PlanRepoEnvironmentSecret(owner, repoName, env string, envVars map[string]string) (*plan.Plan, error) {
+	repo, _, err := s.client.Repositories.Get(s.ctx, owner, repoName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+
+	secrets, err := listSecretNames(func(opts *github.ListOptions) (*github.Secrets, *github.Response, error) {
+		return s.client.Actions.ListEnvSecrets(s.ctx, int(repo.GetID()), env, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository environment secrets: %w", err)
+	}
+	variables, err := listVariableValues(func(opts *github.ListOptions) (*github.ActionsVariables, *github.Response, error) {
+		return s.client.Actions.ListEnvVariables(s.ctx, owner, repoName, env, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository environment variables: %w", err)
+	}
+	target := fmt.Sprintf("GitHub environment secrets of %s/%s (%s)", owner, repoName, env)
+	return planSync(target, internal.OPENV_KEYS_REPO_ENVIRONMENT_SECRETS, envVars, secrets, variables), nil
+}
+
+// PlanOrgVariable computes the changes SyncToOrgVariable would apply
+func (s *GitHubService) PlanOrgVariable(org string, envVars map[string]string) (*plan.Plan, error) {
+	variables, err := listVariableValues(func(opts *github.ListOptions) (*github.ActionsVariables, *github.Response, error) {
+		return s.client.Actions.ListOrgVariables(s.ctx, org, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization variables: %w", err)
+	}
+	target := fmt.Sprintf("GitHub organization variables of %s", org)
+	return planSync(target, internal.OPENV_KEYS_ORG_VARIABLES, envVars, variables, variables), nil
+}
+
+// PlanRepoVariable computes the changes SyncToRepoVariable would apply
+func (s *GitHubService) PlanRepoVariable(owner, repo string, envVars map[string]string) (*plan.Plan, error) {
+	variables, err := listVariableValues(func(opts *github.ListOptions) (*github.ActionsVariables, *github.Response, error) {
+		return s.client.Actions.ListRepoVariables(s.ctx, owner, repo, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository variables: %w", err)
+	}
+	target := fmt.Sprintf("GitHub repository variables of %s/%s", owner, repo)
+	return planSync(target, internal.OPENV_KEYS_REPO_VARIABLES, envVars, variables, variables), nil
+}
+
+// PlanRepoEnvironmentVariable computes the changes SyncToRepoEnvironmentVariable would apply
+func (s *GitHubService) PlanRepoEnvironmentVariable(owner, repoName, env string, envVars map[string]string) (*plan.Plan, error) {
+	variables, err := listVariableValues(func(opts *github.ListOptions) (*github.ActionsVariables, *github.Response, error) {
+		return s.client.Actions.ListEnvVariables(s.ctx, owner, repoName, env, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository environment variables: %w", err)
+	}
+	target := fmt.Sprintf("GitHub environment variables of %s/%s (%s)", owner, repoName, env)
+	return planSync(target, internal.OPENV_KEYS_REPO_ENVIRONMENT_VARIABLES, envVars, variables, variables), nil
+}
+
+// planSync compares envVars with the existing secrets or variables of a target.
+// The OPENV_KEYS entry is planned as the bookkeeping variable openvKey, which is always stored as a variable.
+func planSync(target, openvKey string, envVars map[string]string, existing, variables map[string]*string) *plan.Plan {
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = openvKey
+		}
+		desired[key] = value
+	}
+
+	current := maps.Clone(existing)
+	owned := []string{}
+	if value, ok := variables[openvKey]; ok {
+		current[openvKey] = value
+		owned = internal.ParseKeys(*value)
+	}
+
+	return plan.Compute(target, desired, plan.State{Values: current, Owned: owned})
+}
+
+// listSecretNames lists all secrets of a target, secret values cannot be read and are nil
+func listSecretNames(list func(opts *github.ListOptions) (*github.Secrets, *github.Response, error)) (map[string]*string, error) {
+	names := map[string]*string{}
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		secrets, resp, err := list(opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range secrets.Secrets {
+			names[secret.Name] = nil
+		}
+		if resp.NextPage == 0 {
+			return names, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// listVariableValues lists all variables of a target with their values
+func listVariableValues(list func(opts *github.ListOptions) (*github.ActionsVariables, *github.Response, error)) (map[string]*string, error) {
+	values := map[string]*string{}
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		variables, resp, err := list(opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, variable := range variables.Variables {
+			values[variable.Name] = &variable.Value
+		}
+		if resp.NextPage == 0 {
+			return values, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
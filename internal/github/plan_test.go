@@ -0,0 +1,80 @@
+package github
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+func ptr(value string) *string {
+	return &value
+}
+
+func TestPlanSync(t *testing.T) {
+	tests := []struct {
+		name      string
+		envVars   map[string]string
+		existing  map[string]*string
+		variables map[string]*string
+		want      []plan.Change
+	}{
+		{
+			name:    "add",
+			envVars: map[string]string{"NEW": "value"},
+			want:    []plan.Change{{Key: "NEW", Action: plan.ActionCreate}},
+		},
+		{
+			name:      "update variable",
+			envVars:   map[string]string{"KEY": "new"},
+			existing:  map[string]*string{"KEY": ptr("old")},
+			variables: map[string]*string{"KEY": ptr("old")},
+			want:      []plan.Change{{Key: "KEY", Action: plan.ActionUpdate}},
+		},
+		{
+			name:     "unknown secret value",
+			envVars:  map[string]string{"SECRET": "value"},
+			existing: map[string]*string{"SECRET": nil},
+			want:     []plan.Change{{Key: "SECRET", Action: plan.ActionUpdate, Reason: "current value cannot be read"}},
+		},
+		{
+			name:      "delete owned secret",
+			envVars:   map[string]string{internal.OPENV_KEYS: `["KEPT"]`, "KEPT": "value"},
+			existing:  map[string]*string{"KEPT": nil, "REMOVED": nil, "UNMANAGED": nil},
+			variables: map[string]*string{internal.OPENV_KEYS_REPO_SECRETS: ptr(`["KEPT","REMOVED"]`)},
+			want: []plan.Change{
+				{Key: "KEPT", Action: plan.ActionUpdate, Reason: "current value cannot be read"},
+				{Key: internal.OPENV_KEYS_REPO_SECRETS, Action: plan.ActionUpdate},
+				{Key: "REMOVED", Action: plan.ActionDelete},
+			},
+		},
+		{
+			name:      "unchanged bookkeeping variable",
+			envVars:   map[string]string{internal.OPENV_KEYS: `["KEPT"]`},
+			existing:  map[string]*string{},
+			variables: map[string]*string{internal.OPENV_KEYS_REPO_SECRETS: ptr(`["KEPT"]`)},
+			want:      []plan.Change{{Key: internal.OPENV_KEYS_REPO_SECRETS, Action: plan.ActionUnchanged}},
+		},
+		{
+			name:    "new bookkeeping variable",
+			envVars: map[string]string{internal.OPENV_KEYS: `["NEW"]`, "NEW": "value"},
+			want: []plan.Change{
+				{Key: "NEW", Action: plan.ActionCreate},
+				{Key: internal.OPENV_KEYS_REPO_SECRETS, Action: plan.ActionCreate},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := tt.existing
+			if existing == nil {
+				existing = map[string]*string{}
+			}
+			got := planSync("target", internal.OPENV_KEYS_REPO_SECRETS, tt.envVars, existing, tt.variables)
+			if !slices.Equal(got.Changes, tt.want) {
+				t.Errorf("planSync() changes = %+v, want %+v", got.Changes, tt.want)
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -331,11 +330,7 @@ func DeriveLocationFromURL(url string) (string, string, error) {
 func getRemovedEnvVars(existing *github.ActionsVariable, newVarMap map[string]string) []string {
 	existingVarNames := []string{}
 	if existing != nil {
-		// existing.Value contains a JSON array of variable names
-		err := json.Unmarshal([]byte(existing.Value), &existingVarNames)
-		if err != nil {
-			logging.Logger.Warn("failed to unmarshal existing variable names", "error", err)
-		}
+		existingVarNames = internal.ParseKeys(existing.Value)
 	}
 
 	removedVarNames := []string{}
@@ -0,0 +1,284 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// DefaultBaseURL is the URL of GitLab.com, self-hosted instances use their own URL
+const DefaultBaseURL = "https://gitlab.com"
+
+const (
+	apiPath   = "/api/v4"
+	pageSize  = 100
+	typeEnv   = "env_var"
+	allScopes = "*"
+)
+
+// maskablePattern matches values GitLab accepts for masked variables
+var maskablePattern = regexp.MustCompile(`^[a-zA-Z0-9+/=@:.~_-]{8,}$`)
+
+type variable struct {
+	Key              string  `json:"key"`
+	Value            *string `json:"value"`
+	VariableType     string  `json:"variable_type"`
+	Protected        bool    `json:"protected"`
+	Masked           bool    `json:"masked"`
+	Raw              bool    `json:"raw"`
+	EnvironmentScope string  `json:"environment_scope,omitempty"`
+}
+
+// level is a project, a group or the instance, which own CI/CD variables
+type level struct {
+	name string
+	id   string
+	path string
+	// scoped levels support environment scopes
+	scoped bool
+}
+
+// GitLabService handles syncing environment variables to GitLab CI/CD variables
+type GitLabService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewGitLabService creates a new GitLabService for a GitLab instance, an empty baseURL uses DefaultBaseURL
+func NewGitLabService(token, baseURL string) *GitLabService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client := rest.NewClient(ctx, strings.TrimSuffix(baseURL, "/")+apiPath, map[string]string{
+		"PRIVATE-TOKEN": token,
+	})
+	return &GitLabService{client: client, ctx: ctx}
+}
+
+// SyncToProjectVariable syncs environment variables to the CI/CD variables of a project with the environment scope.
+// project is the project ID or path, e.g. my-group/my-project.
+func (s *GitLabService) SyncToProjectVariable(project, environmentScope string, envVars map[string]string, plain []string, protected, withCleanup bool) error {
+	return s.syncVariables(projectLevel(project), environmentScope, envVars, plain, protected, withCleanup)
+}
+
+// SyncToGroupVariable syncs environment variables to the CI/CD variables of a group with the environment scope
+func (s *GitLabService) SyncToGroupVariable(group, environmentScope string, envVars map[string]string, plain []string, protected, withCleanup bool) error {
+	return s.syncVariables(groupLevel(group), environmentScope, envVars, plain, protected, withCleanup)
+}
+
+// SyncToInstanceVariable syncs environment variables to the CI/CD variables of the instance.
+// Instance variables have no environment scope and require an administrator token.
+func (s *GitLabService) SyncToInstanceVariable(envVars map[string]string, plain []string, protected, withCleanup bool) error {
+	return s.syncVariables(instanceLevel(), "", envVars, plain, protected, withCleanup)
+}
+
+// PlanProjectVariable computes the changes SyncToProjectVariable would apply
+func (s *GitLabService) PlanProjectVariable(project, environmentScope string, envVars map[string]string) (*plan.Plan, error) {
+	return s.planVariables(projectLevel(project), environmentScope, envVars)
+}
+
+// PlanGroupVariable computes the changes SyncToGroupVariable would apply
+func (s *GitLabService) PlanGroupVariable(group, environmentScope string, envVars map[string]string) (*plan.Plan, error) {
+	return s.planVariables(groupLevel(group), environmentScope, envVars)
+}
+
+// PlanInstanceVariable computes the changes SyncToInstanceVariable would apply
+func (s *GitLabService) PlanInstanceVariable(envVars map[string]string) (*plan.Plan, error) {
+	return s.planVariables(instanceLevel(), "", envVars)
+}
+
+func (s *GitLabService) syncVariables(l level, environmentScope string, envVars map[string]string, plain []string, protected, withCleanup bool) error {
+	if l.path == "" {
+		return fmt.Errorf("no GitLab %s configured, set the profile URL to <host>/<path>", l.name)
+	}
+	if !l.scoped {
+		environmentScope = ""
+	}
+	existing, err := s.listVariables(l, environmentScope)
+	if err != nil {
+		return err
+	}
+
+	logging.Logger.Debug("syncing to GitLab CI/CD variables",
+		"level", l.name,
+		"id", l.id,
+		"environment_scope", environmentScope)
+
+	if withCleanup {
+		s.cleanupVariables(l, environmentScope, existing, envVars)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(envVars)) {
+		value := envVars[key]
+		desired := variable{
+			Key:              key,
+			Value:            &value,
+			VariableType:     typeEnv,
+			Protected:        protected,
+			Raw:              true,
+			EnvironmentScope: environmentScope,
+		}
+		switch {
+		case key == internal.OPENV_KEYS:
+			desired.Key = internal.OPENV_KEYS_GITLAB
+			desired.Protected = false
+		case slices.Contains(plain, key):
+		case maskablePattern.MatchString(value):
+			desired.Masked = true
+		default:
+			logging.Logger.Warn("GitLab cannot mask the value, it is stored unmasked", "key", key)
+		}
+
+		current, exists := existing[desired.Key]
+		if !exists {
+			if err := s.client.Do("POST", l.path, desired, nil); err != nil {
+				return fmt.Errorf("failed to create GitLab variable %s: %w", desired.Key, err)
+			}
+			continue
+		}
+		if current.Value != nil && *current.Value == value && current.Masked == desired.Masked && current.Protected == desired.Protected && current.Raw {
+			continue
+		}
+		if err := s.client.Do("PUT", l.variablePath(desired.Key, environmentScope), desired, nil); err != nil {
+			return fmt.Errorf("failed to update GitLab variable %s: %w", desired.Key, err)
+		}
+	}
+	return nil
+}
+
+func (s *GitLabService) planVariables(l level, environmentScope string, envVars map[string]string) (*plan.Plan, error) {
+	if l.path == "" {
+		return nil, fmt.Errorf("no GitLab %s configured, set the profile URL to <host>/<path>", l.name)
+	}
+	if !l.scoped {
+		environmentScope = ""
+	}
+	existing, err := s.listVariables(l, environmentScope)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_GITLAB
+		}
+		desired[key] = value
+	}
+
+	current := map[string]*string{}
+	for key, v := range existing {
+		current[key] = v.Value
+	}
+
+	owned := []string{}
+	if bookkeeping, ok := existing[internal.OPENV_KEYS_GITLAB]; ok && bookkeeping.Value != nil {
+		owned = internal.ParseKeys(*bookkeeping.Value)
+	}
+
+	target := fmt.Sprintf("GitLab %s variables of %s", l.name, l.id)
+	if environmentScope != "" {
+		target += fmt.Sprintf(" (environment scope %s)", environmentScope)
+	}
+	return plan.Compute(target, desired, plan.State{Values: current, Owned: owned}), nil
+}
+
+// cleanupVariables deletes variables that were synced before but are no longer present in envVars
+func (s *GitLabService) cleanupVariables(l level, environmentScope string, existing map[string]variable, envVars map[string]string) {
+	bookkeeping, ok := existing[internal.OPENV_KEYS_GITLAB]
+	if !ok || bookkeeping.Value == nil {
+		return
+	}
+	for _, removed := range internal.ParseKeys(*bookkeeping.Value) {
+		if _, exists := envVars[removed]; exists {
+			continue
+		}
+		if _, exists := existing[removed]; !exists {
+			continue
+		}
+		if err := s.client.Do("DELETE", l.variablePath(removed, environmentScope), nil, nil); err != nil {
+			logging.Logger.Warn("failed to delete GitLab variable", "key", removed, "error", err)
+		}
+	}
+}
+
+// listVariables lists the variables of the level with the environment scope by key
+func (s *GitLabService) listVariables(l level, environmentScope string) (map[string]variable, error) {
+	existing := map[string]variable{}
+	for page := 1; ; page++ {
+		variables := []variable{}
+		if err := s.client.Do("GET", fmt.Sprintf("%s?per_page=%d&page=%d", l.path, pageSize, page), nil, &variables); err != nil {
+			return nil, fmt.Errorf("failed to list GitLab variables: %w", err)
+		}
+		for _, v := range variables {
+			if l.scoped && v.EnvironmentScope != environmentScope {
+				continue
+			}
+			existing[v.Key] = v
+		}
+		if len(variables) < pageSize {
+			return existing, nil
+		}
+	}
+}
+
+func projectLevel(project string) level {
+	return level{name: "project", id: project, path: idPath("/projects/", project), scoped: true}
+}
+
+func groupLevel(group string) level {
+	return level{name: "group", id: group, path: idPath("/groups/", group), scoped: true}
+}
+
+func instanceLevel() level {
+	return level{name: "instance", id: "the instance", path: "/admin/ci/variables"}
+}
+
+// idPath returns the variables path of a project or group, given by ID or URL-encoded full path
+func idPath(prefix, id string) string {
+	id = strings.Trim(id, "/")
+	if id == "" {
+		return ""
+	}
+	return prefix + url.PathEscape(id) + "/variables"
+}
+
+// variablePath returns the path of a variable, filtered by environment scope as keys are only unique per scope
+func (l level) variablePath(key, environmentScope string) string {
+	path := l.path + "/" + url.PathEscape(key)
+	if l.scoped {
+		path += "?filter[environment_scope]=" + url.QueryEscape(environmentScope)
+	}
+	return path
+}
+
+// EnvironmentScope maps an openv environment to a GitLab environment scope, all matches every environment
+func EnvironmentScope(env string) string {
+	if env == "" || env == "all" {
+		return allScopes
+	}
+	return env
+}
+
+// DeriveLocationFromURL splits a URL like gitlab.example.com/group/project into the
+// GitLab instance URL and the project or group path. URLs without a scheme use https.
+func DeriveLocationFromURL(location string) (string, string, error) {
+	if !strings.Contains(location, "://") {
+		location = "https://" + location
+	}
+	parsed, err := url.Parse(location)
+	if err != nil || parsed.Host == "" {
+		return "", "", fmt.Errorf("invalid GitLab URL: %s", location)
+	}
+	return parsed.Scheme + "://" + parsed.Host, strings.Trim(strings.TrimSuffix(parsed.Path, ".git"), "/"), nil
+}
@@ -0,0 +1,256 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// fakeGitLab serves the CI/CD variables endpoints of one project, group or the instance
+// of a self-hosted GitLab instance below /gitlab
+type fakeGitLab struct {
+	t *testing.T
+	// path is the escaped path of the variables, e.g. /gitlab/api/v4/projects/my-group%2Fmy-project/variables
+	path      string
+	mu        sync.Mutex
+	variables []variable
+	requests  []string
+}
+
+func newFakeGitLab(t *testing.T, path string, variables ...variable) (*fakeGitLab, *GitLabService) {
+	f := &fakeGitLab{t: t, path: "/gitlab" + apiPath + path, variables: variables}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, NewGitLabService("token", server.URL+"/gitlab/")
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	request := r.Method + " " + r.URL.EscapedPath()
+	scope, filtered := r.URL.Query()["filter[environment_scope]"]
+	if filtered {
+		request += " " + scope[0]
+	}
+	if r.Method != "GET" {
+		f.requests = append(f.requests, request)
+	}
+
+	if r.Header.Get("PRIVATE-TOKEN") != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	key, found := strings.CutPrefix(r.URL.EscapedPath(), f.path)
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key = strings.TrimPrefix(key, "/")
+
+	var body variable
+	if r.Method == "POST" || r.Method == "PUT" {
+		payload, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(payload, &body); err != nil {
+			f.t.Errorf("invalid request body: %v", err)
+		}
+	}
+	matches := func(v variable) bool {
+		return v.Key == key && (!filtered || v.EnvironmentScope == scope[0])
+	}
+
+	switch {
+	case r.Method == "GET" && key == "":
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		start := min((page-1)*perPage, len(f.variables))
+		writeJSON(w, f.variables[start:min(start+perPage, len(f.variables))])
+	case r.Method == "POST" && key == "":
+		f.variables = append(f.variables, body)
+		writeJSON(w, body)
+	case r.Method == "PUT":
+		i := slices.IndexFunc(f.variables, matches)
+		if i < 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f.variables[i] = body
+		writeJSON(w, body)
+	case r.Method == "DELETE":
+		if !slices.ContainsFunc(f.variables, matches) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		f.variables = slices.DeleteFunc(f.variables, matches)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// find returns the variable with the key in the environment scope
+func (f *fakeGitLab) find(key, environmentScope string) (variable, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := slices.IndexFunc(f.variables, func(v variable) bool {
+		return v.Key == key && v.EnvironmentScope == environmentScope
+	})
+	if i < 0 {
+		return variable{}, false
+	}
+	return f.variables[i], true
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestSyncToProjectVariable(t *testing.T) {
+	f, service := newFakeGitLab(t, "/projects/my-group%2Fmy-project/variables",
+		variable{Key: internal.OPENV_KEYS_GITLAB, Value: ptr(`["REMOVED","UNCHANGED","UPDATED"]`), VariableType: typeEnv, Raw: true, EnvironmentScope: "production"},
+		variable{Key: "UNCHANGED", Value: ptr("unchanged-value"), VariableType: typeEnv, Masked: true, Protected: true, Raw: true, EnvironmentScope: "production"},
+		variable{Key: "UPDATED", Value: ptr("old-value"), VariableType: typeEnv, Masked: true, Protected: true, Raw: true, EnvironmentScope: "production"},
+		variable{Key: "UPDATED", Value: ptr("other-scope"), VariableType: typeEnv, Raw: true, EnvironmentScope: allScopes},
+		variable{Key: "REMOVED", Value: ptr("gone"), VariableType: typeEnv, Raw: true, EnvironmentScope: "production"},
+		variable{Key: "REMOVED", Value: ptr("kept"), VariableType: typeEnv, Raw: true, EnvironmentScope: "staging"},
+	)
+
+	err := service.SyncToProjectVariable("my-group/my-project", "production", map[string]string{
+		"UNCHANGED":         "unchanged-value",
+		"UPDATED":           "new-value",
+		"SHORT":             "short",
+		"PUBLIC":            "public-value",
+		internal.OPENV_KEYS: `["PUBLIC","SHORT","UNCHANGED","UPDATED"]`,
+	}, []string{"PUBLIC"}, true, true)
+	if err != nil {
+		t.Fatalf("SyncToProjectVariable() error = %v", err)
+	}
+
+	path := "/gitlab/api/v4/projects/my-group%2Fmy-project/variables"
+	want := []string{
+		"DELETE " + path + "/REMOVED production",
+		"PUT " + path + "/OPENV_KEYS_GITLAB production",
+		"POST " + path,
+		"POST " + path,
+		"PUT " + path + "/UPDATED production",
+	}
+	if !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %v, want %v", f.requests, want)
+	}
+
+	if v, _ := f.find("UPDATED", "production"); *v.Value != "new-value" || !v.Masked || !v.Protected {
+		t.Errorf("UPDATED = %+v, want the new value masked and protected", v)
+	}
+	if v, _ := f.find("UPDATED", allScopes); *v.Value != "other-scope" {
+		t.Errorf("UPDATED in scope * = %s, want it unchanged", *v.Value)
+	}
+	if _, exists := f.find("REMOVED", "staging"); !exists {
+		t.Error("REMOVED in scope staging was deleted")
+	}
+	if v, _ := f.find("SHORT", "production"); v.Masked || !v.Protected {
+		t.Errorf("SHORT = %+v, want it protected and unmasked as the value cannot be masked", v)
+	}
+	if v, _ := f.find("PUBLIC", "production"); v.Masked {
+		t.Errorf("PUBLIC = %+v, want plain keys unmasked", v)
+	}
+	if v, _ := f.find(internal.OPENV_KEYS_GITLAB, "production"); v.Protected || v.Masked {
+		t.Errorf("bookkeeping variable = %+v, want it neither protected nor masked", v)
+	}
+}
+
+func TestSyncToInstanceVariable(t *testing.T) {
+	f, service := newFakeGitLab(t, "/admin/ci/variables",
+		variable{Key: "UPDATED", Value: ptr("old-value"), VariableType: typeEnv, Raw: true},
+	)
+
+	if err := service.SyncToInstanceVariable(map[string]string{"UPDATED": "new-value"}, nil, false, true); err != nil {
+		t.Fatalf("SyncToInstanceVariable() error = %v", err)
+	}
+
+	// Instance variables have no environment scope to filter by
+	if want := []string{"PUT /gitlab/api/v4/admin/ci/variables/UPDATED"}; !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %v, want %v", f.requests, want)
+	}
+	if v, _ := f.find("UPDATED", ""); *v.Value != "new-value" || v.EnvironmentScope != "" {
+		t.Errorf("UPDATED = %+v, want the new value without environment scope", v)
+	}
+}
+
+func TestPlanGroupVariable(t *testing.T) {
+	variables := []variable{
+		{Key: internal.OPENV_KEYS_GITLAB, Value: ptr(`["REMOVED","SAME"]`), EnvironmentScope: "staging"},
+		{Key: "SAME", Value: ptr("value"), EnvironmentScope: "staging"},
+		{Key: "REMOVED", Value: ptr("gone"), EnvironmentScope: "staging"},
+		{Key: "OTHER_SCOPE", Value: ptr("value"), EnvironmentScope: allScopes},
+	}
+	// Fill the first page, so the plan has to read the second one
+	for i := range pageSize {
+		variables = append(variables, variable{Key: "FILLER_" + strconv.Itoa(i), Value: ptr("value"), EnvironmentScope: "production"})
+	}
+	variables = append(variables, variable{Key: "SECOND_PAGE", Value: ptr("old"), EnvironmentScope: "staging"})
+	_, service := newFakeGitLab(t, "/groups/42/variables", variables...)
+
+	p, err := service.PlanGroupVariable("42", "staging", map[string]string{
+		"SAME":        "value",
+		"OTHER_SCOPE": "value",
+		"SECOND_PAGE": "new",
+	})
+	if err != nil {
+		t.Fatalf("PlanGroupVariable() error = %v", err)
+	}
+	want := []plan.Change{
+		{Key: "OTHER_SCOPE", Action: plan.ActionCreate},
+		{Key: "REMOVED", Action: plan.ActionDelete},
+		{Key: "SAME", Action: plan.ActionUnchanged},
+		{Key: "SECOND_PAGE", Action: plan.ActionUpdate},
+	}
+	if !slices.Equal(p.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", p.Changes, want)
+	}
+}
+
+func TestDeriveLocationFromURL(t *testing.T) {
+	tests := []struct {
+		location string
+		baseURL  string
+		path     string
+	}{
+		{"gitlab.com/my-group/my-project", "https://gitlab.com", "my-group/my-project"},
+		{"https://gitlab.example.com/group/subgroup/project.git", "https://gitlab.example.com", "group/subgroup/project"},
+		{"http://localhost:8080/my-group/", "http://localhost:8080", "my-group"},
+		{"gitlab.example.com", "https://gitlab.example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			baseURL, path, err := DeriveLocationFromURL(tt.location)
+			if err != nil {
+				t.Fatalf("DeriveLocationFromURL() error = %v", err)
+			}
+			if baseURL != tt.baseURL || path != tt.path {
+				t.Errorf("DeriveLocationFromURL() = %s, %s, want %s, %s", baseURL, path, tt.baseURL, tt.path)
+			}
+		})
+	}
+}
+
+func TestEnvironmentScope(t *testing.T) {
+	for env, want := range map[string]string{"": "*", "all": "*", "production": "production"} {
+		if got := EnvironmentScope(env); got != want {
+			t.Errorf("EnvironmentScope(%q) = %s, want %s", env, got, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package heroku
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// DefaultBaseURL is the base URL of the Heroku Platform API
+const DefaultBaseURL = "https://api.heroku.com"
+
+// HerokuService handles syncing environment variables to Heroku app config vars
+type HerokuService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewHerokuService creates a new HerokuService authenticating with an API key, an empty baseURL uses DefaultBaseURL
+func NewHerokuService(token, baseURL string) *HerokuService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client := rest.NewClient(ctx, baseURL, map[string]string{
+		"Accept":        "application/vnd.heroku+json; version=3",
+		"Authorization": "Bearer " + token,
+	})
+	return &HerokuService{client: client, ctx: ctx}
+}
+
+// SyncToApp updates the config vars of a Heroku app in one request, config vars synced before
+// and no longer in envVars are set to null, which removes them
+func (s *HerokuService) SyncToApp(app string, envVars map[string]string, withCleanup bool) error {
+	existing, err := s.GetConfigVars(app)
+	if err != nil {
+		return err
+	}
+
+	changes := map[string]*string{}
+	if bookkeeping, ok := existing[internal.OPENV_KEYS_HEROKU]; ok && withCleanup {
+		for _, removed := range internal.ParseKeys(bookkeeping) {
+			if _, desired := envVars[removed]; desired {
+				continue
+			}
+			if _, exists := existing[removed]; exists {
+				changes[removed] = nil
+			}
+		}
+	}
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_HEROKU
+		}
+		if current, exists := existing[key]; !exists || current != value {
+			changes[key] = &value
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	logging.Logger.Debug("syncing to Heroku config vars", "app", app, "changes", len(changes))
+
+	// Each config var update creates a release, so all changes are sent at once
+	if err := s.client.Do("PATCH", configVarsPath(app), changes, nil); err != nil {
+		return fmt.Errorf("failed to update config vars of Heroku app %s: %w", app, err)
+	}
+	return nil
+}
+
+// PlanApp computes the changes SyncToApp would apply
+func (s *HerokuService) PlanApp(app string, envVars map[string]string) (*plan.Plan, error) {
+	existing, err := s.GetConfigVars(app)
+	if err != nil {
+		return nil, err
+	}
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_HEROKU
+		}
+		desired[key] = value
+	}
+	current := map[string]*string{}
+	for key, value := range existing {
+		current[key] = &value
+	}
+	owned := []string{}
+	if bookkeeping, ok := existing[internal.OPENV_KEYS_HEROKU]; ok {
+		owned = internal.ParseKeys(bookkeeping)
+	}
+	return plan.Compute(fmt.Sprintf("Heroku app %s", app), desired, plan.State{Values: current, Owned: owned}), nil
+}
+
+// GetConfigVars returns the config vars of a Heroku app
+func (s *HerokuService) GetConfigVars(app string) (map[string]string, error) {
+	if app == "" {
+		return nil, fmt.Errorf("no Heroku app configured, set the profile URL to the app name")
+	}
+	configVars := map[string]string{}
+	if err := s.client.Do("GET", configVarsPath(app), nil, &configVars); err != nil {
+		return nil, fmt.Errorf("failed to get config vars of Heroku app %s: %w", app, err)
+	}
+	return configVars, nil
+}
+
+// ImportConfigVars returns the config vars of a Heroku app without the openv bookkeeping variables
+func (s *HerokuService) ImportConfigVars(app string) (map[string]string, error) {
+	configVars, err := s.GetConfigVars(app)
+	if err != nil {
+		return nil, err
+	}
+	for key := range configVars {
+		if key == internal.OPENV_KEYS || slices.Contains(internal.OPENV_KEYS_LIST, key) {
+			delete(configVars, key)
+		}
+	}
+	return configVars, nil
+}
+
+func configVarsPath(app string) string {
+	return "/apps/" + url.PathEscape(app) + "/config-vars"
+}
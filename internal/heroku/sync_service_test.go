@@ -0,0 +1,172 @@
+package heroku
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+const appPath = "/apps/my-app/config-vars"
+
+// fakeHeroku serves the config vars of the Heroku app my-app
+type fakeHeroku struct {
+	t          *testing.T
+	mu         sync.Mutex
+	configVars map[string]string
+	// patches holds the bodies of the PATCH requests, null values remove config vars
+	patches []map[string]*string
+}
+
+func newFakeHeroku(t *testing.T, configVars map[string]string) (*fakeHeroku, *HerokuService) {
+	f := &fakeHeroku{t: t, configVars: configVars}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, NewHerokuService("token", server.URL)
+}
+
+func (f *fakeHeroku) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Accept") != "application/vnd.heroku+json; version=3" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path != appPath {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeJSON(w, f.configVars)
+	case "PATCH":
+		patch := map[string]*string{}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			f.t.Errorf("invalid config vars patch: %v", err)
+		}
+		f.patches = append(f.patches, patch)
+		for key, value := range patch {
+			if value == nil {
+				delete(f.configVars, key)
+			} else {
+				f.configVars[key] = *value
+			}
+		}
+		writeJSON(w, f.configVars)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestSyncToApp(t *testing.T) {
+	f, service := newFakeHeroku(t, map[string]string{
+		internal.OPENV_KEYS_HEROKU: `["GONE","REMOVED","UNCHANGED","UPDATED"]`,
+		"REMOVED":                  "gone",
+		"UNCHANGED":                "same",
+		"UPDATED":                  "old",
+		"DATABASE_URL":             "postgres://",
+	})
+
+	err := service.SyncToApp("my-app", map[string]string{
+		"ADDED":             "value",
+		"UNCHANGED":         "same",
+		"UPDATED":           "new",
+		internal.OPENV_KEYS: `["ADDED","UNCHANGED","UPDATED"]`,
+	}, true)
+	if err != nil {
+		t.Fatalf("SyncToApp() error = %v", err)
+	}
+
+	// GONE is owned but no longer exists, so it is not removed again
+	want := []map[string]*string{{
+		"ADDED":                    ptr("value"),
+		"UPDATED":                  ptr("new"),
+		"REMOVED":                  nil,
+		internal.OPENV_KEYS_HEROKU: ptr(`["ADDED","UNCHANGED","UPDATED"]`),
+	}}
+	equal := func(a, b map[string]*string) bool {
+		return maps.EqualFunc(a, b, func(x, y *string) bool { return (x == nil && y == nil) || (x != nil && y != nil && *x == *y) })
+	}
+	if !slices.EqualFunc(f.patches, want, equal) {
+		t.Errorf("patches = %v, want one patch with the changes and a null for REMOVED", f.patches)
+	}
+	if _, exists := f.configVars["DATABASE_URL"]; !exists {
+		t.Error("DATABASE_URL was removed")
+	}
+}
+
+func TestSyncToAppWithoutChanges(t *testing.T) {
+	f, service := newFakeHeroku(t, map[string]string{
+		internal.OPENV_KEYS_HEROKU: `["KEY","REMOVED"]`,
+		"KEY":                      "value",
+		"REMOVED":                  "kept",
+	})
+
+	if err := service.SyncToApp("my-app", map[string]string{"KEY": "value", internal.OPENV_KEYS: `["KEY","REMOVED"]`}, false); err != nil {
+		t.Fatalf("SyncToApp() error = %v", err)
+	}
+	// Every patch creates a release, so none is sent without changes
+	if len(f.patches) != 0 {
+		t.Errorf("patches = %v, want none", f.patches)
+	}
+}
+
+func TestPlanApp(t *testing.T) {
+	_, service := newFakeHeroku(t, map[string]string{
+		internal.OPENV_KEYS_HEROKU: `["REMOVED","UNCHANGED"]`,
+		"REMOVED":                  "gone",
+		"UNCHANGED":                "same",
+		"DATABASE_URL":             "postgres://",
+	})
+
+	p, err := service.PlanApp("my-app", map[string]string{"UNCHANGED": "same", "ADDED": "value"})
+	if err != nil {
+		t.Fatalf("PlanApp() error = %v", err)
+	}
+	want := []plan.Change{
+		{Key: "ADDED", Action: plan.ActionCreate},
+		{Key: "REMOVED", Action: plan.ActionDelete},
+		{Key: "UNCHANGED", Action: plan.ActionUnchanged},
+	}
+	if !slices.Equal(p.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", p.Changes, want)
+	}
+}
+
+func TestImportConfigVars(t *testing.T) {
+	_, service := newFakeHeroku(t, map[string]string{
+		internal.OPENV_KEYS:        `["KEY"]`,
+		internal.OPENV_KEYS_HEROKU: `["KEY"]`,
+		internal.OPENV_KEYS_SSM:    `["KEY"]`,
+		"KEY":                      "value",
+		"OPENV_KEYSTORE":           "kept",
+	})
+
+	got, err := service.ImportConfigVars("my-app")
+	if err != nil {
+		t.Fatalf("ImportConfigVars() error = %v", err)
+	}
+	if want := map[string]string{"KEY": "value", "OPENV_KEYSTORE": "kept"}; !maps.Equal(got, want) {
+		t.Errorf("ImportConfigVars() = %v, want %v", got, want)
+	}
+	if _, err := service.ImportConfigVars(""); err == nil {
+		t.Error("ImportConfigVars() error = nil, want missing app")
+	}
+}
@@ -0,0 +1,17 @@
+package internal
+
+import (
+	"encoding/json"
+
+	"github.com/hinterland-software/openv/internal/logging"
+)
+
+// ParseKeys parses the JSON array of variable names stored in an OPENV_KEYS_* bookkeeping variable.
+// An invalid value is logged and treated as an empty list, so no variable is removed because of it.
+func ParseKeys(value string) []string {
+	keys := []string{}
+	if err := json.Unmarshal([]byte(value), &keys); err != nil {
+		logging.Logger.Warn("failed to unmarshal existing variable names", "error", err)
+	}
+	return keys
+}
@@ -0,0 +1,21 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := map[string][]string{
+		`["A","B"]`: {"A", "B"},
+		`[]`:        {},
+		``:          {},
+		`not json`:  {},
+		`{"A":1}`:   {},
+	}
+	for value, want := range tests {
+		if got := ParseKeys(value); !slices.Equal(got, want) || got == nil {
+			t.Errorf("ParseKeys(%q) = %#v, want %#v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package kubernetes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubeconfig is the subset of a kubeconfig file needed to connect to a cluster
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Contexts       []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Clusters []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+			Exec                  any    `yaml:"exec"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// Cluster holds the connection settings of a kubeconfig context
+type Cluster struct {
+	Server    string
+	Token     string
+	Namespace string
+	TLS       *tls.Config
+}
+
+// KubeconfigPath returns the first file of KUBECONFIG, defaulting to ~/.kube/config
+func KubeconfigPath() (string, error) {
+	if paths := filepath.SplitList(os.Getenv("KUBECONFIG")); len(paths) > 0 && paths[0] != "" {
+		return paths[0], nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".kube", "config"), nil
+}
+
+// LoadCluster reads the connection settings of a context from a kubeconfig file, an empty context uses the current context.
+// Token and client certificate authentication are supported, exec credential plugins are not.
+func LoadCluster(path, contextName string) (*Cluster, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+	config := kubeconfig{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig %s: %w", path, err)
+	}
+	// Relative file references are resolved against the kubeconfig directory
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(filepath.Dir(path), file)
+	}
+
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+	cluster := &Cluster{TLS: &tls.Config{MinVersion: tls.VersionTLS12}}
+	clusterName, userName := "", ""
+	for _, c := range config.Contexts {
+		if c.Name == contextName {
+			clusterName, userName, cluster.Namespace = c.Context.Cluster, c.Context.User, c.Context.Namespace
+		}
+	}
+	if clusterName == "" {
+		return nil, fmt.Errorf("kubeconfig context %q not found", contextName)
+	}
+
+	for _, c := range config.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		cluster.Server = c.Cluster.Server
+		cluster.TLS.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+		ca, err := readData(c.Cluster.CertificateAuthorityData, resolve(c.Cluster.CertificateAuthority))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate authority of cluster %s: %w", clusterName, err)
+		}
+		if len(ca) > 0 {
+			cluster.TLS.RootCAs = x509.NewCertPool()
+			if !cluster.TLS.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("invalid certificate authority of cluster %s", clusterName)
+			}
+		}
+	}
+	if cluster.Server == "" {
+		return nil, fmt.Errorf("kubeconfig cluster %q not found", clusterName)
+	}
+
+	for _, u := range config.Users {
+		if u.Name != userName {
+			continue
+		}
+		if u.User.Exec != nil {
+			return nil, fmt.Errorf("exec credential plugins of kubeconfig user %s are not supported, use a token or client certificate", userName)
+		}
+		cluster.Token = u.User.Token
+		if cluster.Token == "" && u.User.TokenFile != "" {
+			token, err := os.ReadFile(resolve(u.User.TokenFile))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read token of kubeconfig user %s: %w", userName, err)
+			}
+			cluster.Token = strings.TrimSpace(string(token))
+		}
+
+		cert, err := readData(u.User.ClientCertificateData, resolve(u.User.ClientCertificate))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client certificate of kubeconfig user %s: %w", userName, err)
+		}
+		key, err := readData(u.User.ClientKeyData, resolve(u.User.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client key of kubeconfig user %s: %w", userName, err)
+		}
+		if len(cert) > 0 {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid client certificate of kubeconfig user %s: %w", userName, err)
+			}
+			cluster.TLS.Certificates = []tls.Certificate{pair}
+		}
+	}
+	return cluster, nil
+}
+
+// Transport returns an HTTP transport using the TLS settings of the cluster
+func (c *Cluster) Transport() http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = c.TLS
+	return transport
+}
+
+// readData returns base64 encoded inline data, or the content of file
+func readData(data, file string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	return nil, nil
+}
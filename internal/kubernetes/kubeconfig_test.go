@@ -0,0 +1,218 @@
+package kubernetes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// generateCertificate returns a PEM encoded self-signed certificate and its key
+func generateCertificate(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "openv"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: dev
+contexts:
+- name: dev
+  context:
+    cluster: dev-cluster
+    user: dev-user
+    namespace: dev-apps
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: prod-user
+clusters:
+- name: dev-cluster
+  cluster:
+    server: https://dev.example.com
+    insecure-skip-tls-verify: true
+- name: prod-cluster
+  cluster:
+    server: https://prod.example.com
+    certificate-authority: certs/ca.crt
+users:
+- name: dev-user
+  user:
+    token: dev-token
+- name: prod-user
+  user:
+    tokenFile: tokens/prod
+    client-certificate: certs/client.crt
+    client-key-data: %s
+- name: exec-user
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+`
+
+func TestLoadCluster(t *testing.T) {
+	dir := t.TempDir()
+	ca, _ := generateCertificate(t)
+	clientCert, clientKey := generateCertificate(t)
+	path := filepath.Join(dir, "config")
+	writeFile(t, path, strings.Replace(testKubeconfig, "%s", base64.StdEncoding.EncodeToString(clientKey), 1))
+	writeFile(t, filepath.Join(dir, "certs", "ca.crt"), string(ca))
+	writeFile(t, filepath.Join(dir, "certs", "client.crt"), string(clientCert))
+	writeFile(t, filepath.Join(dir, "tokens", "prod"), "prod-token\n")
+
+	current, err := LoadCluster(path, "")
+	if err != nil {
+		t.Fatalf("LoadCluster() error = %v", err)
+	}
+	if current.Server != "https://dev.example.com" || current.Token != "dev-token" || current.Namespace != "dev-apps" || !current.TLS.InsecureSkipVerify {
+		t.Errorf("current context = %+v, want the dev cluster, user and namespace", current)
+	}
+
+	prod, err := LoadCluster(path, "prod")
+	if err != nil {
+		t.Fatalf("LoadCluster() error = %v", err)
+	}
+	if prod.Server != "https://prod.example.com" || prod.Token != "prod-token" || prod.Namespace != "" {
+		t.Errorf("prod context = %+v, want the prod cluster with the token read relative to the kubeconfig", prod)
+	}
+	if prod.TLS.RootCAs == nil || prod.TLS.InsecureSkipVerify {
+		t.Error("prod context does not verify with the certificate authority file")
+	}
+	if len(prod.TLS.Certificates) != 1 {
+		t.Errorf("prod context has %d client certificates, want 1", len(prod.TLS.Certificates))
+	}
+}
+
+func TestLoadClusterErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	// The certificate authority and token files of the prod context do not exist
+	content := strings.Replace(testKubeconfig, "contexts:\n", `contexts:
+- name: exec
+  context:
+    cluster: dev-cluster
+    user: exec-user
+- name: missing-cluster
+  context:
+    cluster: unknown
+    user: dev-user
+`, 1)
+	writeFile(t, path, strings.Replace(content, "%s", "", 1))
+
+	tests := []struct {
+		context string
+		want    string
+	}{
+		{context: "exec", want: "exec credential plugins"},
+		{context: "missing-cluster", want: `cluster "unknown" not found`},
+		{context: "unknown", want: `context "unknown" not found`},
+		{context: "prod", want: "certificate authority"},
+	}
+	for _, tt := range tests {
+		_, err := LoadCluster(path, tt.context)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadCluster(%s) error = %v, want %q", tt.context, err, tt.want)
+		}
+	}
+}
+
+func TestLoadClusterConnects(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"apiVersion":"v1","kind":"Secret","data":{"KEY":"dmFsdWU="}}`))
+	}))
+	defer server.Close()
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	path := filepath.Join(t.TempDir(), "config")
+	writeFile(t, path, `current-context: test
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+clusters:
+- name: test
+  cluster:
+    server: `+server.URL+`
+    certificate-authority-data: `+base64.StdEncoding.EncodeToString(ca)+`
+users:
+- name: test
+  user:
+    token: token
+`)
+
+	cluster, err := LoadCluster(path, "")
+	if err != nil {
+		t.Fatalf("LoadCluster() error = %v", err)
+	}
+	p, err := NewKubernetesService(cluster).PlanSecret("apps", "web", map[string]string{"KEY": "value"})
+	if err != nil {
+		t.Fatalf("PlanSecret() error = %v", err)
+	}
+	if len(p.Changes) != 1 || p.Changes[0].Action != plan.ActionUnchanged {
+		t.Errorf("changes = %v, want KEY unchanged", p.Changes)
+	}
+}
+
+func TestKubeconfigPath(t *testing.T) {
+	t.Setenv("KUBECONFIG", strings.Join([]string{"/first/config", "/second/config"}, string(filepath.ListSeparator)))
+	if path, err := KubeconfigPath(); err != nil || path != "/first/config" {
+		t.Errorf("KubeconfigPath() = %s, %v, want the first KUBECONFIG entry", path, err)
+	}
+
+	home := t.TempDir()
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("HOME", home)
+	if path, err := KubeconfigPath(); err != nil || path != filepath.Join(home, ".kube", "config") {
+		t.Errorf("KubeconfigPath() = %s, %v, want the default in the home directory", path, err)
+	}
+}
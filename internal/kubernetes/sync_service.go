@@ -0,0 +1,176 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// FieldManager is the server-side apply field manager owning the keys openv syncs
+const FieldManager = "openv"
+
+const applyContentType = "application/apply-patch+yaml"
+
+// keyPattern matches valid Secret data keys
+var keyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+type secret struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   metadata          `json:"metadata"`
+	Type       string            `json:"type,omitempty"`
+	Data       map[string]string `json:"data"`
+}
+
+type metadata struct {
+	Name          string            `json:"name"`
+	Namespace     string            `json:"namespace"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	ManagedFields []managedField    `json:"managedFields,omitempty"`
+}
+
+type managedField struct {
+	Manager   string                     `json:"manager"`
+	Operation string                     `json:"operation"`
+	FieldsV1  map[string]json.RawMessage `json:"fieldsV1"`
+}
+
+// KubernetesService handles syncing environment variables to Secrets in a Kubernetes cluster
+type KubernetesService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewKubernetesService creates a new KubernetesService connecting to the cluster
+func NewKubernetesService(cluster *Cluster) *KubernetesService {
+	ctx := context.Background()
+	headers := map[string]string{}
+	if cluster.Token != "" {
+		headers["Authorization"] = "Bearer " + cluster.Token
+	}
+	client := rest.NewClient(ctx, cluster.Server, headers).WithTransport(cluster.Transport())
+	return &KubernetesService{client: client, ctx: ctx}
+}
+
+// SyncToSecret applies the environment variables to the Secret with server-side apply.
+// Keys applied before but no longer present are pruned by the API server,
+// keys managed by others are kept.
+func (s *KubernetesService) SyncToSecret(namespace, name string, envVars map[string]string) error {
+	if namespace == "" || name == "" {
+		return fmt.Errorf("Kubernetes namespace and Secret name are required")
+	}
+	data := map[string]string{}
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			continue
+		}
+		if !keyPattern.MatchString(key) {
+			return fmt.Errorf("invalid Kubernetes Secret key %q", key)
+		}
+		data[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+
+	logging.Logger.Debug("applying Kubernetes Secret", "namespace", namespace, "name", name)
+
+	payload, err := json.Marshal(secret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata: metadata{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": FieldManager},
+		},
+		Type: "Opaque",
+		Data: data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal Secret: %w", err)
+	}
+
+	// force takes over keys last applied by other managers, openv is the source of truth for the keys it syncs
+	path := secretPath(namespace, name) + "?fieldManager=" + FieldManager + "&force=true"
+	if err := s.client.DoRaw("PATCH", path, applyContentType, bytes.NewReader(payload), nil); err != nil {
+		return fmt.Errorf("failed to apply Kubernetes Secret %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// PlanSecret computes the changes SyncToSecret would apply.
+// The keys openv owns are read from the managed fields of the Secret.
+func (s *KubernetesService) PlanSecret(namespace, name string, envVars map[string]string) (*plan.Plan, error) {
+	if namespace == "" || name == "" {
+		return nil, fmt.Errorf("Kubernetes namespace and Secret name are required")
+	}
+	current := secret{}
+	err := s.client.Do("GET", secretPath(namespace, name), nil, &current)
+	if err != nil && !rest.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get Kubernetes Secret %s/%s: %w", namespace, name, err)
+	}
+
+	desired := map[string]string{}
+	for key, value := range envVars {
+		if key != internal.OPENV_KEYS {
+			desired[key] = value
+		}
+	}
+
+	values := map[string]*string{}
+	for key, encoded := range current.Data {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			values[key] = nil
+			continue
+		}
+		value := string(decoded)
+		values[key] = &value
+	}
+
+	return plan.Compute(fmt.Sprintf("Kubernetes Secret %s/%s", namespace, name), desired, plan.State{
+		Values: values,
+		Owned:  ownedKeys(current.Metadata.ManagedFields),
+	}), nil
+}
+
+// ownedKeys returns the data keys applied by the openv field manager
+func ownedKeys(managedFields []managedField) []string {
+	owned := []string{}
+	for _, field := range managedFields {
+		if field.Manager != FieldManager || field.Operation != "Apply" {
+			continue
+		}
+		data := map[string]json.RawMessage{}
+		if err := json.Unmarshal(field.FieldsV1["f:data"], &data); err != nil {
+			continue
+		}
+		for key := range data {
+			if strings.HasPrefix(key, "f:") {
+				owned = append(owned, strings.TrimPrefix(key, "f:"))
+			}
+		}
+	}
+	return owned
+}
+
+func secretPath(namespace, name string) string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/secrets/%s", url.PathEscape(namespace), url.PathEscape(name))
+}
+
+// ParseLocation splits a profile URL of the form [<context>/]<namespace> into kubeconfig context and namespace.
+// Context names may contain slashes, so the namespace is the last segment.
+func ParseLocation(location string) (string, string) {
+	index := strings.LastIndex(location, "/")
+	if index < 0 {
+		return "", location
+	}
+	return location[:index], location[index+1:]
+}
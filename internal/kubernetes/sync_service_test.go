@@ -0,0 +1,274 @@
+package kubernetes
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// fakeAPIServer serves a single Secret and emulates server-side apply:
+// keys applied by a manager before but missing from its next apply are pruned,
+// keys of other managers are kept
+type fakeAPIServer struct {
+	t      *testing.T
+	mu     sync.Mutex
+	secret *secret
+	// owners maps each data key to its managers and their operation
+	owners map[string]map[string]string
+}
+
+func newFakeAPIServer(t *testing.T) (*fakeAPIServer, *httptest.Server) {
+	f := &fakeAPIServer{t: t, owners: map[string]map[string]string{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path != "/api/v1/namespaces/apps/secrets/web" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		if f.secret == nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"kind":"Status","reason":"NotFound"}`)
+			return
+		}
+		writeJSON(w, f.secretWithManagedFields())
+	case "PATCH":
+		if r.Header.Get("Content-Type") != applyContentType {
+			f.t.Errorf("Content-Type = %s, want %s", r.Header.Get("Content-Type"), applyContentType)
+		}
+		query := r.URL.Query()
+		if query.Get("fieldManager") != FieldManager || query.Get("force") != "true" {
+			f.t.Errorf("query = %s, want fieldManager=%s and force=true", r.URL.RawQuery, FieldManager)
+		}
+		applied := secret{}
+		if err := json.NewDecoder(r.Body).Decode(&applied); err != nil {
+			f.t.Errorf("invalid apply body: %v", err)
+		}
+		f.apply(FieldManager, "Apply", applied.Data)
+		f.secret.Metadata.Labels = applied.Metadata.Labels
+		writeJSON(w, f.secretWithManagedFields())
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// apply sets data as the keys of manager, pruning its keys missing from data
+func (f *fakeAPIServer) apply(manager, operation string, data map[string]string) {
+	if f.secret == nil {
+		f.secret = &secret{APIVersion: "v1", Kind: "Secret", Metadata: metadata{Name: "web", Namespace: "apps"}, Data: map[string]string{}}
+	}
+	for key, owners := range f.owners {
+		if _, ok := owners[manager]; !ok {
+			continue
+		}
+		if _, applied := data[key]; applied {
+			continue
+		}
+		delete(owners, manager)
+		if len(owners) == 0 {
+			delete(f.owners, key)
+			delete(f.secret.Data, key)
+		}
+	}
+	for key, value := range data {
+		f.secret.Data[key] = value
+		// force takes over the key from other appliers
+		f.owners[key] = map[string]string{manager: operation}
+	}
+}
+
+func (f *fakeAPIServer) secretWithManagedFields() secret {
+	response := *f.secret
+	fields := map[string]map[string]json.RawMessage{}
+	operations := map[string]string{}
+	for key, owners := range f.owners {
+		for manager, operation := range owners {
+			if fields[manager] == nil {
+				fields[manager] = map[string]json.RawMessage{}
+			}
+			fields[manager]["f:"+key] = json.RawMessage("{}")
+			operations[manager] = operation
+		}
+	}
+	for _, manager := range slices.Sorted(maps.Keys(fields)) {
+		data, _ := json.Marshal(fields[manager])
+		response.Metadata.ManagedFields = append(response.Metadata.ManagedFields, managedField{
+			Manager:   manager,
+			Operation: operations[manager],
+			FieldsV1:  map[string]json.RawMessage{"f:data": data, "f:type": json.RawMessage("{}")},
+		})
+	}
+	return response
+}
+
+func (f *fakeAPIServer) value(key string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.secret == nil {
+		return "", false
+	}
+	encoded, ok := f.secret.Data[key]
+	if !ok {
+		return "", false
+	}
+	decoded, _ := base64.StdEncoding.DecodeString(encoded)
+	return string(decoded), true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newTestService(server *httptest.Server) *KubernetesService {
+	return NewKubernetesService(&Cluster{Server: server.URL, Token: "token", TLS: &tls.Config{}})
+}
+
+func TestSyncToSecretAppliesAndPrunes(t *testing.T) {
+	f, server := newFakeAPIServer(t)
+	service := newTestService(server)
+	f.apply("kubectl", "Update", map[string]string{"FOREIGN": base64.StdEncoding.EncodeToString([]byte("keep"))})
+
+	if err := service.SyncToSecret("apps", "web", map[string]string{"KEPT": "1", "REMOVED": "2", internal.OPENV_KEYS: `["KEPT","REMOVED"]`}); err != nil {
+		t.Fatalf("SyncToSecret() error = %v", err)
+	}
+	if value, ok := f.value("REMOVED"); !ok || value != "2" {
+		t.Fatalf("REMOVED = %q, %v, want it applied", value, ok)
+	}
+	if _, ok := f.value(internal.OPENV_KEYS); ok {
+		t.Errorf("%s was applied as Secret key", internal.OPENV_KEYS)
+	}
+	if f.secret.Metadata.Labels["app.kubernetes.io/managed-by"] != FieldManager {
+		t.Errorf("labels = %v, want managed-by %s", f.secret.Metadata.Labels, FieldManager)
+	}
+
+	if err := service.SyncToSecret("apps", "web", map[string]string{"KEPT": "updated"}); err != nil {
+		t.Fatalf("SyncToSecret() error = %v", err)
+	}
+	if value, _ := f.value("KEPT"); value != "updated" {
+		t.Errorf("KEPT = %q, want updated", value)
+	}
+	if _, ok := f.value("REMOVED"); ok {
+		t.Error("REMOVED was not pruned")
+	}
+	if value, _ := f.value("FOREIGN"); value != "keep" {
+		t.Errorf("FOREIGN = %q, want the key of another manager kept", value)
+	}
+}
+
+func TestSyncToSecretRejectsInvalidKeys(t *testing.T) {
+	_, server := newFakeAPIServer(t)
+
+	err := newTestService(server).SyncToSecret("apps", "web", map[string]string{"INVALID KEY": "value"})
+	if err == nil {
+		t.Fatal("SyncToSecret() error = nil, want invalid key error")
+	}
+}
+
+func TestPlanSecret(t *testing.T) {
+	f, server := newFakeAPIServer(t)
+	service := newTestService(server)
+	f.apply(FieldManager, "Apply", map[string]string{
+		"SAME":    base64.StdEncoding.EncodeToString([]byte("value")),
+		"UPDATED": base64.StdEncoding.EncodeToString([]byte("old")),
+		"REMOVED": base64.StdEncoding.EncodeToString([]byte("gone")),
+	})
+	f.apply("kubectl", "Update", map[string]string{"FOREIGN": base64.StdEncoding.EncodeToString([]byte("keep"))})
+
+	p, err := service.PlanSecret("apps", "web", map[string]string{
+		"SAME":              "value",
+		"UPDATED":           "new",
+		"ADDED":             "added",
+		internal.OPENV_KEYS: `["ADDED","SAME","UPDATED"]`,
+	})
+	if err != nil {
+		t.Fatalf("PlanSecret() error = %v", err)
+	}
+
+	actions := map[string]plan.Action{}
+	for _, change := range p.Changes {
+		actions[change.Key] = change.Action
+	}
+	want := map[string]plan.Action{
+		"SAME":    plan.ActionUnchanged,
+		"UPDATED": plan.ActionUpdate,
+		"ADDED":   plan.ActionCreate,
+		"REMOVED": plan.ActionDelete,
+	}
+	if !maps.Equal(actions, want) {
+		t.Errorf("actions = %v, want %v", actions, want)
+	}
+}
+
+func TestPlanSecretMissing(t *testing.T) {
+	_, server := newFakeAPIServer(t)
+
+	p, err := newTestService(server).PlanSecret("apps", "web", map[string]string{"KEY": "value"})
+	if err != nil {
+		t.Fatalf("PlanSecret() error = %v", err)
+	}
+	if len(p.Changes) != 1 || p.Changes[0].Action != plan.ActionCreate {
+		t.Errorf("changes = %v, want KEY created", p.Changes)
+	}
+}
+
+func TestOwnedKeys(t *testing.T) {
+	// managedFields as returned by the API server after an apply by openv and an edit with kubectl
+	var fields []managedField
+	err := json.Unmarshal([]byte(`[
+		{"manager":"openv","operation":"Apply","apiVersion":"v1","fieldsType":"FieldsV1",
+		 "fieldsV1":{"f:data":{"f:API_KEY":{},"f:DATABASE_URL":{}},"f:metadata":{"f:labels":{"f:app.kubernetes.io/managed-by":{}}},"f:type":{}}},
+		{"manager":"kubectl-edit","operation":"Update","apiVersion":"v1","fieldsType":"FieldsV1",
+		 "fieldsV1":{"f:data":{"f:EDITED":{}}}},
+		{"manager":"openv","operation":"Update","apiVersion":"v1","fieldsType":"FieldsV1",
+		 "fieldsV1":{"f:data":{"f:UPDATED_BY_OPENV":{}}}},
+		{"manager":"openv","operation":"Apply","subresource":"status","fieldsType":"FieldsV1",
+		 "fieldsV1":{"f:metadata":{}}}
+	]`), &fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owned := ownedKeys(fields)
+	slices.Sort(owned)
+	if want := []string{"API_KEY", "DATABASE_URL"}; !slices.Equal(owned, want) {
+		t.Errorf("ownedKeys() = %v, want %v", owned, want)
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct{ location, context, namespace string }{
+		{"apps", "", "apps"},
+		{"prod/apps", "prod", "apps"},
+		{"arn:aws:eks:eu-west-1:123:cluster/prod/apps", "arn:aws:eks:eu-west-1:123:cluster/prod", "apps"},
+	}
+	for _, tt := range tests {
+		context, namespace := ParseLocation(tt.location)
+		if context != tt.context || namespace != tt.namespace {
+			t.Errorf("ParseLocation(%q) = %q, %q, want %q, %q", tt.location, context, namespace, tt.context, tt.namespace)
+		}
+	}
+}
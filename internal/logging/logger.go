@@ -9,8 +9,8 @@ import (
 )
 
 var (
-	// Logger is the global logger instance
-	Logger *slog.Logger
+	// Logger is the global logger instance, the default logger until InitLogger is called
+	Logger = slog.Default()
 )
 
 type Options struct {
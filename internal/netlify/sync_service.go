@@ -0,0 +1,336 @@
+package netlify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// DefaultBaseURL is the base URL of the Netlify API
+const DefaultBaseURL = "https://api.netlify.com/api/v1"
+
+// Scope limits where an environment variable is available
+type Scope string
+
+const (
+	ScopeBuilds         Scope = "builds"
+	ScopeFunctions      Scope = "functions"
+	ScopeRuntime        Scope = "runtime"
+	ScopePostProcessing Scope = "post_processing"
+)
+
+// AllScopes lists all scopes, variables without explicit scopes are available in all of them
+var AllScopes = []Scope{
+	ScopeBuilds,
+	ScopeFunctions,
+	ScopeRuntime,
+	ScopePostProcessing,
+}
+
+const (
+	contextAll    = "all"
+	contextBranch = "branch"
+)
+
+// standardContexts are the deploy contexts a value for all contexts is split into
+var standardContexts = []string{"production", "deploy-preview", "branch-deploy", "dev"}
+
+type envVar struct {
+	Key    string     `json:"key"`
+	Scopes []Scope    `json:"scopes,omitempty"`
+	Values []envValue `json:"values"`
+}
+
+type envValue struct {
+	ID               string `json:"id,omitempty"`
+	Value            string `json:"value"`
+	Context          string `json:"context"`
+	ContextParameter string `json:"context_parameter,omitempty"`
+}
+
+type site struct {
+	ID          string `json:"id"`
+	AccountID   string `json:"account_id"`
+	AccountSlug string `json:"account_slug"`
+}
+
+// NetlifyService handles syncing environment variables to Netlify sites
+type NetlifyService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewNetlifyService creates a new NetlifyService, an empty baseURL uses DefaultBaseURL
+func NewNetlifyService(token, baseURL string) *NetlifyService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client := rest.NewClient(ctx, baseURL, map[string]string{
+		"Authorization": "Bearer " + token,
+	})
+	return &NetlifyService{client: client, ctx: ctx}
+}
+
+// SyncToDeployContext syncs environment variables to the deploy context of a site matching env.
+// siteID is the site ID or its domain, e.g. my-site.netlify.app.
+// Values for other deploy contexts are kept, new variables are created with the given scopes.
+func (s *NetlifyService) SyncToDeployContext(siteID, env string, scopes []Scope, envVars map[string]string, withCleanup bool) error {
+	target, err := s.resolveSite(siteID)
+	if err != nil {
+		return err
+	}
+	existing, err := s.listEnv(target)
+	if err != nil {
+		return err
+	}
+
+	deployContext := deployContextForEnv(env)
+	logging.Logger.Debug("syncing to Netlify deploy context",
+		"site", siteID,
+		"context", deployContext.Context,
+		"context_parameter", deployContext.ContextParameter)
+
+	if withCleanup {
+		s.cleanupDeployContext(target, deployContext, existing, envVars)
+	}
+
+	created := []envVar{}
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_NETLIFY
+		}
+		newValue := envValue{Value: value, Context: deployContext.Context, ContextParameter: deployContext.ContextParameter}
+
+		current, exists := existing[key]
+		if !exists {
+			created = append(created, envVar{Key: key, Scopes: scopes, Values: []envValue{newValue}})
+			continue
+		}
+
+		currentValue := findValue(current, deployContext)
+		if currentValue != nil && currentValue.Value == value && len(missingScopes(current, scopes)) == 0 {
+			continue
+		}
+
+		updated := envVar{
+			Key:    key,
+			Scopes: mergeScopes(current.Scopes, scopes),
+			Values: append(withoutContext(current.Values, deployContext), newValue),
+		}
+		if err := s.client.Do("PUT", s.envPath(target, key), updated, nil); err != nil {
+			return fmt.Errorf("failed to update Netlify environment variable %s: %w", key, err)
+		}
+	}
+
+	if len(created) > 0 {
+		if err := s.client.Do("POST", s.envPath(target, ""), created, nil); err != nil {
+			return fmt.Errorf("failed to create Netlify environment variables: %w", err)
+		}
+	}
+	return nil
+}
+
+// PlanDeployContext computes the changes SyncToDeployContext would apply
+func (s *NetlifyService) PlanDeployContext(siteID, env string, scopes []Scope, envVars map[string]string) (*plan.Plan, error) {
+	target, err := s.resolveSite(siteID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.listEnv(target)
+	if err != nil {
+		return nil, err
+	}
+
+	deployContext := deployContextForEnv(env)
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_NETLIFY
+		}
+		desired[key] = value
+	}
+
+	// Only values of the synced deploy context count as existing
+	current := map[string]*string{}
+	for key, variable := range existing {
+		if value := findValue(variable, deployContext); value != nil {
+			current[key] = &value.Value
+		}
+	}
+
+	owned := []string{}
+	if value, ok := current[internal.OPENV_KEYS_NETLIFY]; ok {
+		owned = internal.ParseKeys(*value)
+	}
+
+	name := deployContext.Context
+	if deployContext.ContextParameter != "" {
+		name += ":" + deployContext.ContextParameter
+	}
+	p := plan.Compute(fmt.Sprintf("Netlify deploy context %s of %s", name, siteID), desired, plan.State{Values: current, Owned: owned})
+	// An unchanged value is still updated if the variable lacks one of the scopes
+	for i, change := range p.Changes {
+		if change.Action != plan.ActionUnchanged {
+			continue
+		}
+		if missing := missingScopes(existing[change.Key], scopes); len(missing) > 0 {
+			p.Changes[i].Action = plan.ActionUpdate
+			p.Changes[i].Reason = fmt.Sprintf("missing scopes %v", missing)
+		}
+	}
+	return p, nil
+}
+
+// cleanupDeployContext removes the values of the deploy context for variables
+// that were synced before but are no longer present in envVars
+func (s *NetlifyService) cleanupDeployContext(target *site, deployContext envValue, existing map[string]envVar, envVars map[string]string) {
+	bookkeeping, ok := existing[internal.OPENV_KEYS_NETLIFY]
+	if !ok {
+		return
+	}
+	value := findValue(bookkeeping, deployContext)
+	if value == nil {
+		return
+	}
+
+	for _, removed := range internal.ParseKeys(value.Value) {
+		if _, exists := envVars[removed]; exists {
+			continue
+		}
+		variable, ok := existing[removed]
+		if !ok || findValue(variable, deployContext) == nil {
+			continue
+		}
+
+		var err error
+		if remaining := withoutContext(variable.Values, deployContext); len(remaining) == 0 {
+			err = s.client.Do("DELETE", s.envPath(target, removed), nil, nil)
+		} else {
+			variable.Values = remaining
+			err = s.client.Do("PUT", s.envPath(target, removed), variable, nil)
+		}
+		if err != nil {
+			logging.Logger.Warn("failed to delete Netlify environment variable", "key", removed, "error", err)
+		}
+	}
+}
+
+func (s *NetlifyService) resolveSite(siteID string) (*site, error) {
+	if siteID == "" {
+		return nil, fmt.Errorf("no Netlify site configured, set the profile URL to the site ID or domain")
+	}
+	target := &site{}
+	if err := s.client.Do("GET", "/sites/"+url.PathEscape(siteID), nil, target); err != nil {
+		return nil, fmt.Errorf("failed to get Netlify site %s: %w", siteID, err)
+	}
+	if target.AccountID == "" {
+		target.AccountID = target.AccountSlug
+	}
+	return target, nil
+}
+
+func (s *NetlifyService) listEnv(target *site) (map[string]envVar, error) {
+	variables := []envVar{}
+	if err := s.client.Do("GET", s.envPath(target, ""), nil, &variables); err != nil {
+		return nil, fmt.Errorf("failed to list Netlify environment variables: %w", err)
+	}
+	existing := make(map[string]envVar, len(variables))
+	for _, variable := range variables {
+		existing[variable.Key] = variable
+	}
+	return existing, nil
+}
+
+func (s *NetlifyService) envPath(target *site, key string) string {
+	path := fmt.Sprintf("/accounts/%s/env", url.PathEscape(target.AccountID))
+	if key != "" {
+		path += "/" + url.PathEscape(key)
+	}
+	return path + "?site_id=" + url.QueryEscape(target.ID)
+}
+
+// deployContextForEnv maps an openv environment to a Netlify deploy context.
+// Environments that are not a deploy context are treated as branch names.
+func deployContextForEnv(env string) envValue {
+	switch strings.ToLower(env) {
+	case "production", "prod":
+		return envValue{Context: "production"}
+	case "deploy-preview", "preview":
+		return envValue{Context: "deploy-preview"}
+	case "branch-deploy":
+		return envValue{Context: "branch-deploy"}
+	case "dev", "development", "local":
+		return envValue{Context: "dev"}
+	default:
+		return envValue{Context: contextBranch, ContextParameter: env}
+	}
+}
+
+// findValue returns the value of variable used in the deploy context, if any.
+// A value for the deploy context takes precedence over a value for all contexts.
+func findValue(variable envVar, deployContext envValue) *envValue {
+	var fallback *envValue
+	for i, value := range variable.Values {
+		switch {
+		case value.Context == deployContext.Context && value.ContextParameter == deployContext.ContextParameter:
+			return &variable.Values[i]
+		case value.Context == contextAll:
+			fallback = &variable.Values[i]
+		}
+	}
+	return fallback
+}
+
+// withoutContext returns values without the value of the deploy context.
+// A value for all contexts is split into the standard contexts first, so the other contexts keep it.
+func withoutContext(values []envValue, deployContext envValue) []envValue {
+	result := []envValue{}
+	for _, value := range values {
+		if value.Context == contextAll {
+			for _, context := range standardContexts {
+				result = append(result, envValue{Value: value.Value, Context: context})
+			}
+			continue
+		}
+		result = append(result, value)
+	}
+	return slices.DeleteFunc(result, func(value envValue) bool {
+		return value.Context == deployContext.Context && value.ContextParameter == deployContext.ContextParameter
+	})
+}
+
+// missingScopes returns the scopes the variable is not available in, no scopes means all scopes
+func missingScopes(variable envVar, scopes []Scope) []Scope {
+	if len(variable.Scopes) == 0 {
+		return nil
+	}
+	missing := []Scope{}
+	for _, scope := range scopes {
+		if !slices.Contains(variable.Scopes, scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// mergeScopes adds scopes to the current scopes of a variable, no scopes means all scopes
+func mergeScopes(current, scopes []Scope) []Scope {
+	if len(current) == 0 {
+		return nil
+	}
+	merged := slices.Clone(current)
+	for _, scope := range scopes {
+		if !slices.Contains(merged, scope) {
+			merged = append(merged, scope)
+		}
+	}
+	return merged
+}
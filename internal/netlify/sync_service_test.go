@@ -0,0 +1,286 @@
+package netlify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// fakeNetlify serves the site and environment variable endpoints of the Netlify API for one site
+type fakeNetlify struct {
+	t        *testing.T
+	mu       sync.Mutex
+	vars     map[string]envVar
+	requests []string
+	// bodies holds the raw bodies of the create and update requests
+	bodies []string
+}
+
+func newFakeNetlify(t *testing.T, vars ...envVar) (*fakeNetlify, *NetlifyService) {
+	f := &fakeNetlify{t: t, vars: map[string]envVar{}}
+	for _, v := range vars {
+		f.vars[v.Key] = v
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, NewNetlifyService("token", server.URL)
+}
+
+func (f *fakeNetlify) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path == "/sites/my-site.netlify.app" {
+		writeJSON(w, site{ID: "site-1", AccountSlug: "team"})
+		return
+	}
+	key, isEnv := strings.CutPrefix(r.URL.Path, "/accounts/team/env")
+	if !isEnv || r.URL.Query().Get("site_id") != "site-1" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key = strings.TrimPrefix(key, "/")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		f.t.Errorf("failed to read request body: %v", err)
+	}
+	if len(body) > 0 {
+		f.bodies = append(f.bodies, string(body))
+	}
+
+	switch {
+	case r.Method == "GET" && key == "":
+		list := []envVar{}
+		for _, v := range f.vars {
+			list = append(list, v)
+		}
+		writeJSON(w, list)
+	case r.Method == "POST" && key == "":
+		created := []envVar{}
+		if err := json.Unmarshal(body, &created); err != nil {
+			f.t.Errorf("invalid create body: %v", err)
+		}
+		for _, v := range created {
+			if _, exists := f.vars[v.Key]; exists {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			f.vars[v.Key] = v
+		}
+		writeJSON(w, created)
+	case r.Method == "PUT":
+		var updated envVar
+		if err := json.Unmarshal(body, &updated); err != nil {
+			f.t.Errorf("invalid update body: %v", err)
+		}
+		f.vars[key] = updated
+		writeJSON(w, updated)
+	case r.Method == "DELETE":
+		delete(f.vars, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// contextValues returns the values of a variable by context and context parameter
+func (f *fakeNetlify) contextValues(key string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	values := map[string]string{}
+	for _, value := range f.vars[key].Values {
+		name := value.Context
+		if value.ContextParameter != "" {
+			name += ":" + value.ContextParameter
+		}
+		values[name] = value.Value
+	}
+	return values
+}
+
+func TestSyncToDeployContext(t *testing.T) {
+	f, service := newFakeNetlify(t,
+		envVar{Key: "SHARED", Values: []envValue{{Value: "old", Context: contextAll}}},
+		envVar{Key: "UNCHANGED", Values: []envValue{{Value: "same", Context: "production"}}},
+		envVar{Key: "UNMANAGED", Values: []envValue{{Value: "keep", Context: "production"}}},
+	)
+
+	err := service.SyncToDeployContext("my-site.netlify.app", "production", []Scope{ScopeBuilds}, map[string]string{
+		"SHARED":            "new",
+		"UNCHANGED":         "same",
+		"CREATED":           "value",
+		internal.OPENV_KEYS: `["CREATED","SHARED","UNCHANGED"]`,
+	}, true)
+	if err != nil {
+		t.Fatalf("SyncToDeployContext() error = %v", err)
+	}
+
+	shared := f.contextValues("SHARED")
+	if shared["production"] != "new" || shared["deploy-preview"] != "old" || shared["branch-deploy"] != "old" || shared["dev"] != "old" {
+		t.Errorf("SHARED values = %v, want production updated and the other contexts kept", shared)
+	}
+	if created := f.vars["CREATED"]; !slices.Equal(created.Scopes, []Scope{ScopeBuilds}) || f.contextValues("CREATED")["production"] != "value" {
+		t.Errorf("CREATED = %+v, want production value with builds scope", created)
+	}
+	if f.contextValues(internal.OPENV_KEYS_NETLIFY)["production"] == "" {
+		t.Errorf("bookkeeping variable %s was not created", internal.OPENV_KEYS_NETLIFY)
+	}
+	if f.contextValues("UNMANAGED")["production"] != "keep" {
+		t.Error("UNMANAGED was changed")
+	}
+	if slices.Contains(f.requests, "PUT /accounts/team/env/UNCHANGED") {
+		t.Error("UNCHANGED was updated although its value did not change")
+	}
+}
+
+func TestSyncToDeployContextScopes(t *testing.T) {
+	f, service := newFakeNetlify(t)
+
+	if err := service.SyncToDeployContext("my-site.netlify.app", "production", AllScopes, map[string]string{"KEY": "value"}, false); err != nil {
+		t.Fatalf("SyncToDeployContext() error = %v", err)
+	}
+	if len(f.bodies) != 1 {
+		t.Fatalf("request bodies = %v, want one create request", f.bodies)
+	}
+	want := `"scopes":["builds","functions","runtime","post_processing"]`
+	if !strings.Contains(f.bodies[0], want) {
+		t.Errorf("create request body = %s, want it to contain %s", f.bodies[0], want)
+	}
+}
+
+func TestSyncToDeployContextCleanup(t *testing.T) {
+	f, service := newFakeNetlify(t,
+		envVar{Key: internal.OPENV_KEYS_NETLIFY, Values: []envValue{{Value: `["KEPT","PARTIAL","REMOVED"]`, Context: "production"}}},
+		envVar{Key: "KEPT", Values: []envValue{{Value: "1", Context: "production"}}},
+		envVar{Key: "PARTIAL", Values: []envValue{{Value: "prod", Context: "production"}, {Value: "dev", Context: "dev"}}},
+		envVar{Key: "REMOVED", Values: []envValue{{Value: "gone", Context: "production"}}},
+		envVar{Key: "UNMANAGED", Values: []envValue{{Value: "keep", Context: "production"}}},
+	)
+
+	err := service.SyncToDeployContext("my-site.netlify.app", "prod", nil, map[string]string{
+		"KEPT":              "1",
+		internal.OPENV_KEYS: `["KEPT"]`,
+	}, true)
+	if err != nil {
+		t.Fatalf("SyncToDeployContext() error = %v", err)
+	}
+
+	if _, exists := f.vars["REMOVED"]; exists {
+		t.Error("REMOVED was not deleted")
+	}
+	if partial := f.contextValues("PARTIAL"); len(partial) != 1 || partial["dev"] != "dev" {
+		t.Errorf("PARTIAL values = %v, want only the dev value", partial)
+	}
+	if _, exists := f.vars["UNMANAGED"]; !exists {
+		t.Error("UNMANAGED was deleted")
+	}
+	if got := f.contextValues(internal.OPENV_KEYS_NETLIFY)["production"]; got != `["KEPT"]` {
+		t.Errorf("bookkeeping value = %s, want [\"KEPT\"]", got)
+	}
+}
+
+func TestSyncToDeployContextBranch(t *testing.T) {
+	f, service := newFakeNetlify(t, envVar{Key: "API_URL", Values: []envValue{{Value: "prod", Context: "production"}}})
+
+	if err := service.SyncToDeployContext("my-site.netlify.app", "feature-x", nil, map[string]string{"API_URL": "branch"}, false); err != nil {
+		t.Fatalf("SyncToDeployContext() error = %v", err)
+	}
+	values := f.contextValues("API_URL")
+	if values["production"] != "prod" || values["branch:feature-x"] != "branch" {
+		t.Errorf("API_URL values = %v, want the production value kept and a feature-x branch value", values)
+	}
+}
+
+func TestPlanDeployContext(t *testing.T) {
+	_, service := newFakeNetlify(t,
+		envVar{Key: internal.OPENV_KEYS_NETLIFY, Values: []envValue{{Value: `["SHARED","REMOVED"]`, Context: "production"}}},
+		envVar{Key: "SHARED", Values: []envValue{{Value: "old", Context: contextAll}}},
+		envVar{Key: "REMOVED", Values: []envValue{{Value: "gone", Context: "production"}}},
+		envVar{Key: "OTHER_CONTEXT", Values: []envValue{{Value: "dev", Context: "dev"}}},
+	)
+
+	p, err := service.PlanDeployContext("my-site.netlify.app", "production", nil, map[string]string{
+		"SHARED":            "new",
+		"OTHER_CONTEXT":     "prod",
+		internal.OPENV_KEYS: `["OTHER_CONTEXT","SHARED"]`,
+	})
+	if err != nil {
+		t.Fatalf("PlanDeployContext() error = %v", err)
+	}
+	got := map[string]plan.Action{}
+	for _, change := range p.Changes {
+		got[change.Key] = change.Action
+	}
+	want := map[string]plan.Action{
+		"SHARED":                    plan.ActionUpdate,
+		"OTHER_CONTEXT":             plan.ActionCreate,
+		"REMOVED":                   plan.ActionDelete,
+		internal.OPENV_KEYS_NETLIFY: plan.ActionUpdate,
+	}
+	for key, action := range want {
+		if got[key] != action {
+			t.Errorf("plan action of %s = %s, want %s", key, got[key], action)
+		}
+	}
+}
+
+func TestPlanDeployContextScopes(t *testing.T) {
+	f, service := newFakeNetlify(t,
+		envVar{Key: "ALL_SCOPES", Values: []envValue{{Value: "1", Context: "production"}}},
+		envVar{Key: "SCOPED", Scopes: []Scope{ScopeBuilds}, Values: []envValue{{Value: "1", Context: "production"}}},
+	)
+	envVars := map[string]string{"ALL_SCOPES": "1", "SCOPED": "1"}
+	scopes := []Scope{ScopeBuilds, ScopeRuntime}
+
+	p, err := service.PlanDeployContext("my-site.netlify.app", "production", scopes, envVars)
+	if err != nil {
+		t.Fatalf("PlanDeployContext() error = %v", err)
+	}
+	want := []plan.Change{
+		{Key: "ALL_SCOPES", Action: plan.ActionUnchanged},
+		{Key: "SCOPED", Action: plan.ActionUpdate, Reason: "missing scopes [runtime]"},
+	}
+	if !slices.Equal(p.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", p.Changes, want)
+	}
+
+	if err := service.SyncToDeployContext("my-site.netlify.app", "production", scopes, envVars, false); err != nil {
+		t.Fatalf("SyncToDeployContext() error = %v", err)
+	}
+	if len(f.bodies) != 1 || !strings.Contains(f.bodies[0], `"scopes":["builds","runtime"]`) {
+		t.Errorf("request bodies = %v, want one update of SCOPED adding the runtime scope", f.bodies)
+	}
+}
+
+func TestDeployContextForEnv(t *testing.T) {
+	tests := map[string]envValue{
+		"production": {Context: "production"},
+		"Prod":       {Context: "production"},
+		"preview":    {Context: "deploy-preview"},
+		"local":      {Context: "dev"},
+		"staging":    {Context: contextBranch, ContextParameter: "staging"},
+	}
+	for env, want := range tests {
+		if got := deployContextForEnv(env); got != want {
+			t.Errorf("deployContextForEnv(%s) = %+v, want %+v", env, got, want)
+		}
+	}
+}
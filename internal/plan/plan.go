@@ -0,0 +1,140 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Action is the change applied to a single variable of a target
+type Action string
+
+const (
+	ActionCreate    Action = "create"
+	ActionUpdate    Action = "update"
+	ActionDelete    Action = "delete"
+	ActionUnchanged Action = "no-op"
+)
+
+var actionSymbols = map[Action]string{
+	ActionCreate:    "+",
+	ActionUpdate:    "~",
+	ActionDelete:    "-",
+	ActionUnchanged: " ",
+}
+
+// Change describes what a sync does to a single variable
+type Change struct {
+	Key    string `json:"key"`
+	Action Action `json:"action"`
+	// Reason explains the action if it is not obvious, e.g. that the current value cannot be read
+	Reason string `json:"reason,omitempty"`
+}
+
+// Plan lists the changes a sync applies to a target
+type Plan struct {
+	// Target describes where the variables are synced to, e.g. the sync type and repository
+	Target  string   `json:"target"`
+	Changes []Change `json:"changes"`
+}
+
+// State is the current state of a target
+type State struct {
+	// Values of the existing variables. Nil values mark variables whose value cannot be read back, like secrets.
+	Values map[string]*string
+	// Owned lists the variables created by an earlier sync, as recorded in the OPENV_KEYS_* bookkeeping variable
+	Owned []string
+}
+
+// Summary counts the changes per action
+type Summary struct {
+	Create int `json:"create"`
+	Update int `json:"update"`
+	Delete int `json:"delete"`
+}
+
+// Compute compares the desired variables with the current state of a target.
+// Variables that exist but cannot be read are planned as updates, since a sync always overwrites them.
+// Owned variables that are no longer desired are planned as deletes, other existing variables are left alone.
+func Compute(target string, desired map[string]string, current State) *Plan {
+	p := &Plan{Target: target, Changes: []Change{}}
+
+	for key, value := range desired {
+		existing, exists := current.Values[key]
+		switch {
+		case !exists:
+			p.Changes = append(p.Changes, Change{Key: key, Action: ActionCreate})
+		case existing == nil:
+			p.Changes = append(p.Changes, Change{Key: key, Action: ActionUpdate, Reason: "current value cannot be read"})
+		case *existing != value:
+			p.Changes = append(p.Changes, Change{Key: key, Action: ActionUpdate})
+		default:
+			p.Changes = append(p.Changes, Change{Key: key, Action: ActionUnchanged})
+		}
+	}
+
+	for _, key := range current.Owned {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		if _, exists := current.Values[key]; !exists {
+			// Removed outside of openv, nothing left to delete
+			continue
+		}
+		p.Changes = append(p.Changes, Change{Key: key, Action: ActionDelete})
+	}
+
+	sort.Slice(p.Changes, func(i, j int) bool {
+		return p.Changes[i].Key < p.Changes[j].Key
+	})
+	return p
+}
+
+// Summary counts the changes of the plan per action
+func (p *Plan) Summary() Summary {
+	summary := Summary{}
+	for _, change := range p.Changes {
+		switch change.Action {
+		case ActionCreate:
+			summary.Create++
+		case ActionUpdate:
+			summary.Update++
+		case ActionDelete:
+			summary.Delete++
+		}
+	}
+	return summary
+}
+
+// HasChanges reports whether applying the plan changes anything
+func (p *Plan) HasChanges() bool {
+	summary := p.Summary()
+	return summary.Create+summary.Update+summary.Delete > 0
+}
+
+// Write renders the plan in a Terraform-like format, values are never shown
+func (p *Plan) Write(w io.Writer) error {
+	var content strings.Builder
+	fmt.Fprintf(&content, "%s\n", p.Target)
+	for _, change := range p.Changes {
+		if change.Action == ActionUnchanged {
+			continue
+		}
+		fmt.Fprintf(&content, "  %s %s", actionSymbols[change.Action], change.Key)
+		if change.Reason != "" {
+			fmt.Fprintf(&content, " (%s)", change.Reason)
+		}
+		content.WriteByte('\n')
+	}
+
+	if !p.HasChanges() {
+		content.WriteString("\nNo changes. The target is up to date.\n")
+	} else {
+		summary := p.Summary()
+		fmt.Fprintf(&content, "\nPlan: %d to create, %d to update, %d to delete.\n", summary.Create, summary.Update, summary.Delete)
+	}
+
+	_, err := io.WriteString(w, content.String())
+	return err
+}
@@ -0,0 +1,144 @@
+package plan
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func ptr(value string) *string {
+	return &value
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name    string
+		desired map[string]string
+		current State
+		want    []Change
+	}{
+		{
+			name:    "add",
+			desired: map[string]string{"NEW": "value"},
+			current: State{Values: map[string]*string{}},
+			want:    []Change{{Key: "NEW", Action: ActionCreate}},
+		},
+		{
+			name:    "update",
+			desired: map[string]string{"KEY": "new"},
+			current: State{Values: map[string]*string{"KEY": ptr("old")}},
+			want:    []Change{{Key: "KEY", Action: ActionUpdate}},
+		},
+		{
+			name:    "unchanged",
+			desired: map[string]string{"KEY": "same"},
+			current: State{Values: map[string]*string{"KEY": ptr("same")}},
+			want:    []Change{{Key: "KEY", Action: ActionUnchanged}},
+		},
+		{
+			name:    "unknown value",
+			desired: map[string]string{"SECRET": "value"},
+			current: State{Values: map[string]*string{"SECRET": nil}},
+			want:    []Change{{Key: "SECRET", Action: ActionUpdate, Reason: "current value cannot be read"}},
+		},
+		{
+			name:    "delete owned key",
+			desired: map[string]string{},
+			current: State{Values: map[string]*string{"REMOVED": ptr("gone"), "SECRET": nil}, Owned: []string{"REMOVED", "SECRET"}},
+			want:    []Change{{Key: "REMOVED", Action: ActionDelete}, {Key: "SECRET", Action: ActionDelete}},
+		},
+		{
+			name:    "keep key not owned",
+			desired: map[string]string{},
+			current: State{Values: map[string]*string{"UNMANAGED": ptr("keep")}},
+			want:    []Change{},
+		},
+		{
+			name:    "owned key removed outside of openv",
+			desired: map[string]string{},
+			current: State{Values: map[string]*string{}, Owned: []string{"GONE"}},
+			want:    []Change{},
+		},
+		{
+			name:    "owned key still desired",
+			desired: map[string]string{"KEPT": "value"},
+			current: State{Values: map[string]*string{"KEPT": ptr("value")}, Owned: []string{"KEPT"}},
+			want:    []Change{{Key: "KEPT", Action: ActionUnchanged}},
+		},
+		{
+			name:    "sorted by key",
+			desired: map[string]string{"C": "3", "A": "1"},
+			current: State{Values: map[string]*string{"B": ptr("2")}, Owned: []string{"B"}},
+			want:    []Change{{Key: "A", Action: ActionCreate}, {Key: "B", Action: ActionDelete}, {Key: "C", Action: ActionCreate}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compute("target", tt.desired, tt.current)
+			if got.Target != "target" {
+				t.Errorf("Compute() target = %s, want target", got.Target)
+			}
+			if !slices.Equal(got.Changes, tt.want) {
+				t.Errorf("Compute() changes = %+v, want %+v", got.Changes, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	p := &Plan{Changes: []Change{
+		{Key: "A", Action: ActionCreate},
+		{Key: "B", Action: ActionCreate},
+		{Key: "C", Action: ActionUpdate},
+		{Key: "D", Action: ActionDelete},
+		{Key: "E", Action: ActionUnchanged},
+	}}
+	if got, want := p.Summary(), (Summary{Create: 2, Update: 1, Delete: 1}); got != want {
+		t.Errorf("Summary() = %+v, want %+v", got, want)
+	}
+	if !p.HasChanges() {
+		t.Error("HasChanges() = false, want true")
+	}
+	if (&Plan{Changes: []Change{{Key: "E", Action: ActionUnchanged}}}).HasChanges() {
+		t.Error("HasChanges() of a plan without changes = true, want false")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		plan *Plan
+		want string
+	}{
+		{
+			name: "changes",
+			plan: &Plan{Target: "GitHub repository secrets of org/app", Changes: []Change{
+				{Key: "ADDED", Action: ActionCreate},
+				{Key: "REMOVED", Action: ActionDelete},
+				{Key: "SAME", Action: ActionUnchanged},
+				{Key: "SECRET", Action: ActionUpdate, Reason: "current value cannot be read"},
+			}},
+			want: "GitHub repository secrets of org/app\n" +
+				"  + ADDED\n" +
+				"  - REMOVED\n" +
+				"  ~ SECRET (current value cannot be read)\n" +
+				"\nPlan: 1 to create, 1 to update, 1 to delete.\n",
+		},
+		{
+			name: "no changes",
+			plan: &Plan{Target: "target", Changes: []Change{{Key: "SAME", Action: ActionUnchanged}}},
+			want: "target\n\nNo changes. The target is up to date.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := tt.plan.Write(&out); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Write() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
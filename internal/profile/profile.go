@@ -40,7 +40,44 @@ const (
 
 	ShopifyHydrogenEnvironment SyncType = "shopify-hydrogen-environment"
 
+	GitlabProjectVariable  SyncType = "gitlab-project-variable"
+	GitlabGroupVariable    SyncType = "gitlab-group-variable"
+	GitlabInstanceVariable SyncType = "gitlab-instance-variable"
+
+	BitbucketRepoVariable       SyncType = "bitbucket-repo-variable"
+	BitbucketDeploymentVariable SyncType = "bitbucket-deployment-variable"
+
+	GiteaRepoSecret   SyncType = "gitea-repo-secret"
+	GiteaRepoVariable SyncType = "gitea-repo-variable"
+	GiteaOrgSecret    SyncType = "gitea-org-secret"
+	GiteaOrgVariable  SyncType = "gitea-org-variable"
+
+	AwsSsmParameter         SyncType = "aws-ssm-parameter"
+	AwsSecretsManagerSecret SyncType = "aws-secrets-manager-secret"
+
+	KubernetesSecret SyncType = "kubernetes-secret"
+
+	FlyAppSecret           SyncType = "fly-app-secret"
+	RenderEnvGroup         SyncType = "render-env-group"
+	RenderService          SyncType = "render-service"
+	RailwayServiceVariable SyncType = "railway-service-variable"
+
+	CloudflareWorkerSecret            SyncType = "cloudflare-worker-secret"
+	CloudflareWorkerEnvironmentSecret SyncType = "cloudflare-worker-environment-secret"
+	CloudflarePagesProduction         SyncType = "cloudflare-pages-production"
+	CloudflarePagesPreview            SyncType = "cloudflare-pages-preview"
+
+	HerokuConfigVars SyncType = "heroku-config-vars"
+
+	CircleCIProjectVariable SyncType = "circleci-project-variable"
+	CircleCIContext         SyncType = "circleci-context"
+
+	AzureDevOpsVariableGroup SyncType = "azure-devops-variable-group"
+	AzureKeyVaultSecret      SyncType = "azure-key-vault-secret"
+
 	FlagPrefixWithEnv FlagType = "prefix-with-env"
+	FlagRedeploy      FlagType = "redeploy"
+	FlagProtected     FlagType = "protected"
 )
 
 var (
@@ -76,12 +113,75 @@ var (
 		ShopifyHydrogenEnvironment,
 	}
 
+	ProfileSyncsGitlab = []SyncType{
+		GitlabProjectVariable,
+		GitlabGroupVariable,
+		GitlabInstanceVariable,
+	}
+
+	ProfileSyncsBitbucket = []SyncType{
+		BitbucketRepoVariable,
+		BitbucketDeploymentVariable,
+	}
+
+	ProfileSyncsGitea = []SyncType{
+		GiteaRepoSecret,
+		GiteaRepoVariable,
+		GiteaOrgSecret,
+		GiteaOrgVariable,
+	}
+
+	ProfileSyncsAws = []SyncType{
+		AwsSsmParameter,
+		AwsSecretsManagerSecret,
+	}
+
+	ProfileSyncsKubernetes = []SyncType{
+		KubernetesSecret,
+	}
+
+	ProfileSyncsFly = []SyncType{
+		FlyAppSecret,
+	}
+
+	ProfileSyncsRender = []SyncType{
+		RenderEnvGroup,
+		RenderService,
+	}
+
+	ProfileSyncsRailway = []SyncType{
+		RailwayServiceVariable,
+	}
+
+	ProfileSyncsCloudflare = []SyncType{
+		CloudflareWorkerSecret,
+		CloudflareWorkerEnvironmentSecret,
+		CloudflarePagesProduction,
+		CloudflarePagesPreview,
+	}
+
+	ProfileSyncsHeroku = []SyncType{
+		HerokuConfigVars,
+	}
+
+	ProfileSyncsCircleCI = []SyncType{
+		CircleCIProjectVariable,
+		CircleCIContext,
+	}
+
+	ProfileSyncsAzure = []SyncType{
+		AzureDevOpsVariableGroup,
+		AzureKeyVaultSecret,
+	}
+
 	Flags = []FlagType{
 		FlagPrefixWithEnv,
+		FlagRedeploy,
+		FlagProtected,
 	}
 )
 
-var ActiveProfileSyncs = append([]SyncType{}, ProfileSyncsGithub...)
+var ActiveProfileSyncs = slices.Concat(ProfileSyncsGithub, ProfileSyncsNetlify, ProfileSyncsVercel, ProfileSyncsDeno, ProfileSyncsShopify, ProfileSyncsGitlab, ProfileSyncsBitbucket, ProfileSyncsGitea, ProfileSyncsAws, ProfileSyncsKubernetes, ProfileSyncsFly, ProfileSyncsRender, ProfileSyncsRailway, ProfileSyncsCloudflare, ProfileSyncsHeroku, ProfileSyncsCircleCI, ProfileSyncsAzure)
 
 func TypesToStrings[T Types](types []T) []string {
 	strings := []string{}
@@ -0,0 +1,206 @@
+package railway
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// DefaultBaseURL is the base URL of the Railway public API
+const DefaultBaseURL = "https://backboard.railway.com"
+
+const graphQLPath = "/graphql/v2"
+
+const (
+	environmentsQuery = `query Environments($projectId: String!) {
+  environments(projectId: $projectId) {
+    edges {
+      node {
+        id
+        name
+      }
+    }
+  }
+}`
+
+	variablesQuery = `query Variables($projectId: String!, $environmentId: String!, $serviceId: String) {
+  variables(projectId: $projectId, environmentId: $environmentId, serviceId: $serviceId, unrendered: true)
+}`
+
+	upsertMutation = `mutation Upsert($input: VariableCollectionUpsertInput!) {
+  variableCollectionUpsert(input: $input)
+}`
+
+	deleteMutation = `mutation Delete($input: VariableDeleteInput!) {
+  variableDelete(input: $input)
+}`
+)
+
+// service identifies the variables of a service in an environment
+type service struct {
+	ProjectID     string
+	EnvironmentID string
+	ServiceID     string
+}
+
+// RailwayService handles syncing environment variables to Railway service variables
+type RailwayService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewRailwayService creates a new RailwayService, an empty baseURL uses DefaultBaseURL
+func NewRailwayService(token, baseURL string) *RailwayService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client := rest.NewClient(ctx, baseURL, map[string]string{
+		"Authorization": "Bearer " + token,
+	})
+	return &RailwayService{client: client, ctx: ctx}
+}
+
+// SyncToServiceVariable syncs environment variables to a service in the Railway environment named like env.
+// location is <project id>/<service id>. All variables are upserted in one request.
+func (s *RailwayService) SyncToServiceVariable(location, env string, envVars map[string]string, withCleanup bool) error {
+	target, err := s.resolve(location, env)
+	if err != nil {
+		return err
+	}
+	existing, err := s.listVariables(target)
+	if err != nil {
+		return err
+	}
+
+	logging.Logger.Debug("syncing to Railway service variables",
+		"project", target.ProjectID,
+		"environment", target.EnvironmentID,
+		"service", target.ServiceID)
+
+	if bookkeeping, ok := existing[internal.OPENV_KEYS_RAILWAY]; ok && withCleanup {
+		for _, removed := range internal.ParseKeys(bookkeeping) {
+			if _, exists := envVars[removed]; exists {
+				continue
+			}
+			if _, exists := existing[removed]; !exists {
+				continue
+			}
+			if err := s.client.GraphQL(graphQLPath, deleteMutation, map[string]any{
+				"input": map[string]string{
+					"projectId":     target.ProjectID,
+					"environmentId": target.EnvironmentID,
+					"serviceId":     target.ServiceID,
+					"name":          removed,
+				},
+			}, nil); err != nil {
+				logging.Logger.Warn("failed to delete Railway variable", "key", removed, "error", err)
+			}
+		}
+	}
+
+	variables := map[string]string{}
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_RAILWAY
+		}
+		if current, exists := existing[key]; !exists || current != value {
+			variables[key] = value
+		}
+	}
+	if len(variables) == 0 {
+		return nil
+	}
+
+	if err := s.client.GraphQL(graphQLPath, upsertMutation, map[string]any{
+		"input": map[string]any{
+			"projectId":     target.ProjectID,
+			"environmentId": target.EnvironmentID,
+			"serviceId":     target.ServiceID,
+			"variables":     variables,
+		},
+	}, nil); err != nil {
+		return fmt.Errorf("failed to upsert Railway variables: %w", err)
+	}
+	return nil
+}
+
+// PlanServiceVariable computes the changes SyncToServiceVariable would apply
+func (s *RailwayService) PlanServiceVariable(location, env string, envVars map[string]string) (*plan.Plan, error) {
+	target, err := s.resolve(location, env)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.listVariables(target)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_RAILWAY
+		}
+		desired[key] = value
+	}
+	current := map[string]*string{}
+	for key, value := range existing {
+		current[key] = &value
+	}
+	owned := []string{}
+	if bookkeeping, ok := existing[internal.OPENV_KEYS_RAILWAY]; ok {
+		owned = internal.ParseKeys(bookkeeping)
+	}
+	return plan.Compute(fmt.Sprintf("Railway service %s (%s)", target.ServiceID, env), desired, plan.State{Values: current, Owned: owned}), nil
+}
+
+// resolve looks up the ID of the Railway environment named like env
+func (s *RailwayService) resolve(location, env string) (service, error) {
+	projectID, serviceID, found := strings.Cut(strings.Trim(location, "/"), "/")
+	if !found || projectID == "" || serviceID == "" {
+		return service{}, fmt.Errorf("invalid Railway service %s, set the profile URL to <project id>/<service id>", location)
+	}
+
+	response := struct {
+		Environments struct {
+			Edges []struct {
+				Node struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"node"`
+			} `json:"edges"`
+		} `json:"environments"`
+	}{}
+	if err := s.client.GraphQL(graphQLPath, environmentsQuery, map[string]any{"projectId": projectID}, &response); err != nil {
+		return service{}, fmt.Errorf("failed to list Railway environments: %w", err)
+	}
+	for _, edge := range response.Environments.Edges {
+		if strings.EqualFold(edge.Node.Name, env) {
+			return service{ProjectID: projectID, EnvironmentID: edge.Node.ID, ServiceID: serviceID}, nil
+		}
+	}
+	return service{}, fmt.Errorf("Railway environment %s not found in project %s", env, projectID)
+}
+
+// listVariables returns the unrendered variables, so references like ${{Postgres.DATABASE_URL}} compare as written
+func (s *RailwayService) listVariables(target service) (map[string]string, error) {
+	response := struct {
+		Variables map[string]string `json:"variables"`
+	}{}
+	if err := s.client.GraphQL(graphQLPath, variablesQuery, map[string]any{
+		"projectId":     target.ProjectID,
+		"environmentId": target.EnvironmentID,
+		"serviceId":     target.ServiceID,
+	}, &response); err != nil {
+		return nil, fmt.Errorf("failed to list Railway variables: %w", err)
+	}
+	if response.Variables == nil {
+		return map[string]string{}, nil
+	}
+	return response.Variables, nil
+}
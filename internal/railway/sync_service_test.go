@@ -0,0 +1,209 @@
+package railway
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// fakeRailway serves the environment and variable queries and mutations of the Railway API
+// for project proj-1 with a production and a staging environment
+type fakeRailway struct {
+	t  *testing.T
+	mu sync.Mutex
+	// variables holds the variables of service svc-1 by environment ID
+	variables map[string]map[string]string
+	upserts   []map[string]string
+	deletes   []string
+}
+
+func newFakeRailway(t *testing.T, variables map[string]map[string]string) (*fakeRailway, *RailwayService) {
+	f := &fakeRailway{t: t, variables: variables}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, NewRailwayService("token", server.URL)
+}
+
+func (f *fakeRailway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method != "POST" || r.URL.Path != graphQLPath {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var request struct {
+		Query     string `json:"query"`
+		Variables struct {
+			ProjectID     string `json:"projectId"`
+			EnvironmentID string `json:"environmentId"`
+			ServiceID     string `json:"serviceId"`
+			Input         struct {
+				ProjectID     string            `json:"projectId"`
+				EnvironmentID string            `json:"environmentId"`
+				ServiceID     string            `json:"serviceId"`
+				Name          string            `json:"name"`
+				Variables     map[string]string `json:"variables"`
+			} `json:"input"`
+		} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		f.t.Errorf("invalid GraphQL request: %v", err)
+	}
+	vars, input := request.Variables, request.Variables.Input
+
+	switch {
+	case strings.HasPrefix(request.Query, "query Environments"):
+		if vars.ProjectID != "proj-1" {
+			writeErrors(w, "project not found")
+			return
+		}
+		writeData(w, map[string]any{"environments": map[string]any{"edges": []map[string]any{
+			{"node": map[string]string{"id": "env-prod", "name": "production"}},
+			{"node": map[string]string{"id": "env-staging", "name": "Staging"}},
+		}}})
+	case strings.HasPrefix(request.Query, "query Variables"):
+		if vars.ServiceID != "svc-1" {
+			f.t.Errorf("listed variables of service %s, want svc-1", vars.ServiceID)
+		}
+		writeData(w, map[string]any{"variables": f.variables[vars.EnvironmentID]})
+	case strings.HasPrefix(request.Query, "mutation Upsert"):
+		if input.ProjectID != "proj-1" || input.ServiceID != "svc-1" {
+			f.t.Errorf("upserted variables of %s/%s, want proj-1/svc-1", input.ProjectID, input.ServiceID)
+		}
+		maps.Copy(f.variables[input.EnvironmentID], input.Variables)
+		f.upserts = append(f.upserts, input.Variables)
+		writeData(w, map[string]any{"variableCollectionUpsert": true})
+	case strings.HasPrefix(request.Query, "mutation Delete"):
+		if _, exists := f.variables[input.EnvironmentID][input.Name]; !exists {
+			writeErrors(w, "variable not found")
+			return
+		}
+		delete(f.variables[input.EnvironmentID], input.Name)
+		f.deletes = append(f.deletes, input.EnvironmentID+"/"+input.Name)
+		writeData(w, map[string]any{"variableDelete": true})
+	default:
+		f.t.Errorf("unexpected GraphQL query %s", request.Query)
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func writeData(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+func writeErrors(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"errors": []map[string]string{{"message": message}}})
+}
+
+func TestSyncToServiceVariable(t *testing.T) {
+	f, service := newFakeRailway(t, map[string]map[string]string{
+		"env-prod": {
+			internal.OPENV_KEYS_RAILWAY: `["REMOVED","UNCHANGED","UPDATED"]`,
+			"REMOVED":                   "gone",
+			"UNCHANGED":                 "same",
+			"UPDATED":                   "old",
+			"DATABASE_URL":              "${{Postgres.DATABASE_URL}}",
+		},
+		"env-staging": {"REMOVED": "kept"},
+	})
+
+	err := service.SyncToServiceVariable("proj-1/svc-1", "production", map[string]string{
+		"ADDED":             "value",
+		"UNCHANGED":         "same",
+		"UPDATED":           "new",
+		internal.OPENV_KEYS: `["ADDED","UNCHANGED","UPDATED"]`,
+	}, true)
+	if err != nil {
+		t.Fatalf("SyncToServiceVariable() error = %v", err)
+	}
+
+	if want := []string{"env-prod/REMOVED"}; !slices.Equal(f.deletes, want) {
+		t.Errorf("deletes = %v, want %v", f.deletes, want)
+	}
+	want := []map[string]string{{
+		"ADDED":                     "value",
+		"UPDATED":                   "new",
+		internal.OPENV_KEYS_RAILWAY: `["ADDED","UNCHANGED","UPDATED"]`,
+	}}
+	if !slices.EqualFunc(f.upserts, want, maps.Equal) {
+		t.Errorf("upserts = %v, want one upsert of the changed variables %v", f.upserts, want)
+	}
+	if got := f.variables["env-prod"]["DATABASE_URL"]; got != "${{Postgres.DATABASE_URL}}" {
+		t.Errorf("DATABASE_URL = %s, want the unmanaged reference kept", got)
+	}
+	if _, exists := f.variables["env-staging"]["REMOVED"]; !exists {
+		t.Error("REMOVED was deleted from the staging environment")
+	}
+}
+
+func TestSyncToServiceVariableUnchanged(t *testing.T) {
+	f, service := newFakeRailway(t, map[string]map[string]string{
+		"env-staging": {"KEY": "value", internal.OPENV_KEYS_RAILWAY: `["KEY"]`},
+	})
+
+	// Environment names are matched case-insensitively
+	if err := service.SyncToServiceVariable("proj-1/svc-1/", "staging", map[string]string{"KEY": "value", internal.OPENV_KEYS: `["KEY"]`}, true); err != nil {
+		t.Fatalf("SyncToServiceVariable() error = %v", err)
+	}
+	if len(f.upserts) != 0 || len(f.deletes) != 0 {
+		t.Errorf("upserts = %v, deletes = %v, want no mutation", f.upserts, f.deletes)
+	}
+}
+
+func TestSyncToServiceVariableResolveErrors(t *testing.T) {
+	_, service := newFakeRailway(t, map[string]map[string]string{})
+	tests := map[string]struct {
+		location string
+		env      string
+	}{
+		"missing service":     {"proj-1", "production"},
+		"unknown environment": {"proj-1/svc-1", "preview"},
+		"unknown project":     {"proj-2/svc-1", "production"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := service.SyncToServiceVariable(tt.location, tt.env, map[string]string{"KEY": "value"}, true); err == nil {
+				t.Error("SyncToServiceVariable() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestPlanServiceVariable(t *testing.T) {
+	_, service := newFakeRailway(t, map[string]map[string]string{
+		"env-prod": {
+			internal.OPENV_KEYS_RAILWAY: `["REMOVED","UNCHANGED"]`,
+			"REMOVED":                   "gone",
+			"UNCHANGED":                 "same",
+			"UNMANAGED":                 "keep",
+		},
+	})
+
+	p, err := service.PlanServiceVariable("proj-1/svc-1", "production", map[string]string{"UNCHANGED": "same", "ADDED": "value"})
+	if err != nil {
+		t.Fatalf("PlanServiceVariable() error = %v", err)
+	}
+	want := []plan.Change{
+		{Key: "ADDED", Action: plan.ActionCreate},
+		{Key: "REMOVED", Action: plan.ActionDelete},
+		{Key: "UNCHANGED", Action: plan.ActionUnchanged},
+	}
+	if !slices.Equal(p.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", p.Changes, want)
+	}
+}
@@ -0,0 +1,174 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// DefaultBaseURL is the base URL of the Render API
+const DefaultBaseURL = "https://api.render.com/v1"
+
+const pageSize = 100
+
+type envVar struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// RenderService handles syncing environment variables to Render environment groups and services
+type RenderService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewRenderService creates a new RenderService, an empty baseURL uses DefaultBaseURL
+func NewRenderService(token, baseURL string) *RenderService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client := rest.NewClient(ctx, baseURL, map[string]string{
+		"Authorization": "Bearer " + token,
+	})
+	return &RenderService{client: client, ctx: ctx}
+}
+
+// SyncToEnvGroup syncs environment variables to a Render environment group, given by ID
+func (s *RenderService) SyncToEnvGroup(envGroupID string, envVars map[string]string, withCleanup bool) error {
+	if envGroupID == "" {
+		return fmt.Errorf("no Render environment group configured, set the profile URL to the environment group ID")
+	}
+	existing, err := s.listEnvGroup(envGroupID)
+	if err != nil {
+		return err
+	}
+	return s.syncEnvVars("/env-groups/"+url.PathEscape(envGroupID)+"/env-vars", existing, envVars, withCleanup)
+}
+
+// SyncToService syncs environment variables to the environment of a Render service, given by ID
+func (s *RenderService) SyncToService(serviceID string, envVars map[string]string, withCleanup bool) error {
+	if serviceID == "" {
+		return fmt.Errorf("no Render service configured, set the profile URL to the service ID")
+	}
+	existing, err := s.listService(serviceID)
+	if err != nil {
+		return err
+	}
+	return s.syncEnvVars("/services/"+url.PathEscape(serviceID)+"/env-vars", existing, envVars, withCleanup)
+}
+
+// PlanEnvGroup computes the changes SyncToEnvGroup would apply
+func (s *RenderService) PlanEnvGroup(envGroupID string, envVars map[string]string) (*plan.Plan, error) {
+	existing, err := s.listEnvGroup(envGroupID)
+	if err != nil {
+		return nil, err
+	}
+	return planSync(fmt.Sprintf("Render environment group %s", envGroupID), existing, envVars), nil
+}
+
+// PlanService computes the changes SyncToService would apply
+func (s *RenderService) PlanService(serviceID string, envVars map[string]string) (*plan.Plan, error) {
+	existing, err := s.listService(serviceID)
+	if err != nil {
+		return nil, err
+	}
+	return planSync(fmt.Sprintf("Render service %s", serviceID), existing, envVars), nil
+}
+
+func (s *RenderService) syncEnvVars(path string, existing map[string]string, envVars map[string]string, withCleanup bool) error {
+	logging.Logger.Debug("syncing to Render environment variables", "path", path)
+
+	if bookkeeping, ok := existing[internal.OPENV_KEYS_RENDER]; ok && withCleanup {
+		for _, removed := range internal.ParseKeys(bookkeeping) {
+			if _, exists := envVars[removed]; exists {
+				continue
+			}
+			if _, exists := existing[removed]; !exists {
+				continue
+			}
+			if err := s.client.Do("DELETE", path+"/"+url.PathEscape(removed), nil, nil); err != nil {
+				logging.Logger.Warn("failed to delete Render environment variable", "key", removed, "error", err)
+			}
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(envVars)) {
+		value := envVars[key]
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_RENDER
+		}
+		if current, exists := existing[key]; exists && current == value {
+			continue
+		}
+		if err := s.client.Do("PUT", path+"/"+url.PathEscape(key), map[string]string{"value": value}, nil); err != nil {
+			return fmt.Errorf("failed to set Render environment variable %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (s *RenderService) listEnvGroup(envGroupID string) (map[string]string, error) {
+	group := struct {
+		EnvVars []envVar `json:"envVars"`
+	}{}
+	if err := s.client.Do("GET", "/env-groups/"+url.PathEscape(envGroupID), nil, &group); err != nil {
+		return nil, fmt.Errorf("failed to get Render environment group %s: %w", envGroupID, err)
+	}
+	existing := map[string]string{}
+	for _, v := range group.EnvVars {
+		existing[v.Key] = v.Value
+	}
+	return existing, nil
+}
+
+func (s *RenderService) listService(serviceID string) (map[string]string, error) {
+	existing := map[string]string{}
+	cursor := ""
+	for {
+		path := fmt.Sprintf("/services/%s/env-vars?limit=%d", url.PathEscape(serviceID), pageSize)
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+		page := []struct {
+			EnvVar envVar `json:"envVar"`
+			Cursor string `json:"cursor"`
+		}{}
+		if err := s.client.Do("GET", path, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list Render environment variables of %s: %w", serviceID, err)
+		}
+		for _, item := range page {
+			existing[item.EnvVar.Key] = item.EnvVar.Value
+		}
+		if len(page) < pageSize {
+			return existing, nil
+		}
+		cursor = page[len(page)-1].Cursor
+	}
+}
+
+func planSync(target string, existing map[string]string, envVars map[string]string) *plan.Plan {
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_RENDER
+		}
+		desired[key] = value
+	}
+	current := map[string]*string{}
+	for key, value := range existing {
+		current[key] = &value
+	}
+	owned := []string{}
+	if bookkeeping, ok := existing[internal.OPENV_KEYS_RENDER]; ok {
+		owned = internal.ParseKeys(bookkeeping)
+	}
+	return plan.Compute(target, desired, plan.State{Values: current, Owned: owned})
+}
@@ -0,0 +1,195 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// fakeRender serves the environment variables of Render environment groups and services
+type fakeRender struct {
+	t         *testing.T
+	mu        sync.Mutex
+	envGroups map[string]map[string]string
+	services  map[string]map[string]string
+	// requests lists the requests changing variables
+	requests []string
+}
+
+func newFakeRender(t *testing.T) (*fakeRender, *RenderService) {
+	f := &fakeRender{t: t, envGroups: map[string]map[string]string{}, services: map[string]map[string]string{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, NewRenderService("token", server.URL)
+}
+
+func (f *fakeRender) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	owners := map[string]map[string]map[string]string{"env-groups": f.envGroups, "services": f.services}[parts[0]]
+	if owners == nil || len(parts) < 2 || owners[parts[1]] == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	vars := owners[parts[1]]
+	if r.Method != "GET" {
+		f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	}
+
+	switch {
+	case r.Method == "GET" && parts[0] == "env-groups" && len(parts) == 2:
+		envVars := []envVar{}
+		for _, key := range slices.Sorted(maps.Keys(vars)) {
+			envVars = append(envVars, envVar{Key: key, Value: vars[key]})
+		}
+		writeJSON(w, map[string]any{"id": parts[1], "envVars": envVars})
+	case r.Method == "GET" && parts[0] == "services" && len(parts) == 3:
+		// Items after the cursor, which is the key of the last item of the previous page
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		cursor := r.URL.Query().Get("cursor")
+		items := []map[string]any{}
+		for _, key := range slices.Sorted(maps.Keys(vars)) {
+			if key > cursor && len(items) < limit {
+				items = append(items, map[string]any{"envVar": envVar{Key: key, Value: vars[key]}, "cursor": key})
+			}
+		}
+		writeJSON(w, items)
+	case r.Method == "PUT" && len(parts) == 4:
+		var body struct {
+			Value string `json:"value"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Errorf("invalid request body: %v", err)
+		}
+		vars[parts[3]] = body.Value
+		writeJSON(w, envVar{Key: parts[3], Value: body.Value})
+	case r.Method == "DELETE" && len(parts) == 4:
+		if _, exists := vars[parts[3]]; !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(vars, parts[3])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestSyncToEnvGroup(t *testing.T) {
+	f, service := newFakeRender(t)
+	f.envGroups["evg-1"] = map[string]string{
+		internal.OPENV_KEYS_RENDER: `["REMOVED","UNCHANGED","UPDATED"]`,
+		"REMOVED":                  "gone",
+		"UNCHANGED":                "same",
+		"UPDATED":                  "old",
+		"UNMANAGED":                "keep",
+	}
+
+	err := service.SyncToEnvGroup("evg-1", map[string]string{
+		"ADDED":             "value",
+		"UNCHANGED":         "same",
+		"UPDATED":           "new",
+		internal.OPENV_KEYS: `["ADDED","UNCHANGED","UPDATED"]`,
+	}, true)
+	if err != nil {
+		t.Fatalf("SyncToEnvGroup() error = %v", err)
+	}
+
+	want := []string{
+		"DELETE /env-groups/evg-1/env-vars/REMOVED",
+		"PUT /env-groups/evg-1/env-vars/ADDED",
+		"PUT /env-groups/evg-1/env-vars/" + internal.OPENV_KEYS_RENDER,
+		"PUT /env-groups/evg-1/env-vars/UPDATED",
+	}
+	if !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %v, want %v", f.requests, want)
+	}
+	if got := f.envGroups["evg-1"]["UNMANAGED"]; got != "keep" {
+		t.Errorf("UNMANAGED = %q, want it kept", got)
+	}
+}
+
+func TestSyncToService(t *testing.T) {
+	f, service := newFakeRender(t)
+	vars := map[string]string{internal.OPENV_KEYS_RENDER: `["REMOVED"]`}
+	// More than a page, REMOVED sorts after the first page and is only found by following the cursor
+	for i := range pageSize + 20 {
+		vars[fmt.Sprintf("FILLER_%03d", i)] = "value"
+	}
+	vars["REMOVED"] = "gone"
+	vars["UPDATED"] = "old"
+	f.services["srv-1"] = vars
+
+	if err := service.SyncToService("srv-1", map[string]string{"UPDATED": "new", internal.OPENV_KEYS: `["UPDATED"]`}, true); err != nil {
+		t.Fatalf("SyncToService() error = %v", err)
+	}
+
+	want := []string{
+		"DELETE /services/srv-1/env-vars/REMOVED",
+		"PUT /services/srv-1/env-vars/" + internal.OPENV_KEYS_RENDER,
+		"PUT /services/srv-1/env-vars/UPDATED",
+	}
+	if !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %v, want %v", f.requests, want)
+	}
+	if len(f.envGroups) != 0 {
+		t.Errorf("environment groups = %v, want none touched", f.envGroups)
+	}
+}
+
+func TestPlanService(t *testing.T) {
+	f, service := newFakeRender(t)
+	vars := map[string]string{
+		internal.OPENV_KEYS_RENDER: `["REMOVED","UNCHANGED"]`,
+		"UNCHANGED":                "same",
+	}
+	for i := range pageSize {
+		vars[fmt.Sprintf("FILLER_%03d", i)] = "value"
+	}
+	vars["REMOVED"] = "gone"
+	f.services["srv-1"] = vars
+
+	p, err := service.PlanService("srv-1", map[string]string{"UNCHANGED": "same", "ADDED": "value"})
+	if err != nil {
+		t.Fatalf("PlanService() error = %v", err)
+	}
+	want := []plan.Change{
+		{Key: "ADDED", Action: plan.ActionCreate},
+		{Key: "REMOVED", Action: plan.ActionDelete},
+		{Key: "UNCHANGED", Action: plan.ActionUnchanged},
+	}
+	if !slices.Equal(p.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", p.Changes, want)
+	}
+}
+
+func TestSyncRequiresID(t *testing.T) {
+	_, service := newFakeRender(t)
+	if err := service.SyncToEnvGroup("", map[string]string{}, true); err == nil {
+		t.Error("SyncToEnvGroup() error = nil, want missing environment group")
+	}
+	if err := service.SyncToService("", map[string]string{}, true); err == nil {
+		t.Error("SyncToService() error = nil, want missing service")
+	}
+}
@@ -0,0 +1,194 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hinterland-software/openv/internal/version"
+)
+
+// Client is a minimal JSON REST client shared by the services without an official Go SDK
+type Client struct {
+	baseURL    string
+	headers    http.Header
+	httpClient *http.Client
+	ctx        context.Context
+	signer     Signer
+}
+
+// Signer signs a request with its body before it is sent, e.g. with AWS Signature Version 4
+type Signer func(req *http.Request, body []byte) error
+
+// StatusError is returned when the API responds with a non-2xx status code
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s returned %d: %s", e.Method, e.URL, e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// IsNotFound reports whether err is a StatusError with status 404
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// NewClient creates a new client sending the given headers with every request
+func NewClient(ctx context.Context, baseURL string, headers map[string]string) *Client {
+	h := http.Header{}
+	h.Set("Accept", "application/json")
+	h.Set("User-Agent", "openv/"+version.Version)
+	for key, value := range headers {
+		h.Set(key, value)
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		headers:    h,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		ctx:        ctx,
+	}
+}
+
+// WithSigner returns the client signing every request with signer
+func (c *Client) WithSigner(signer Signer) *Client {
+	c.signer = signer
+	return c
+}
+
+// WithTransport returns the client sending requests with transport, e.g. for custom TLS settings
+func (c *Client) WithTransport(transport http.RoundTripper) *Client {
+	c.httpClient.Transport = transport
+	return c
+}
+
+// BaseURL returns the base URL requests are sent to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Do sends body encoded as JSON and decodes the JSON response into out.
+// body and out may be nil.
+func (c *Client) Do(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+	return c.DoRaw(method, path, "application/json", reader, out)
+}
+
+// DoRaw sends body with the given content type and decodes the JSON response into out
+func (c *Client) DoRaw(method, path, contentType string, body io.Reader, out any) error {
+	return c.DoRawWithHeaders(method, path, contentType, nil, body, out)
+}
+
+// DoRawWithHeaders works like DoRaw, sending additional headers with the request
+func (c *Client) DoRawWithHeaders(method, path, contentType string, headers map[string]string, body io.Reader, out any) error {
+	url := c.baseURL + path
+	payload := []byte{}
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return fmt.Errorf("failed to read request body: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(c.ctx, method, url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = c.headers.Clone()
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.signer != nil {
+		if err := c.signer(req, payload); err != nil {
+			return fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response of %s %s: %w", method, url, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{
+			Method:     method,
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Body:       string(respBody),
+		}
+	}
+
+	if out == nil || len(bytes.TrimSpace(respBody)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode response of %s %s: %w", method, url, err)
+	}
+	return nil
+}
+
+// GraphQLError is an error reported in the errors list of a GraphQL response
+type GraphQLError struct {
+	Messages []string
+}
+
+func (e *GraphQLError) Error() string {
+	return "GraphQL errors: " + strings.Join(e.Messages, "; ")
+}
+
+// GraphQL posts a GraphQL query to path and decodes the data of the response into out
+func (c *Client) GraphQL(path, query string, variables map[string]any, out any) error {
+	request := map[string]any{"query": query}
+	if len(variables) > 0 {
+		request["variables"] = variables
+	}
+	response := struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	if err := c.Do("POST", path, request, &response); err != nil {
+		return err
+	}
+	if len(response.Errors) > 0 {
+		graphQLErr := &GraphQLError{}
+		for _, e := range response.Errors {
+			graphQLErr.Messages = append(graphQLErr.Messages, e.Message)
+		}
+		return graphQLErr
+	}
+	if out == nil || len(response.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(response.Data, out); err != nil {
+		return fmt.Errorf("failed to decode GraphQL data: %w", err)
+	}
+	return nil
+}
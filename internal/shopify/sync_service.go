@@ -0,0 +1,335 @@
+package shopify
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// adminAPIVersion is the dated Admin API version exposing Hydrogen storefronts
+const adminAPIVersion = "2026-07"
+
+// adminPath is the Admin GraphQL API path exposing Hydrogen storefronts
+const adminPath = "/admin/api/" + adminAPIVersion + "/graphql.json"
+
+const storefrontIDPrefix = "gid://shopify/HydrogenStorefront/"
+
+const (
+	listStorefrontsQuery = `query ListStorefronts {
+  hydrogenStorefronts {
+    id
+    parsedId
+    title
+  }
+}`
+
+	listEnvironmentsQuery = `query ListEnvironments($id: ID!) {
+  hydrogenStorefront(id: $id) {
+    environments {
+      id
+      name
+      handle
+      branch
+      type
+    }
+  }
+}`
+
+	listVariablesQuery = `query ListVariables($id: ID!, $handle: String) {
+  hydrogenStorefront(id: $id) {
+    environmentVariables(handle: $handle) {
+      id
+      key
+      value
+      isSecret
+      readOnly
+    }
+  }
+}`
+
+	replaceVariablesMutation = `mutation ReplaceVariables($storefrontId: ID!, $environmentId: ID, $environmentVariablesInput: [EnvironmentVariableInput!]!) {
+  environmentVariablesBulkReplace(storefrontId: $storefrontId, environmentId: $environmentId, environmentVariablesInput: $environmentVariablesInput) {
+    userErrors {
+      code
+      field
+      message
+    }
+  }
+}`
+)
+
+type storefront struct {
+	ID       string `json:"id"`
+	ParsedID string `json:"parsedId"`
+	Title    string `json:"title"`
+}
+
+type environment struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Handle string `json:"handle"`
+	Branch string `json:"branch"`
+	Type   string `json:"type"`
+}
+
+type variable struct {
+	ID       string `json:"id"`
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	IsSecret bool   `json:"isSecret"`
+	ReadOnly bool   `json:"readOnly"`
+}
+
+type variableInput struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	IsSecret bool   `json:"isSecret"`
+}
+
+// ShopifyService handles syncing environment variables to Shopify Hydrogen storefronts deployed on Oxygen
+type ShopifyService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewShopifyService creates a new ShopifyService for a shop, e.g. my-shop.myshopify.com.
+// An empty baseURL uses the Admin API of the shop.
+func NewShopifyService(token, shop, baseURL string) *ShopifyService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = "https://" + shop
+	}
+	client := rest.NewClient(ctx, baseURL, map[string]string{
+		"X-Shopify-Access-Token": token,
+	})
+	return &ShopifyService{client: client, ctx: ctx}
+}
+
+// SyncToEnvironment syncs environment variables to the Oxygen environment of a storefront matching env.
+// Keys listed in plain are stored as regular variables, all others as secrets.
+// Variables not created by openv are kept, read-only variables managed by Oxygen are never changed.
+// Secrets not created by openv cannot be read back and sent along, so the sync fails instead of deleting them.
+func (s *ShopifyService) SyncToEnvironment(storefrontRef, env string, envVars map[string]string, plain []string, withCleanup bool) error {
+	target, oxygenEnv, existing, err := s.load(storefrontRef, env)
+	if err != nil {
+		return err
+	}
+
+	logging.Logger.Debug("syncing to Oxygen environment",
+		"storefront", target.Title,
+		"environment", oxygenEnv.Handle)
+
+	removed := []string{}
+	if withCleanup {
+		removed = ownedKeys(existing)
+	}
+	if err := checkKept(oxygenEnv, existing, envVars, removed); err != nil {
+		return err
+	}
+
+	// The bulk replace mutation replaces all variables of the environment,
+	// so variables not managed by openv are sent back unchanged
+	inputs := []variableInput{}
+	for _, current := range existing {
+		_, desired := envVars[current.Key]
+		switch {
+		case current.ReadOnly, desired, current.Key == internal.OPENV_KEYS_SHOPIFY:
+			continue
+		case slices.Contains(removed, current.Key):
+			logging.Logger.Debug("removing Oxygen environment variable", "key", current.Key)
+			continue
+		}
+		inputs = append(inputs, variableInput{Key: current.Key, Value: current.Value})
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(envVars)) {
+		value := envVars[key]
+		input := variableInput{Key: key, Value: value, IsSecret: !slices.Contains(plain, key)}
+		if key == internal.OPENV_KEYS {
+			input = variableInput{Key: internal.OPENV_KEYS_SHOPIFY, Value: value}
+		}
+		if current := findVariable(existing, input.Key); current != nil && current.ReadOnly {
+			return fmt.Errorf("Oxygen environment variable %s is read-only", key)
+		}
+		inputs = append(inputs, input)
+	}
+
+	response := struct {
+		EnvironmentVariablesBulkReplace struct {
+			UserErrors []struct {
+				Code    string   `json:"code"`
+				Field   []string `json:"field"`
+				Message string   `json:"message"`
+			} `json:"userErrors"`
+		} `json:"environmentVariablesBulkReplace"`
+	}{}
+	if err := s.client.GraphQL(adminPath, replaceVariablesMutation, map[string]any{
+		"storefrontId":              target.ID,
+		"environmentId":             oxygenEnv.ID,
+		"environmentVariablesInput": inputs,
+	}, &response); err != nil {
+		return fmt.Errorf("failed to replace Oxygen environment variables: %w", err)
+	}
+
+	if userErrors := response.EnvironmentVariablesBulkReplace.UserErrors; len(userErrors) > 0 {
+		messages := []string{}
+		for _, userErr := range userErrors {
+			messages = append(messages, userErr.Message)
+		}
+		return fmt.Errorf("failed to replace Oxygen environment variables: %s", strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// PlanEnvironment computes the changes SyncToEnvironment would apply.
+// It fails like the sync if a secret not created by openv would have to be sent back.
+func (s *ShopifyService) PlanEnvironment(storefrontRef, env string, envVars map[string]string) (*plan.Plan, error) {
+	target, oxygenEnv, existing, err := s.load(storefrontRef, env)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKept(oxygenEnv, existing, envVars, ownedKeys(existing)); err != nil {
+		return nil, err
+	}
+
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_SHOPIFY
+		}
+		desired[key] = value
+	}
+
+	current := map[string]*string{}
+	for _, v := range existing {
+		current[v.Key] = nil
+		if !v.IsSecret {
+			current[v.Key] = &v.Value
+		}
+	}
+
+	return plan.Compute(fmt.Sprintf("Oxygen environment %s of storefront %s", oxygenEnv.Handle, target.Title), desired, plan.State{
+		Values: current,
+		Owned:  ownedKeys(existing),
+	}), nil
+}
+
+// load looks up the storefront, the Oxygen environment and its current variables
+func (s *ShopifyService) load(storefrontRef, env string) (*storefront, *environment, []variable, error) {
+	target, err := s.findStorefront(storefrontRef)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	oxygenEnv, err := s.findEnvironment(target, env)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	response := struct {
+		HydrogenStorefront struct {
+			EnvironmentVariables []variable `json:"environmentVariables"`
+		} `json:"hydrogenStorefront"`
+	}{}
+	if err := s.client.GraphQL(adminPath, listVariablesQuery, map[string]any{
+		"id":     target.ID,
+		"handle": oxygenEnv.Handle,
+	}, &response); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list Oxygen environment variables: %w", err)
+	}
+	return target, oxygenEnv, response.HydrogenStorefront.EnvironmentVariables, nil
+}
+
+// findStorefront finds a storefront by ID, numeric ID or title
+func (s *ShopifyService) findStorefront(ref string) (*storefront, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("no Hydrogen storefront configured, set the profile URL to <shop>.myshopify.com/<storefront>")
+	}
+	response := struct {
+		HydrogenStorefronts []storefront `json:"hydrogenStorefronts"`
+	}{}
+	if err := s.client.GraphQL(adminPath, listStorefrontsQuery, nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to list Hydrogen storefronts: %w", err)
+	}
+	for _, candidate := range response.HydrogenStorefronts {
+		if candidate.ID == ref || candidate.ParsedID == ref || strings.EqualFold(candidate.Title, ref) {
+			return &candidate, nil
+		}
+	}
+	return nil, fmt.Errorf("Hydrogen storefront %s not found", ref)
+}
+
+// findEnvironment maps an openv environment to an Oxygen environment by handle, name or branch.
+// production and preview fall back to the environment of that type.
+func (s *ShopifyService) findEnvironment(target *storefront, env string) (*environment, error) {
+	response := struct {
+		HydrogenStorefront struct {
+			Environments []environment `json:"environments"`
+		} `json:"hydrogenStorefront"`
+	}{}
+	if err := s.client.GraphQL(adminPath, listEnvironmentsQuery, map[string]any{"id": target.ID}, &response); err != nil {
+		return nil, fmt.Errorf("failed to list Oxygen environments: %w", err)
+	}
+
+	environments := response.HydrogenStorefront.Environments
+	for _, candidate := range environments {
+		if candidate.Handle == env || strings.EqualFold(candidate.Name, env) || (candidate.Branch != "" && candidate.Branch == env) {
+			return &candidate, nil
+		}
+	}
+	for _, candidate := range environments {
+		if strings.EqualFold(candidate.Type, env) {
+			return &candidate, nil
+		}
+	}
+	return nil, fmt.Errorf("Oxygen environment %s not found in storefront %s", env, target.Title)
+}
+
+// DeriveLocationFromURL splits a profile URL of the form <shop>.myshopify.com/<storefront> into shop and storefront
+func DeriveLocationFromURL(url string) (string, string, error) {
+	url = strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	shop, ref, found := strings.Cut(strings.Trim(url, "/"), "/")
+	if !found || shop == "" || ref == "" {
+		return "", "", fmt.Errorf("invalid Hydrogen storefront %s, expected <shop>.myshopify.com/<storefront>", url)
+	}
+	if _, err := strconv.Atoi(ref); err == nil {
+		ref = storefrontIDPrefix + ref
+	}
+	return shop, ref, nil
+}
+
+// checkKept fails if the replacement would have to keep a secret, whose value cannot be read back
+func checkKept(oxygenEnv *environment, existing []variable, envVars map[string]string, removed []string) error {
+	for _, current := range existing {
+		_, desired := envVars[current.Key]
+		if current.IsSecret && !current.ReadOnly && !desired && !slices.Contains(removed, current.Key) {
+			return fmt.Errorf("cannot keep secret %s in Oxygen environment %s, its value cannot be read back", current.Key, oxygenEnv.Handle)
+		}
+	}
+	return nil
+}
+
+func findVariable(variables []variable, key string) *variable {
+	for i, v := range variables {
+		if v.Key == key {
+			return &variables[i]
+		}
+	}
+	return nil
+}
+
+// ownedKeys returns the keys recorded in the OPENV_KEYS_SHOPIFY bookkeeping variable
+func ownedKeys(variables []variable) []string {
+	bookkeeping := findVariable(variables, internal.OPENV_KEYS_SHOPIFY)
+	if bookkeeping == nil {
+		return []string{}
+	}
+	return internal.ParseKeys(bookkeeping.Value)
+}
@@ -0,0 +1,253 @@
+package shopify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+const storefrontID = storefrontIDPrefix + "1"
+
+// fakeAdmin serves the Hydrogen storefront queries and the bulk replace mutation of the Admin GraphQL API
+// for one storefront with a production and a preview environment
+type fakeAdmin struct {
+	t          *testing.T
+	mu         sync.Mutex
+	vars       []variable
+	userErrors []string
+	replaced   []variableInput
+}
+
+func newFakeAdmin(t *testing.T, vars ...variable) (*fakeAdmin, *ShopifyService) {
+	f := &fakeAdmin{t: t, vars: vars}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, NewShopifyService("token", "my-shop.myshopify.com", server.URL)
+}
+
+func (f *fakeAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method != "POST" || r.URL.Path != adminPath {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Header.Get("X-Shopify-Access-Token") != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var request struct {
+		Query     string          `json:"query"`
+		Variables json.RawMessage `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		f.t.Errorf("invalid GraphQL request: %v", err)
+	}
+
+	switch {
+	case strings.HasPrefix(request.Query, "query ListStorefronts"):
+		writeData(w, map[string]any{"hydrogenStorefronts": []storefront{{ID: storefrontID, ParsedID: "1", Title: "Shop"}}})
+	case strings.HasPrefix(request.Query, "query ListEnvironments"):
+		writeData(w, map[string]any{"hydrogenStorefront": map[string]any{"environments": []environment{
+			{ID: "env-1", Name: "Production", Handle: "production", Type: "PRODUCTION"},
+			{ID: "env-2", Name: "Preview", Handle: "preview", Type: "PREVIEW"},
+		}}})
+	case strings.HasPrefix(request.Query, "query ListVariables"):
+		vars := []variable{}
+		for _, v := range f.vars {
+			if v.IsSecret {
+				v.Value = ""
+			}
+			vars = append(vars, v)
+		}
+		writeData(w, map[string]any{"hydrogenStorefront": map[string]any{"environmentVariables": vars}})
+	case strings.HasPrefix(request.Query, "mutation ReplaceVariables"):
+		var variables struct {
+			StorefrontID  string          `json:"storefrontId"`
+			EnvironmentID string          `json:"environmentId"`
+			Inputs        []variableInput `json:"environmentVariablesInput"`
+		}
+		if err := json.Unmarshal(request.Variables, &variables); err != nil {
+			f.t.Errorf("invalid mutation variables: %v", err)
+		}
+		if variables.StorefrontID != storefrontID || variables.EnvironmentID != "env-1" {
+			f.t.Errorf("replaced variables of %s/%s, want %s/env-1", variables.StorefrontID, variables.EnvironmentID, storefrontID)
+		}
+		userErrors := []map[string]any{}
+		for _, message := range f.userErrors {
+			userErrors = append(userErrors, map[string]any{"code": "INVALID", "field": []string{}, "message": message})
+		}
+		f.replaced = variables.Inputs
+		writeData(w, map[string]any{"environmentVariablesBulkReplace": map[string]any{"userErrors": userErrors}})
+	default:
+		f.t.Errorf("unexpected GraphQL query %s", request.Query)
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func writeData(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+// replacedInput returns the input sent for a key, or nil if it was left out
+func (f *fakeAdmin) replacedInput(key string) *variableInput {
+	for i, input := range f.replaced {
+		if input.Key == key {
+			return &f.replaced[i]
+		}
+	}
+	return nil
+}
+
+func TestSyncToEnvironment(t *testing.T) {
+	f, service := newFakeAdmin(t,
+		variable{Key: internal.OPENV_KEYS_SHOPIFY, Value: `["KEPT","REMOVED"]`},
+		variable{Key: "KEPT", Value: "old", IsSecret: true},
+		variable{Key: "REMOVED", Value: "gone"},
+		variable{Key: "UNMANAGED", Value: "keep"},
+		variable{Key: "PUBLIC_STORE_DOMAIN", Value: "my-shop.myshopify.com", ReadOnly: true},
+	)
+
+	err := service.SyncToEnvironment("Shop", "production", map[string]string{
+		"KEPT":              "new",
+		"PUBLIC_URL":        "https://example.com",
+		internal.OPENV_KEYS: `["KEPT","PUBLIC_URL"]`,
+	}, []string{"PUBLIC_URL"}, true)
+	if err != nil {
+		t.Fatalf("SyncToEnvironment() error = %v", err)
+	}
+
+	if input := f.replacedInput("KEPT"); input == nil || input.Value != "new" || !input.IsSecret {
+		t.Errorf("KEPT input = %+v, want the new value as secret", input)
+	}
+	if input := f.replacedInput("PUBLIC_URL"); input == nil || input.IsSecret {
+		t.Errorf("PUBLIC_URL input = %+v, want a plain variable", input)
+	}
+	if input := f.replacedInput("UNMANAGED"); input == nil || input.Value != "keep" {
+		t.Errorf("UNMANAGED input = %+v, want the current value sent back", input)
+	}
+	if input := f.replacedInput(internal.OPENV_KEYS_SHOPIFY); input == nil || input.Value != `["KEPT","PUBLIC_URL"]` || input.IsSecret {
+		t.Errorf("bookkeeping input = %+v, want the new keys as plain variable", input)
+	}
+	for _, key := range []string{"REMOVED", "PUBLIC_STORE_DOMAIN", internal.OPENV_KEYS} {
+		if input := f.replacedInput(key); input != nil {
+			t.Errorf("%s input = %+v, want it left out", key, input)
+		}
+	}
+}
+
+func TestSyncToEnvironmentWithoutCleanup(t *testing.T) {
+	f, service := newFakeAdmin(t,
+		variable{Key: internal.OPENV_KEYS_SHOPIFY, Value: `["REMOVED"]`},
+		variable{Key: "REMOVED", Value: "kept"},
+	)
+
+	if err := service.SyncToEnvironment("1", "PRODUCTION", map[string]string{"KEY": "value"}, nil, false); err != nil {
+		t.Fatalf("SyncToEnvironment() error = %v", err)
+	}
+	if input := f.replacedInput("REMOVED"); input == nil || input.Value != "kept" {
+		t.Errorf("REMOVED input = %+v, want it sent back without cleanup", input)
+	}
+}
+
+func TestSyncToEnvironmentReadOnly(t *testing.T) {
+	f, service := newFakeAdmin(t, variable{Key: "PUBLIC_STORE_DOMAIN", Value: "my-shop.myshopify.com", ReadOnly: true})
+
+	err := service.SyncToEnvironment("Shop", "production", map[string]string{"PUBLIC_STORE_DOMAIN": "other"}, nil, true)
+	if err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Fatalf("SyncToEnvironment() error = %v, want read-only error", err)
+	}
+	if f.replaced != nil {
+		t.Errorf("variables were replaced although the sync failed")
+	}
+}
+
+func TestSyncToEnvironmentUnmanagedSecret(t *testing.T) {
+	f, service := newFakeAdmin(t, variable{Key: "UNMANAGED_SECRET", Value: "hidden", IsSecret: true})
+
+	err := service.SyncToEnvironment("Shop", "production", map[string]string{"KEY": "value"}, nil, true)
+	if err == nil || !strings.Contains(err.Error(), "UNMANAGED_SECRET") {
+		t.Fatalf("SyncToEnvironment() error = %v, want an error naming the unmanaged secret", err)
+	}
+	if f.replaced != nil {
+		t.Errorf("variables were replaced, which deletes the unmanaged secret")
+	}
+
+	if _, err := service.PlanEnvironment("Shop", "production", map[string]string{"KEY": "value"}); err == nil {
+		t.Error("PlanEnvironment() error = nil, want the sync failure reported")
+	}
+}
+
+func TestSyncToEnvironmentUserErrors(t *testing.T) {
+	f, service := newFakeAdmin(t)
+	f.userErrors = []string{"Key is invalid"}
+
+	err := service.SyncToEnvironment("Shop", "production", map[string]string{"KEY": "value"}, nil, true)
+	if err == nil || !strings.Contains(err.Error(), "Key is invalid") {
+		t.Fatalf("SyncToEnvironment() error = %v, want the user error", err)
+	}
+}
+
+func TestPlanEnvironment(t *testing.T) {
+	_, service := newFakeAdmin(t,
+		variable{Key: internal.OPENV_KEYS_SHOPIFY, Value: `["SAME","SECRET","REMOVED"]`},
+		variable{Key: "SAME", Value: "value"},
+		variable{Key: "SECRET", Value: "hidden", IsSecret: true},
+		variable{Key: "REMOVED", Value: "gone"},
+		variable{Key: "UNMANAGED", Value: "keep"},
+	)
+
+	p, err := service.PlanEnvironment("Shop", "production", map[string]string{
+		"SAME":              "value",
+		"SECRET":            "hidden",
+		"ADDED":             "new",
+		internal.OPENV_KEYS: `["ADDED","SAME","SECRET"]`,
+	})
+	if err != nil {
+		t.Fatalf("PlanEnvironment() error = %v", err)
+	}
+
+	actions := map[string]plan.Action{}
+	for _, change := range p.Changes {
+		actions[change.Key] = change.Action
+	}
+	want := map[string]plan.Action{
+		"SAME":    plan.ActionUnchanged,
+		"SECRET":  plan.ActionUpdate,
+		"ADDED":   plan.ActionCreate,
+		"REMOVED": plan.ActionDelete,
+	}
+	for key, action := range want {
+		if actions[key] != action {
+			t.Errorf("action of %s = %v, want %v", key, actions[key], action)
+		}
+	}
+	if _, ok := actions["UNMANAGED"]; ok {
+		t.Error("plan includes UNMANAGED, which openv does not own")
+	}
+}
+
+func TestDeriveLocationFromURL(t *testing.T) {
+	tests := []struct {
+		url, shop, ref string
+		wantErr        bool
+	}{
+		{url: "my-shop.myshopify.com/Shop", shop: "my-shop.myshopify.com", ref: "Shop"},
+		{url: "https://my-shop.myshopify.com/42/", shop: "my-shop.myshopify.com", ref: storefrontIDPrefix + "42"},
+		{url: "my-shop.myshopify.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		shop, ref, err := DeriveLocationFromURL(tt.url)
+		if (err != nil) != tt.wantErr || shop != tt.shop || ref != tt.ref {
+			t.Errorf("DeriveLocationFromURL(%q) = %q, %q, %v, want %q, %q", tt.url, shop, ref, err, tt.shop, tt.ref)
+		}
+	}
+}
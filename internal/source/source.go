@@ -0,0 +1,345 @@
+package source
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hinterland-software/openv/internal/dotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an input format for imported environment variables
+type Format string
+
+const (
+	FormatDotenv Format = "dotenv"
+	FormatJSON   Format = "json"
+	FormatYAML   Format = "yaml"
+	FormatTOML   Format = "toml"
+)
+
+// Formats lists all supported input formats
+var Formats = []Format{
+	FormatDotenv,
+	FormatJSON,
+	FormatYAML,
+	FormatTOML,
+}
+
+// DefaultSeparator joins the keys of nested values
+const DefaultSeparator = "_"
+
+// Options configures how sources are parsed
+type Options struct {
+	// Separator joins the keys of nested values, e.g. database.host becomes DATABASE_HOST
+	Separator string
+	// Expand, Lookup and Resolve behave like the dotenv options of the same name.
+	// For structured formats all string values are resolved and expanded after flattening.
+	Expand  bool
+	Lookup  dotenv.LookupFunc
+	Resolve func(value string) (string, error)
+}
+
+// FormatsToStrings returns the names of all supported formats
+func FormatsToStrings() []string {
+	strings := []string{}
+	for _, f := range Formats {
+		strings = append(strings, string(f))
+	}
+	return strings
+}
+
+// ValidateFormat checks whether the given format is supported
+func ValidateFormat(format Format) error {
+	if !slices.Contains(Formats, format) {
+		return fmt.Errorf("%s is not a valid format (%s)", format, strings.Join(FormatsToStrings(), ", "))
+	}
+	return nil
+}
+
+// DetectFormat derives the format from the file extension.
+// Files without a known extension, like .env.production, are read as dotenv.
+func DetectFormat(filePath string) Format {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatDotenv
+	}
+}
+
+// ParseFile reads and parses the file at the given path.
+// If format is empty it is detected from the file extension.
+func ParseFile(filePath string, format Format, opts Options) (map[string]string, error) {
+	if format == "" {
+		format = DetectFormat(filePath)
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	vars, err := Parse(content, format, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s file %s: %w", format, filePath, err)
+	}
+	return vars, nil
+}
+
+// ParseReader reads and parses content in the given format from r, e.g. standard input
+func ParseReader(r io.Reader, format Format, opts Options) (map[string]string, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	vars, err := Parse(content, format, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s input: %w", format, err)
+	}
+	return vars, nil
+}
+
+// ParseEnviron converts a list of KEY=value entries, as returned by os.Environ, into a map of variables
+func ParseEnviron(environ []string) map[string]string {
+	vars := make(map[string]string, len(environ))
+	for _, entry := range environ {
+		key, value, found := strings.Cut(entry, "=")
+		// Skip malformed entries and the per-drive working directories Windows stores as =C:=C:\
+		if !found || key == "" {
+			continue
+		}
+		vars[key] = value
+	}
+	return vars
+}
+
+// Parse parses content in the given format into a map of variables.
+// Nested objects and arrays of structured formats are flattened into upper-case keys
+// joined with the separator, e.g. {"database": {"hosts": ["a"]}} becomes DATABASE_HOSTS_0=a.
+func Parse(content []byte, format Format, opts Options) (map[string]string, error) {
+	if err := ValidateFormat(format); err != nil {
+		return nil, err
+	}
+	if format == FormatDotenv {
+		return dotenv.Parse(string(content), dotenv.Options{
+			Expand:  opts.Expand,
+			Lookup:  opts.Lookup,
+			Resolve: opts.Resolve,
+		})
+	}
+
+	if opts.Separator == "" {
+		opts.Separator = DefaultSeparator
+	}
+
+	f := &flattener{separator: opts.Separator, vars: map[string]string{}}
+	var err error
+	switch format {
+	case FormatJSON:
+		err = f.flattenJSON(content)
+	case FormatYAML:
+		err = f.flattenYAML(content)
+	case FormatTOML:
+		err = f.flattenTOML(content)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.Expand {
+		return f.vars, nil
+	}
+	if opts.Resolve == nil {
+		return dotenv.ExpandAll(f.vars, opts.Lookup)
+	}
+	return dotenv.ExpandAndResolveAll(f.vars, opts.Lookup, opts.Resolve)
+}
+
+type flattener struct {
+	separator string
+	vars      map[string]string
+}
+
+func (f *flattener) flattenJSON(content []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	// Keep numbers as written instead of converting them to float64
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	if _, ok := value.(map[string]any); !ok {
+		return fmt.Errorf("expected a JSON object at the top level")
+	}
+	return f.flatten(nil, value)
+}
+
+func (f *flattener) flattenTOML(content []byte) error {
+	var value map[string]any
+	if err := toml.Unmarshal(content, &value); err != nil {
+		return err
+	}
+	return f.flatten(nil, value)
+}
+
+func (f *flattener) flattenYAML(content []byte) error {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return err
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a YAML mapping at the top level", root.Line)
+	}
+	return f.flattenNode(nil, root)
+}
+
+// flattenNode flattens a YAML node, keeping scalars as written in the document
+func (f *flattener) flattenNode(path []string, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		entries, err := mappingEntries(node)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := f.flattenNode(append(path, entry.key), entry.value); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			if err := f.flattenNode(append(path, strconv.Itoa(i)), item); err != nil {
+				return err
+			}
+		}
+	case yaml.AliasNode:
+		return f.flattenNode(path, node.Alias)
+	case yaml.ScalarNode:
+		value := node.Value
+		if node.Tag == "!!null" {
+			value = ""
+		}
+		return f.set(path, value)
+	}
+	return nil
+}
+
+type mappingEntry struct {
+	key   string
+	value *yaml.Node
+}
+
+// mappingEntries returns the entries of a YAML mapping with merge keys like <<: *defaults resolved.
+// Keys of the mapping take precedence over merged keys, and earlier merged mappings over later ones.
+func mappingEntries(node *yaml.Node) ([]mappingEntry, error) {
+	entries := []mappingEntry{}
+	merges := []*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch {
+		case key.Kind != yaml.ScalarNode:
+			return nil, fmt.Errorf("line %d: unsupported mapping key", key.Line)
+		case key.Tag == "!!merge":
+			merges = append(merges, value)
+		default:
+			entries = append(entries, mappingEntry{key: key.Value, value: value})
+		}
+	}
+	if len(merges) == 0 {
+		return entries, nil
+	}
+
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		seen[entry.key] = true
+	}
+	for _, merge := range merges {
+		merged := []*yaml.Node{merge}
+		if resolveAlias(merge).Kind == yaml.SequenceNode {
+			merged = resolveAlias(merge).Content
+		}
+		for _, m := range merged {
+			m = resolveAlias(m)
+			if m.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("line %d: merge key value must be a mapping or a sequence of mappings", m.Line)
+			}
+			mergedEntries, err := mappingEntries(m)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range mergedEntries {
+				if !seen[entry.key] {
+					seen[entry.key] = true
+					entries = append(entries, entry)
+				}
+			}
+		}
+	}
+	return entries, nil
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// flatten flattens a decoded JSON or TOML value
+func (f *flattener) flatten(path []string, value any) error {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if err := f.flatten(append(path, key), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		for i, item := range v {
+			if err := f.flatten(append(path, strconv.Itoa(i)), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case nil:
+		return f.set(path, "")
+	case string:
+		return f.set(path, v)
+	case float64:
+		return f.set(path, strconv.FormatFloat(v, 'f', -1, 64))
+	case time.Time:
+		return f.set(path, v.Format(time.RFC3339Nano))
+	default:
+		// json.Number, int64, bool and the local TOML date and time types
+		return f.set(path, fmt.Sprint(v))
+	}
+}
+
+func (f *flattener) set(path []string, value string) error {
+	key := strings.ToUpper(strings.Join(path, f.separator))
+	if !dotenv.IsValidKey(key) {
+		return fmt.Errorf("%q is not a valid variable name", key)
+	}
+	if _, exists := f.vars[key]; exists {
+		return fmt.Errorf("duplicate variable %s after flattening", key)
+	}
+	f.vars[key] = value
+	return nil
+}
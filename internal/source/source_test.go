@@ -0,0 +1,178 @@
+package source
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParseYAMLMergeKeys(t *testing.T) {
+	content := `
+defaults: &defaults
+  host: localhost
+  port: 5432
+logging: &logging
+  level: info
+  port: 9000
+database:
+  <<: *defaults
+  name: app
+replica:
+  <<: [*defaults, *logging]
+  host: replica.internal
+nested:
+  <<:
+    <<: *defaults
+    name: inline
+`
+	got, err := Parse([]byte(content), FormatYAML, Options{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := map[string]string{
+		"DEFAULTS_HOST": "localhost",
+		"DEFAULTS_PORT": "5432",
+		"LOGGING_LEVEL": "info",
+		"LOGGING_PORT":  "9000",
+		"DATABASE_HOST": "localhost",
+		"DATABASE_PORT": "5432",
+		"DATABASE_NAME": "app",
+		"REPLICA_HOST":  "replica.internal",
+		"REPLICA_PORT":  "5432",
+		"REPLICA_LEVEL": "info",
+		"NESTED_HOST":   "localhost",
+		"NESTED_PORT":   "5432",
+		"NESTED_NAME":   "inline",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+}
+
+func TestParseYAMLInvalidMergeKey(t *testing.T) {
+	_, err := Parse([]byte("app:\n  <<: value\n"), FormatYAML, Options{})
+	if err == nil || !strings.Contains(err.Error(), "merge key") {
+		t.Errorf("Parse() error = %v, want merge key error", err)
+	}
+}
+
+func TestParseNested(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		content string
+	}{
+		{
+			name:   "json",
+			format: FormatJSON,
+			content: `{
+  "database": {"host": "localhost", "port": 5432, "ssl": true, "password": null},
+  "hosts": ["a", "b"],
+  "servers": [{"name": "primary"}],
+  "ratio": 0.25
+}`,
+		},
+		{
+			name:   "toml",
+			format: FormatTOML,
+			content: `hosts = ["a", "b"]
+ratio = 0.25
+
+[database]
+host = "localhost"
+port = 5432
+ssl = true
+password = ""
+
+[[servers]]
+name = "primary"
+`,
+		},
+		{
+			name:   "yaml",
+			format: FormatYAML,
+			content: `database:
+  host: localhost
+  port: 5432
+  ssl: true
+  password: null
+hosts: [a, b]
+servers:
+  - name: primary
+ratio: 0.25
+`,
+		},
+	}
+	want := map[string]string{
+		"DATABASE_HOST":     "localhost",
+		"DATABASE_PORT":     "5432",
+		"DATABASE_SSL":      "true",
+		"DATABASE_PASSWORD": "",
+		"HOSTS_0":           "a",
+		"HOSTS_1":           "b",
+		"SERVERS_0_NAME":    "primary",
+		"RATIO":             "0.25",
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.content), tt.format, Options{})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !maps.Equal(got, want) {
+				t.Errorf("Parse() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseSeparator(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatTOML} {
+		content := `{"database": {"hosts": ["a"]}}`
+		if format == FormatTOML {
+			content = "[database]\nhosts = [\"a\"]\n"
+		}
+		got, err := Parse([]byte(content), format, Options{Separator: "__"})
+		if err != nil {
+			t.Fatalf("Parse(%s) error = %v", format, err)
+		}
+		if want := map[string]string{"DATABASE__HOSTS__0": "a"}; !maps.Equal(got, want) {
+			t.Errorf("Parse(%s) = %v, want %v", format, got, want)
+		}
+	}
+}
+
+func TestParseDuplicateAfterFlattening(t *testing.T) {
+	tests := []struct {
+		format  Format
+		content string
+	}{
+		{format: FormatJSON, content: `{"database": {"host": "a"}, "DATABASE_HOST": "b"}`},
+		{format: FormatJSON, content: `{"db": {"Host": "a", "host": "b"}}`},
+		{format: FormatTOML, content: "database_host = \"b\"\n[database]\nhost = \"a\"\n"},
+		{format: FormatYAML, content: "database:\n  host: a\ndatabase_host: b\n"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.content), tt.format, Options{})
+		if err == nil || !strings.Contains(err.Error(), "duplicate variable") {
+			t.Errorf("Parse(%s, %q) error = %v, want duplicate variable error", tt.format, tt.content, err)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		format  Format
+		content string
+	}{
+		{format: FormatJSON, content: `["not", "an", "object"]`},
+		{format: FormatJSON, content: `{"invalid key": "value"}`},
+		{format: FormatYAML, content: "- not a mapping\n"},
+		{format: FormatTOML, content: "key = \n"},
+	}
+	for _, tt := range tests {
+		if got, err := Parse([]byte(tt.content), tt.format, Options{}); err == nil {
+			t.Errorf("Parse(%s, %q) = %v, want an error", tt.format, tt.content, got)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a SecretStore that keeps environments in memory.
+// It is meant for tests that must not reach a real backend.
+type MemoryStore struct {
+	mu           sync.Mutex
+	environments map[string]Environment
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{environments: map[string]Environment{}}
+}
+
+// GetEnvironment retrieves a copy of the environment stored for a URL and environment name
+func (s *MemoryStore) GetEnvironment(opts GetEnvironmentOptions) (*Environment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, environment := range s.environments {
+		if environment.URL == opts.URL && environment.Env == opts.Env {
+			return copyEnvironment(environment, true), nil
+		}
+	}
+	return nil, NotFoundError(opts.URL, opts.Env)
+}
+
+// Put creates or replaces the environment with the same name and environment
+func (s *MemoryStore) Put(opts PutOptions) (*Environment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := memoryID(opts.Name, opts.Env)
+	previous := s.environments[id]
+	environment := Environment{
+		ID:           id,
+		Name:         opts.Name,
+		URL:          opts.URL,
+		Env:          opts.Env,
+		SyncProfiles: slices.Clone(opts.SyncProfiles),
+		Variables:    maps.Clone(opts.Variables),
+		Plain:        slices.Clone(opts.Plain),
+		Expanded:     opts.Expanded,
+		Owned:        previous.Owned,
+	}
+	if environment.Variables == nil {
+		environment.Variables = map[string]string{}
+	}
+	s.environments[id] = environment
+	return copyEnvironment(environment, true), nil
+}
+
+// FindExistingItem looks for an environment with the same name and environment
+func (s *MemoryStore) FindExistingItem(name, env string) (*Environment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	environment, ok := s.environments[memoryID(name, env)]
+	if !ok {
+		return nil, nil
+	}
+	return copyEnvironment(environment, true), nil
+}
+
+// ListEnvironments returns the metadata of all stored environments sorted by name and environment
+func (s *MemoryStore) ListEnvironments() ([]Environment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	environments := make([]Environment, 0, len(s.environments))
+	for _, environment := range s.environments {
+		environments = append(environments, *copyEnvironment(environment, false))
+	}
+	sort.Slice(environments, func(i, j int) bool {
+		return environments[i].ID < environments[j].ID
+	})
+	return environments, nil
+}
+
+// Delete removes the environment stored for a URL and environment name
+func (s *MemoryStore) Delete(opts GetEnvironmentOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, environment := range s.environments {
+		if environment.URL == opts.URL && environment.Env == opts.Env {
+			delete(s.environments, id)
+			return nil
+		}
+	}
+	return NotFoundError(opts.URL, opts.Env)
+}
+
+// SetOwned records the keys synced to a target
+func (s *MemoryStore) SetOwned(opts GetEnvironmentOptions, target string, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, environment := range s.environments {
+		if environment.URL == opts.URL && environment.Env == opts.Env {
+			environment.Owned = CloneOwned(environment.Owned)
+			if environment.Owned == nil {
+				environment.Owned = map[string][]string{}
+			}
+			environment.Owned[target] = OwnedKeys(keys)
+			s.environments[id] = environment
+			return nil
+		}
+	}
+	return NotFoundError(opts.URL, opts.Env)
+}
+
+func memoryID(name, env string) string {
+	return name + "/env:" + env
+}
+
+func copyEnvironment(environment Environment, withVariables bool) *Environment {
+	environment.SyncProfiles = slices.Clone(environment.SyncProfiles)
+	if withVariables {
+		environment.Variables = maps.Clone(environment.Variables)
+		environment.Plain = slices.Clone(environment.Plain)
+		environment.Owned = CloneOwned(environment.Owned)
+	} else {
+		environment.Variables = nil
+		environment.Plain = nil
+		environment.Owned = nil
+	}
+	return &environment
+}
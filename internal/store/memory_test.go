@@ -0,0 +1,14 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/hinterland-software/openv/internal/store"
+	"github.com/hinterland-software/openv/internal/store/storetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.SecretStore {
+		return store.NewMemoryStore()
+	})
+}
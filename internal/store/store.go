@@ -0,0 +1,131 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Backend identifies a secret store implementation
+type Backend string
+
+const (
+	Backend1Password Backend = "1password"
+	BackendFile      Backend = "file"
+	BackendVault     Backend = "vault"
+)
+
+// Backends lists all selectable secret store backends
+var Backends = []Backend{
+	Backend1Password,
+	BackendFile,
+	BackendVault,
+}
+
+// ErrNotFound is returned when no environment matches the requested URL and environment
+var ErrNotFound = errors.New("no environment variables found")
+
+// Environment contains environment variables and metadata
+type Environment struct {
+	ID           string
+	Name         string
+	URL          string
+	Env          string
+	SyncProfiles []string
+	Variables    map[string]string
+	// Plain lists the variables marked as not sensitive, all others are treated as secrets
+	Plain []string
+	// Expanded is set if references were expanded and resolved on import, so the values are final
+	Expanded bool
+	// Owned maps sync targets that cannot store an OPENV_KEYS_* bookkeeping variable themselves,
+	// like write-only secret stores, to the sorted keys openv synced to them
+	Owned map[string][]string
+}
+
+// PutOptions represents the options for storing environment variables
+type PutOptions struct {
+	Name         string
+	Env          string
+	URL          string
+	SyncProfiles []string
+	Variables    map[string]string
+	Plain        []string
+	Expanded     bool
+}
+
+// IsSensitive reports whether the variable is a secret, which is the default unless marked as plain
+func (e *Environment) IsSensitive(key string) bool {
+	return !slices.Contains(e.Plain, key)
+}
+
+// GetEnvironmentOptions represents the options for getting environment variables
+type GetEnvironmentOptions struct {
+	URL string
+	Env string
+}
+
+// SecretStore is implemented by every backend that can persist environments
+type SecretStore interface {
+	// GetEnvironment retrieves the environment stored for a URL and environment name.
+	// Returns an error wrapping ErrNotFound if no matching environment exists.
+	GetEnvironment(opts GetEnvironmentOptions) (*Environment, error)
+	// Put creates the environment or replaces an existing one with the same name and environment.
+	// The owned keys of a replaced environment are kept.
+	Put(opts PutOptions) (*Environment, error)
+	// FindExistingItem looks for an environment with the same name and environment.
+	// Returns nil if there is none.
+	FindExistingItem(name, env string) (*Environment, error)
+	// ListEnvironments returns the metadata of all stored environments, without their variables
+	ListEnvironments() ([]Environment, error)
+	// Delete removes the environment stored for a URL and environment name
+	Delete(opts GetEnvironmentOptions) error
+	// SetOwned records the keys synced to a target in Environment.Owned, replacing the previous keys.
+	// Returns an error wrapping ErrNotFound if no matching environment exists.
+	SetOwned(opts GetEnvironmentOptions, target string, keys []string) error
+}
+
+// Resolver is implemented by stores that can resolve secret references like op://vault/item/field
+type Resolver interface {
+	ResolveReference(reference string) (string, error)
+}
+
+// BackendsToStrings returns the names of all selectable backends
+func BackendsToStrings() []string {
+	strings := []string{}
+	for _, b := range Backends {
+		strings = append(strings, string(b))
+	}
+	return strings
+}
+
+// ValidateBackend checks whether the given backend is known
+func ValidateBackend(backend Backend) error {
+	if slices.Contains(Backends, backend) {
+		return nil
+	}
+	return fmt.Errorf("%s is not a valid secret store backend", backend)
+}
+
+// OwnedKeys returns a sorted copy of keys as stored in Environment.Owned
+func OwnedKeys(keys []string) []string {
+	owned := slices.Clone(keys)
+	slices.Sort(owned)
+	return owned
+}
+
+// CloneOwned returns a deep copy of the owned keys of an environment
+func CloneOwned(owned map[string][]string) map[string][]string {
+	if owned == nil {
+		return nil
+	}
+	cloned := make(map[string][]string, len(owned))
+	for target, keys := range owned {
+		cloned[target] = slices.Clone(keys)
+	}
+	return cloned
+}
+
+// NotFoundError returns an error wrapping ErrNotFound for the given URL and environment
+func NotFoundError(url, env string) error {
+	return fmt.Errorf("%w for %s (%s)", ErrNotFound, url, env)
+}
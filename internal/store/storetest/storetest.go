@@ -0,0 +1,229 @@
+// Package storetest provides the contract tests every SecretStore backend must pass
+package storetest
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal/store"
+)
+
+// Run runs the SecretStore contract tests, newStore must return an empty store for every call
+func Run(t *testing.T, newStore func(t *testing.T) store.SecretStore) {
+	t.Run("GetMissing", func(t *testing.T) {
+		s := newStore(t)
+		_, err := s.GetEnvironment(store.GetEnvironmentOptions{URL: "github.com/org/app", Env: "production"})
+		if !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetEnvironment() error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("PutGet", func(t *testing.T) {
+		s := newStore(t)
+		put := store.PutOptions{
+			Name:         "app",
+			Env:          "production",
+			URL:          "github.com/org/app",
+			SyncProfiles: []string{"gh"},
+			Variables:    map[string]string{"API_KEY": "secret", "LOG_LEVEL": "info", "EMPTY": ""},
+			Plain:        []string{"LOG_LEVEL"},
+			Expanded:     true,
+		}
+		if _, err := s.Put(put); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		got, err := s.GetEnvironment(store.GetEnvironmentOptions{URL: put.URL, Env: put.Env})
+		if err != nil {
+			t.Fatalf("GetEnvironment() error = %v", err)
+		}
+		assertEnvironment(t, got, put)
+		if got.IsSensitive("LOG_LEVEL") || !got.IsSensitive("API_KEY") {
+			t.Errorf("IsSensitive() does not match Plain %v", got.Plain)
+		}
+	})
+
+	t.Run("PutReplacesSameNameAndEnv", func(t *testing.T) {
+		s := newStore(t)
+		first := store.PutOptions{Name: "app", Env: "staging", URL: "github.com/org/app", Variables: map[string]string{"OLD": "1"}}
+		second := store.PutOptions{Name: "app", Env: "staging", URL: "github.com/org/renamed", Variables: map[string]string{"NEW": "2"}}
+		other := store.PutOptions{Name: "app", Env: "production", URL: "github.com/org/app", Variables: map[string]string{"PROD": "3"}}
+		for _, opts := range []store.PutOptions{first, other, second} {
+			if _, err := s.Put(opts); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+		}
+
+		if _, err := s.GetEnvironment(store.GetEnvironmentOptions{URL: first.URL, Env: first.Env}); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetEnvironment() of replaced environment error = %v, want ErrNotFound", err)
+		}
+		got, err := s.GetEnvironment(store.GetEnvironmentOptions{URL: second.URL, Env: second.Env})
+		if err != nil {
+			t.Fatalf("GetEnvironment() error = %v", err)
+		}
+		assertEnvironment(t, got, second)
+		got, err = s.GetEnvironment(store.GetEnvironmentOptions{URL: other.URL, Env: other.Env})
+		if err != nil {
+			t.Fatalf("GetEnvironment() error = %v", err)
+		}
+		assertEnvironment(t, got, other)
+	})
+
+	t.Run("FindExistingItem", func(t *testing.T) {
+		s := newStore(t)
+		existing, err := s.FindExistingItem("app", "production")
+		if err != nil || existing != nil {
+			t.Fatalf("FindExistingItem() = %v, %v, want nil, nil", existing, err)
+		}
+		put := store.PutOptions{Name: "app", Env: "production", URL: "github.com/org/app", Variables: map[string]string{"A": "1"}}
+		if _, err := s.Put(put); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		existing, err = s.FindExistingItem("app", "production")
+		if err != nil || existing == nil {
+			t.Fatalf("FindExistingItem() = %v, %v, want environment", existing, err)
+		}
+		if existing.URL != put.URL {
+			t.Errorf("FindExistingItem().URL = %s, want %s", existing.URL, put.URL)
+		}
+	})
+
+	t.Run("ListEnvironments", func(t *testing.T) {
+		s := newStore(t)
+		for _, env := range []string{"production", "staging"} {
+			if _, err := s.Put(store.PutOptions{Name: "app", Env: env, URL: "github.com/org/app", Variables: map[string]string{"A": env}}); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+		}
+		environments, err := s.ListEnvironments()
+		if err != nil {
+			t.Fatalf("ListEnvironments() error = %v", err)
+		}
+		envs := []string{}
+		for _, e := range environments {
+			envs = append(envs, e.Env)
+			if len(e.Variables) > 0 {
+				t.Errorf("ListEnvironments() returned variables of %s", e.Env)
+			}
+		}
+		slices.Sort(envs)
+		if !slices.Equal(envs, []string{"production", "staging"}) {
+			t.Errorf("ListEnvironments() envs = %v", envs)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s := newStore(t)
+		opts := store.GetEnvironmentOptions{URL: "github.com/org/app", Env: "production"}
+		if err := s.Delete(opts); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("Delete() of missing environment error = %v, want ErrNotFound", err)
+		}
+		if _, err := s.Put(store.PutOptions{Name: "app", Env: opts.Env, URL: opts.URL, Variables: map[string]string{"A": "1"}}); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		if err := s.Delete(opts); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if _, err := s.GetEnvironment(opts); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetEnvironment() after Delete() error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("SetOwned", func(t *testing.T) {
+		s := newStore(t)
+		opts := store.GetEnvironmentOptions{URL: "github.com/org/app", Env: "production"}
+		if err := s.SetOwned(opts, "deno:app", []string{"A"}); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("SetOwned() of missing environment error = %v, want ErrNotFound", err)
+		}
+		put := store.PutOptions{Name: "app", Env: opts.Env, URL: opts.URL, Variables: map[string]string{"A": "1", "B": "2"}}
+		if _, err := s.Put(put); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		if err := s.SetOwned(opts, "deno:app", []string{"B", "A"}); err != nil {
+			t.Fatalf("SetOwned() error = %v", err)
+		}
+		if err := s.SetOwned(opts, "fly-app-secret:app", []string{"A"}); err != nil {
+			t.Fatalf("SetOwned() error = %v", err)
+		}
+		if err := s.SetOwned(opts, "deno:app", []string{"B"}); err != nil {
+			t.Fatalf("SetOwned() error = %v", err)
+		}
+
+		got, err := s.GetEnvironment(opts)
+		if err != nil {
+			t.Fatalf("GetEnvironment() error = %v", err)
+		}
+		assertEnvironment(t, got, put)
+		assertOwned(t, got.Owned, map[string][]string{"deno:app": {"B"}, "fly-app-secret:app": {"A"}})
+
+		// Re-importing the environment keeps the keys synced to targets, they still exist there
+		put.Variables = map[string]string{"C": "3"}
+		if _, err := s.Put(put); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		got, err = s.GetEnvironment(opts)
+		if err != nil {
+			t.Fatalf("GetEnvironment() error = %v", err)
+		}
+		assertEnvironment(t, got, put)
+		assertOwned(t, got.Owned, map[string][]string{"deno:app": {"B"}, "fly-app-secret:app": {"A"}})
+	})
+
+	t.Run("ReturnsCopies", func(t *testing.T) {
+		s := newStore(t)
+		opts := store.GetEnvironmentOptions{URL: "github.com/org/app", Env: "production"}
+		put, err := s.Put(store.PutOptions{Name: "app", Env: opts.Env, URL: opts.URL, Variables: map[string]string{"A": "1"}})
+		if err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		put.Variables["A"] = "changed"
+		got, err := s.GetEnvironment(opts)
+		if err != nil {
+			t.Fatalf("GetEnvironment() error = %v", err)
+		}
+		got.Variables["B"] = "added"
+		got, err = s.GetEnvironment(opts)
+		if err != nil {
+			t.Fatalf("GetEnvironment() error = %v", err)
+		}
+		if !maps.Equal(got.Variables, map[string]string{"A": "1"}) {
+			t.Errorf("GetEnvironment().Variables = %v, changes to returned environments leaked into the store", got.Variables)
+		}
+	})
+}
+
+func assertEnvironment(t *testing.T, got *store.Environment, want store.PutOptions) {
+	t.Helper()
+	if got.Name != want.Name || got.URL != want.URL || got.Env != want.Env {
+		t.Errorf("environment = %s %s (%s), want %s %s (%s)", got.Name, got.URL, got.Env, want.Name, want.URL, want.Env)
+	}
+	if got.ID == "" {
+		t.Error("environment has no ID")
+	}
+	if !maps.Equal(got.Variables, want.Variables) {
+		t.Errorf("Variables = %v, want %v", got.Variables, want.Variables)
+	}
+	if !slices.Equal(sorted(got.Plain), sorted(want.Plain)) {
+		t.Errorf("Plain = %v, want %v", got.Plain, want.Plain)
+	}
+	if got.Expanded != want.Expanded {
+		t.Errorf("Expanded = %t, want %t", got.Expanded, want.Expanded)
+	}
+	if !slices.Equal(got.SyncProfiles, want.SyncProfiles) && len(got.SyncProfiles)+len(want.SyncProfiles) > 0 {
+		t.Errorf("SyncProfiles = %v, want %v", got.SyncProfiles, want.SyncProfiles)
+	}
+}
+
+func assertOwned(t *testing.T, got, want map[string][]string) {
+	t.Helper()
+	if !maps.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("Owned = %v, want %v", got, want)
+	}
+}
+
+func sorted(values []string) []string {
+	values = slices.Clone(values)
+	slices.Sort(values)
+	return values
+}
@@ -0,0 +1,380 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/rest"
+	"github.com/hinterland-software/openv/internal/store"
+)
+
+const (
+	// DefaultMount is the default KV v2 secrets engine mount
+	DefaultMount = "secret"
+	// DefaultPrefix is the default path below the mount all environments are stored in
+	DefaultPrefix = "openv"
+	// ownedDataKey holds the JSON encoded owned keys in the secret data.
+	// Custom metadata values are limited to 512 bytes, and the dot keeps it apart from variable names.
+	ownedDataKey = "openv.owned"
+	// plainDataKey holds the JSON encoded plain keys in the secret data, for the same reason
+	plainDataKey = "openv.plain"
+)
+
+// Options configures the connection to a Vault server
+type Options struct {
+	Address   string
+	Token     string
+	Namespace string
+	Mount     string
+	Prefix    string
+}
+
+// Store is a SecretStore that keeps each URL/env pair as a KV v2 secret.
+// The variables, plain and owned keys are stored as secret data, the metadata as custom metadata.
+type Store struct {
+	client *rest.Client
+	mount  string
+	prefix string
+}
+
+var _ store.SecretStore = (*Store)(nil)
+
+type kvData struct {
+	Data struct {
+		Data     map[string]string `json:"data"`
+		Metadata struct {
+			CustomMetadata map[string]string `json:"custom_metadata"`
+		} `json:"metadata"`
+	} `json:"data"`
+}
+
+type kvMetadata struct {
+	Data struct {
+		CustomMetadata map[string]string `json:"custom_metadata"`
+	} `json:"data"`
+}
+
+type kvList struct {
+	Data struct {
+		Keys []string `json:"keys"`
+	} `json:"data"`
+}
+
+// NewStore creates a new Vault backed secret store
+func NewStore(ctx context.Context, opts Options) (*Store, error) {
+	if opts.Address == "" {
+		return nil, fmt.Errorf("vault address is required")
+	}
+	if opts.Token == "" {
+		return nil, fmt.Errorf("vault token is required")
+	}
+	if opts.Mount == "" {
+		opts.Mount = DefaultMount
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultPrefix
+	}
+
+	headers := map[string]string{"X-Vault-Token": opts.Token}
+	if opts.Namespace != "" {
+		headers["X-Vault-Namespace"] = opts.Namespace
+	}
+
+	return &Store{
+		client: rest.NewClient(ctx, opts.Address, headers),
+		mount:  strings.Trim(opts.Mount, "/"),
+		prefix: strings.Trim(opts.Prefix, "/"),
+	}, nil
+}
+
+// GetEnvironment reads the secret stored for a URL and environment name
+func (s *Store) GetEnvironment(opts store.GetEnvironmentOptions) (*store.Environment, error) {
+	secretPath := s.secretPath(opts.URL, opts.Env)
+
+	var data kvData
+	err := s.client.Do("GET", s.apiPath("data", secretPath), nil, &data)
+	if rest.IsNotFound(err) {
+		return nil, store.NotFoundError(opts.URL, opts.Env)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read secret %s: %w", secretPath, err)
+	}
+
+	return dataToEnvironment(secretPath, data), nil
+}
+
+// Put writes a new version of the secret and replaces its custom metadata.
+// The metadata is written first, so a secret is never stored without the metadata
+// ListEnvironments and FindExistingItem rely on.
+func (s *Store) Put(opts store.PutOptions) (*store.Environment, error) {
+	existing, err := s.FindExistingItem(opts.Name, opts.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	secretPath := s.secretPath(opts.URL, opts.Env)
+	previous, err := s.readMetadata(secretPath)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]string{
+		"name": opts.Name,
+		"url":  opts.URL,
+		"env":  opts.Env,
+	}
+	if len(opts.SyncProfiles) > 0 {
+		metadata["sync_profiles"] = strings.Join(opts.SyncProfiles, ",")
+	}
+	if opts.Expanded {
+		metadata["expanded"] = "true"
+	}
+	if err := s.writeMetadata(secretPath, metadata); err != nil {
+		return nil, err
+	}
+
+	var owned map[string][]string
+	if existing != nil {
+		owned = existing.Owned
+	}
+	variables := opts.Variables
+	if variables == nil {
+		variables = map[string]string{}
+	}
+	if err := s.writeData(secretPath, variables, opts.Plain, owned); err != nil {
+		s.rollbackMetadata(secretPath, previous)
+		return nil, err
+	}
+
+	if existing != nil && existing.ID != secretPath {
+		// The URL changed, remove the secret stored at the old location
+		logging.Logger.Debug("removing secret stored under previous URL", "path", existing.ID)
+		if err := s.deletePath(existing.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	environment := metadataToEnvironment(secretPath, metadata)
+	environment.Variables = maps.Clone(variables)
+	environment.Plain = slices.Clone(opts.Plain)
+	environment.Owned = store.CloneOwned(owned)
+	return environment, nil
+}
+
+// FindExistingItem looks for a secret with the same name and environment.
+// The environment is the last segment of the secret path, so only secrets of that environment are read.
+func (s *Store) FindExistingItem(name, env string) (*store.Environment, error) {
+	secretPaths, err := s.listRecursive(s.prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(secretPaths)
+
+	for _, secretPath := range secretPaths {
+		if path.Base(secretPath) != env {
+			continue
+		}
+		var data kvData
+		err := s.client.Do("GET", s.apiPath("data", secretPath), nil, &data)
+		if rest.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", secretPath, err)
+		}
+		metadata := data.Data.Metadata.CustomMetadata
+		if metadata["name"] == name && metadata["env"] == env {
+			return dataToEnvironment(secretPath, data), nil
+		}
+	}
+	return nil, nil
+}
+
+// ListEnvironments walks all secrets below the prefix and returns their metadata
+func (s *Store) ListEnvironments() ([]store.Environment, error) {
+	secretPaths, err := s.listRecursive(s.prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(secretPaths)
+
+	environments := []store.Environment{}
+	for _, secretPath := range secretPaths {
+		var metadata kvMetadata
+		if err := s.client.Do("GET", s.apiPath("metadata", secretPath), nil, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to read metadata of secret %s: %w", secretPath, err)
+		}
+
+		environment := metadataToEnvironment(secretPath, metadata.Data.CustomMetadata)
+		if environment.URL == "" || environment.Env == "" {
+			continue
+		}
+		environments = append(environments, *environment)
+	}
+	return environments, nil
+}
+
+// Delete removes all versions and the metadata of the secret
+func (s *Store) Delete(opts store.GetEnvironmentOptions) error {
+	if _, err := s.GetEnvironment(opts); err != nil {
+		return err
+	}
+	return s.deletePath(s.secretPath(opts.URL, opts.Env))
+}
+
+// SetOwned writes a new version of the secret with the keys synced to a target
+func (s *Store) SetOwned(opts store.GetEnvironmentOptions, target string, keys []string) error {
+	environment, err := s.GetEnvironment(opts)
+	if err != nil {
+		return err
+	}
+	if environment.Owned == nil {
+		environment.Owned = map[string][]string{}
+	}
+	environment.Owned[target] = store.OwnedKeys(keys)
+	return s.writeData(environment.ID, environment.Variables, environment.Plain, environment.Owned)
+}
+
+// writeData writes the variables, plain and owned keys as a new version of the secret
+func (s *Store) writeData(secretPath string, variables map[string]string, plain []string, owned map[string][]string) error {
+	data := maps.Clone(variables)
+	if len(plain) > 0 {
+		plainJSON, err := json.Marshal(plain)
+		if err != nil {
+			return fmt.Errorf("failed to marshal plain keys: %w", err)
+		}
+		data[plainDataKey] = string(plainJSON)
+	}
+	if len(owned) > 0 {
+		ownedJSON, err := json.Marshal(owned)
+		if err != nil {
+			return fmt.Errorf("failed to marshal owned keys: %w", err)
+		}
+		data[ownedDataKey] = string(ownedJSON)
+	}
+	if err := s.client.Do("POST", s.apiPath("data", secretPath), map[string]any{"data": data}, nil); err != nil {
+		return fmt.Errorf("failed to write secret %s: %w", secretPath, err)
+	}
+	return nil
+}
+
+// readMetadata returns the custom metadata of a secret, or nil if it does not exist
+func (s *Store) readMetadata(secretPath string) (map[string]string, error) {
+	var metadata kvMetadata
+	err := s.client.Do("GET", s.apiPath("metadata", secretPath), nil, &metadata)
+	if rest.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read metadata of secret %s: %w", secretPath, err)
+	}
+	if metadata.Data.CustomMetadata == nil {
+		return map[string]string{}, nil
+	}
+	return metadata.Data.CustomMetadata, nil
+}
+
+func (s *Store) writeMetadata(secretPath string, metadata map[string]string) error {
+	if err := s.client.Do("POST", s.apiPath("metadata", secretPath), map[string]any{"custom_metadata": metadata}, nil); err != nil {
+		return fmt.Errorf("failed to write metadata of secret %s: %w", secretPath, err)
+	}
+	return nil
+}
+
+// rollbackMetadata restores the metadata of a secret after a failed data write, a new secret is removed
+func (s *Store) rollbackMetadata(secretPath string, previous map[string]string) {
+	var err error
+	if previous == nil {
+		err = s.deletePath(secretPath)
+	} else {
+		err = s.writeMetadata(secretPath, previous)
+	}
+	if err != nil {
+		logging.Logger.Warn("failed to roll back metadata of secret", "path", secretPath, "error", err)
+	}
+}
+
+func (s *Store) deletePath(secretPath string) error {
+	if err := s.client.Do("DELETE", s.apiPath("metadata", secretPath), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete secret %s: %w", secretPath, err)
+	}
+	return nil
+}
+
+func (s *Store) listRecursive(folder string) ([]string, error) {
+	var list kvList
+	err := s.client.Do("LIST", s.apiPath("metadata", folder), nil, &list)
+	if rest.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list secrets in %s: %w", folder, err)
+	}
+
+	secretPaths := []string{}
+	for _, key := range list.Data.Keys {
+		child := folder + "/" + strings.TrimSuffix(key, "/")
+		if !strings.HasSuffix(key, "/") {
+			secretPaths = append(secretPaths, child)
+			continue
+		}
+		nested, err := s.listRecursive(child)
+		if err != nil {
+			return nil, err
+		}
+		secretPaths = append(secretPaths, nested...)
+	}
+	return secretPaths, nil
+}
+
+// secretPath returns the location of a URL/env pair below the mount
+func (s *Store) secretPath(url, env string) string {
+	return strings.Join([]string{s.prefix, strings.Trim(url, "/"), env}, "/")
+}
+
+func (s *Store) apiPath(kind, secretPath string) string {
+	segments := strings.Split(secretPath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return fmt.Sprintf("/v1/%s/%s/%s", s.mount, kind, strings.Join(segments, "/"))
+}
+
+func dataToEnvironment(secretPath string, data kvData) *store.Environment {
+	environment := metadataToEnvironment(secretPath, data.Data.Metadata.CustomMetadata)
+	environment.Variables = data.Data.Data
+	if environment.Variables == nil {
+		environment.Variables = map[string]string{}
+	}
+	if owned, ok := environment.Variables[ownedDataKey]; ok {
+		delete(environment.Variables, ownedDataKey)
+		if err := json.Unmarshal([]byte(owned), &environment.Owned); err != nil {
+			logging.Logger.Warn("failed to unmarshal owned keys of secret", "path", secretPath, "error", err)
+		}
+	}
+	if plain, ok := environment.Variables[plainDataKey]; ok {
+		delete(environment.Variables, plainDataKey)
+		if err := json.Unmarshal([]byte(plain), &environment.Plain); err != nil {
+			logging.Logger.Warn("failed to unmarshal plain keys of secret", "path", secretPath, "error", err)
+		}
+	}
+	return environment
+}
+
+func metadataToEnvironment(secretPath string, metadata map[string]string) *store.Environment {
+	environment := &store.Environment{
+		ID:       secretPath,
+		Name:     metadata["name"],
+		URL:      metadata["url"],
+		Env:      metadata["env"],
+		Expanded: metadata["expanded"] == "true",
+	}
+	if profiles := metadata["sync_profiles"]; profiles != "" {
+		environment.SyncProfiles = strings.Split(profiles, ",")
+	}
+	return environment
+}
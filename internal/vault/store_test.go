@@ -0,0 +1,253 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal/store"
+	"github.com/hinterland-software/openv/internal/store/storetest"
+)
+
+// maxMetadataValue is the size limit Vault enforces on custom metadata values
+const maxMetadataValue = 512
+
+type kvSecret struct {
+	data     map[string]string
+	versions int
+	metadata map[string]string
+}
+
+// fakeKV is a minimal KV v2 secrets engine mounted at secret/
+type fakeKV struct {
+	mu       sync.Mutex
+	secrets  map[string]*kvSecret
+	requests []string
+	// failData makes data writes fail with a server error
+	failData bool
+}
+
+func newFakeKV(t *testing.T) (*fakeKV, *Store) {
+	t.Helper()
+	kv := &fakeKV{secrets: map[string]*kvSecret{}}
+	server := httptest.NewServer(kv)
+	t.Cleanup(server.Close)
+	s, err := NewStore(context.Background(), Options{Address: server.URL, Token: "root"})
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+	return kv, s
+}
+
+func (kv *fakeKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.requests = append(kv.requests, r.Method+" "+r.URL.Path)
+
+	if r.Header.Get("X-Vault-Token") != "root" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	kind, secretPath, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/secret/"), "/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	secret := kv.secrets[secretPath]
+
+	switch {
+	case kind == "data" && r.Method == "GET":
+		if secret == nil || secret.versions == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		response := kvData{}
+		response.Data.Data = secret.data
+		response.Data.Metadata.CustomMetadata = secret.metadata
+		json.NewEncoder(w).Encode(response)
+	case kind == "data" && r.Method == "POST":
+		if kv.failData {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body := struct {
+			Data map[string]string `json:"data"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if secret == nil {
+			secret = &kvSecret{}
+			kv.secrets[secretPath] = secret
+		}
+		secret.data = body.Data
+		secret.versions++
+		w.Write([]byte(`{"data":{}}`))
+	case kind == "metadata" && r.Method == "GET":
+		if secret == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		response := kvMetadata{}
+		response.Data.CustomMetadata = secret.metadata
+		json.NewEncoder(w).Encode(response)
+	case kind == "metadata" && r.Method == "POST":
+		body := struct {
+			CustomMetadata map[string]string `json:"custom_metadata"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, value := range body.CustomMetadata {
+			if len(value) > maxMetadataValue {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		if secret == nil {
+			secret = &kvSecret{}
+			kv.secrets[secretPath] = secret
+		}
+		secret.metadata = body.CustomMetadata
+		w.WriteHeader(http.StatusNoContent)
+	case kind == "metadata" && r.Method == "DELETE":
+		delete(kv.secrets, secretPath)
+		w.WriteHeader(http.StatusNoContent)
+	case kind == "metadata" && r.Method == "LIST":
+		keys := []string{}
+		for existing := range kv.secrets {
+			rest, found := strings.CutPrefix(existing, secretPath+"/")
+			if !found {
+				continue
+			}
+			key := rest
+			if child, _, nested := strings.Cut(rest, "/"); nested {
+				key = child + "/"
+			}
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		response := kvList{}
+		response.Data.Keys = keys
+		json.NewEncoder(w).Encode(response)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.SecretStore {
+		_, s := newFakeKV(t)
+		return s
+	})
+}
+
+func TestPutFailedDataWrite(t *testing.T) {
+	kv, s := newFakeKV(t)
+	kv.failData = true
+	if _, err := s.Put(store.PutOptions{Name: "app", Env: "production", URL: "github.com/org/app", Variables: map[string]string{"A": "1"}}); err == nil {
+		t.Fatal("Put() succeeded with a failing data write")
+	}
+	if len(kv.secrets) != 0 {
+		t.Errorf("failed Put() left secrets %v", kv.secrets)
+	}
+
+	// A failed update keeps the previous metadata of an existing secret
+	kv.failData = false
+	if _, err := s.Put(store.PutOptions{Name: "app", Env: "production", URL: "github.com/org/app", Variables: map[string]string{"A": "1"}, Plain: []string{"A"}}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	kv.failData = true
+	if _, err := s.Put(store.PutOptions{Name: "app", Env: "production", URL: "github.com/org/app", Variables: map[string]string{"B": "2"}}); err == nil {
+		t.Fatal("Put() succeeded with a failing data write")
+	}
+	got, err := s.GetEnvironment(store.GetEnvironmentOptions{URL: "github.com/org/app", Env: "production"})
+	if err != nil {
+		t.Fatalf("GetEnvironment() error = %v", err)
+	}
+	if got.Variables["A"] != "1" || !slices.Equal(got.Plain, []string{"A"}) {
+		t.Errorf("GetEnvironment() = %v plain %v, want the previous version", got.Variables, got.Plain)
+	}
+}
+
+func TestPutManyPlainKeys(t *testing.T) {
+	kv, s := newFakeKV(t)
+	variables := map[string]string{}
+	plain := []string{}
+	for i := range 100 {
+		key := fmt.Sprintf("PUBLIC_VARIABLE_%03d", i)
+		variables[key] = "value"
+		plain = append(plain, key)
+	}
+	if _, err := s.Put(store.PutOptions{Name: "app", Env: "production", URL: "github.com/org/app", Variables: variables, Plain: plain}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, ok := kv.secrets["openv/github.com/org/app/production"].metadata["plain"]; ok {
+		t.Error("plain keys were stored in the custom metadata")
+	}
+
+	got, err := s.GetEnvironment(store.GetEnvironmentOptions{URL: "github.com/org/app", Env: "production"})
+	if err != nil {
+		t.Fatalf("GetEnvironment() error = %v", err)
+	}
+	if !slices.Equal(got.Plain, plain) {
+		t.Errorf("GetEnvironment() plain = %v, want %v", got.Plain, plain)
+	}
+	if _, ok := got.Variables[plainDataKey]; ok {
+		t.Errorf("GetEnvironment() variables include %s", plainDataKey)
+	}
+}
+
+func TestPutKeepsOldLocationOnFailure(t *testing.T) {
+	kv, s := newFakeKV(t)
+	if _, err := s.Put(store.PutOptions{Name: "app", Env: "production", URL: "github.com/org/app", Variables: map[string]string{"A": "1"}}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	kv.failData = true
+	if _, err := s.Put(store.PutOptions{Name: "app", Env: "production", URL: "github.com/org/renamed", Variables: map[string]string{"A": "1"}}); err == nil {
+		t.Fatal("Put() succeeded with a failing data write")
+	}
+	if _, err := s.GetEnvironment(store.GetEnvironmentOptions{URL: "github.com/org/app", Env: "production"}); err != nil {
+		t.Errorf("GetEnvironment() of the old location error = %v", err)
+	}
+	if _, err := s.GetEnvironment(store.GetEnvironmentOptions{URL: "github.com/org/renamed", Env: "production"}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetEnvironment() of the new location error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFindExistingItemReadsOnlyMatchingEnv(t *testing.T) {
+	kv, s := newFakeKV(t)
+	for _, opts := range []store.PutOptions{
+		{Name: "app", Env: "production", URL: "github.com/org/app"},
+		{Name: "app", Env: "staging", URL: "github.com/org/app"},
+		{Name: "api", Env: "staging", URL: "github.com/org/api"},
+		{Name: "web", Env: "preview", URL: "github.com/org/web"},
+	} {
+		if _, err := s.Put(opts); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+
+	kv.requests = nil
+	existing, err := s.FindExistingItem("app", "staging")
+	if err != nil || existing == nil || existing.URL != "github.com/org/app" {
+		t.Fatalf("FindExistingItem() = %v, %v", existing, err)
+	}
+	reads := 0
+	for _, request := range kv.requests {
+		if strings.HasPrefix(request, "GET ") {
+			reads++
+		}
+	}
+	if reads > 2 {
+		t.Errorf("FindExistingItem() read %d secrets, want at most the 2 staging secrets: %v", reads, kv.requests)
+	}
+}
@@ -0,0 +1,343 @@
+package vercel
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/logging"
+	"github.com/hinterland-software/openv/internal/plan"
+	"github.com/hinterland-software/openv/internal/rest"
+)
+
+// DefaultBaseURL is the base URL of the Vercel API
+const DefaultBaseURL = "https://api.vercel.com"
+
+// Standard Vercel environments, any other environment is a custom environment
+const (
+	EnvironmentProduction  = "production"
+	EnvironmentPreview     = "preview"
+	EnvironmentDevelopment = "development"
+)
+
+const (
+	typeEncrypted = "encrypted"
+	typePlain     = "plain"
+)
+
+type envVar struct {
+	ID                   string   `json:"id,omitempty"`
+	Key                  string   `json:"key"`
+	Value                string   `json:"value"`
+	Type                 string   `json:"type"`
+	Target               []string `json:"target,omitempty"`
+	GitBranch            string   `json:"gitBranch,omitempty"`
+	CustomEnvironmentIDs []string `json:"customEnvironmentIds,omitempty"`
+}
+
+// selector identifies the environment, and for previews the git branch, variables are synced to
+type selector struct {
+	target              string
+	gitBranch           string
+	customEnvironmentID string
+}
+
+// VercelService handles syncing environment variables to Vercel projects
+type VercelService struct {
+	client *rest.Client
+	ctx    context.Context
+}
+
+// NewVercelService creates a new VercelService, an empty baseURL uses DefaultBaseURL
+func NewVercelService(token, baseURL string) *VercelService {
+	ctx := context.Background()
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client := rest.NewClient(ctx, baseURL, map[string]string{
+		"Authorization": "Bearer " + token,
+	})
+	return &VercelService{client: client, ctx: ctx}
+}
+
+// SyncToEnvironment syncs environment variables to an environment of a Vercel project.
+// project is the project ID or name, prefixed with the team ID or slug for team projects, e.g. my-team/my-project.
+// environment is production, preview, development or the slug of a custom environment.
+// gitBranch limits preview variables to a branch. Keys listed in plain are stored as plain, all others encrypted.
+func (s *VercelService) SyncToEnvironment(project, environment, gitBranch string, envVars map[string]string, plain []string, withCleanup bool) error {
+	p, err := parseProject(project)
+	if err != nil {
+		return err
+	}
+	sel, err := s.resolveSelector(p, environment, gitBranch)
+	if err != nil {
+		return err
+	}
+	existing, err := s.listEnv(p)
+	if err != nil {
+		return err
+	}
+
+	logging.Logger.Debug("syncing to Vercel environment",
+		"project", p.name,
+		"environment", environment,
+		"git_branch", gitBranch)
+
+	if withCleanup {
+		s.cleanupEnvironment(p, sel, existing, envVars)
+	}
+
+	created := []envVar{}
+	for key, value := range envVars {
+		varType := typeEncrypted
+		if slices.Contains(plain, key) {
+			varType = typePlain
+		}
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_VERCEL
+			varType = typePlain
+		}
+
+		current := findVar(existing, key, sel)
+		if current == nil {
+			created = append(created, sel.newVar(key, value, varType))
+			continue
+		}
+
+		// Encrypted values cannot be compared and are always updated
+		if current.Type == typePlain && varType == typePlain && current.Value == value {
+			continue
+		}
+
+		if sel.exclusive(*current) {
+			if err := s.client.Do("PATCH", p.path("/v9/projects/%s/env/%s", current.ID), map[string]string{
+				"value": value,
+				"type":  varType,
+			}, nil); err != nil {
+				return fmt.Errorf("failed to update Vercel environment variable %s: %w", key, err)
+			}
+			continue
+		}
+
+		// The variable is shared with other environments, move it out of them first
+		if err := s.removeFromEnvironment(p, sel, *current); err != nil {
+			return fmt.Errorf("failed to update Vercel environment variable %s: %w", key, err)
+		}
+		created = append(created, sel.newVar(key, value, varType))
+	}
+
+	if len(created) > 0 {
+		if err := s.client.Do("POST", p.path("/v10/projects/%s/env"), created, nil); err != nil {
+			return fmt.Errorf("failed to create Vercel environment variables: %w", err)
+		}
+	}
+	return nil
+}
+
+// PlanEnvironment computes the changes SyncToEnvironment would apply
+func (s *VercelService) PlanEnvironment(project, environment, gitBranch string, envVars map[string]string) (*plan.Plan, error) {
+	p, err := parseProject(project)
+	if err != nil {
+		return nil, err
+	}
+	sel, err := s.resolveSelector(p, environment, gitBranch)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.listEnv(p)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := make(map[string]string, len(envVars))
+	for key, value := range envVars {
+		if key == internal.OPENV_KEYS {
+			key = internal.OPENV_KEYS_VERCEL
+		}
+		desired[key] = value
+	}
+
+	current := map[string]*string{}
+	for _, variable := range existing {
+		if !sel.matches(variable) {
+			continue
+		}
+		current[variable.Key] = nil
+		if variable.Type == typePlain {
+			current[variable.Key] = &variable.Value
+		}
+	}
+
+	owned := []string{}
+	if value := current[internal.OPENV_KEYS_VERCEL]; value != nil {
+		owned = internal.ParseKeys(*value)
+	}
+
+	name := environment
+	if gitBranch != "" {
+		name += " (" + gitBranch + ")"
+	}
+	return plan.Compute(fmt.Sprintf("Vercel environment %s of %s", name, project), desired, plan.State{Values: current, Owned: owned}), nil
+}
+
+// cleanupEnvironment removes variables from the environment
+// that were synced before but are no longer present in envVars
+func (s *VercelService) cleanupEnvironment(p project, sel selector, existing []envVar, envVars map[string]string) {
+	bookkeeping := findVar(existing, internal.OPENV_KEYS_VERCEL, sel)
+	if bookkeeping == nil {
+		return
+	}
+
+	for _, removed := range internal.ParseKeys(bookkeeping.Value) {
+		if _, exists := envVars[removed]; exists {
+			continue
+		}
+		variable := findVar(existing, removed, sel)
+		if variable == nil {
+			continue
+		}
+		if err := s.removeFromEnvironment(p, sel, *variable); err != nil {
+			logging.Logger.Warn("failed to delete Vercel environment variable", "key", removed, "error", err)
+		}
+	}
+}
+
+// removeFromEnvironment deletes a variable, or only removes the environment from it if it is shared with others
+func (s *VercelService) removeFromEnvironment(p project, sel selector, variable envVar) error {
+	if sel.exclusive(variable) {
+		return s.client.Do("DELETE", p.path("/v9/projects/%s/env/%s", variable.ID), nil, nil)
+	}
+	return s.client.Do("PATCH", p.path("/v9/projects/%s/env/%s", variable.ID), map[string][]string{
+		"target":               slices.DeleteFunc(slices.Clone(variable.Target), func(t string) bool { return t == sel.target }),
+		"customEnvironmentIds": slices.DeleteFunc(slices.Clone(variable.CustomEnvironmentIDs), func(id string) bool { return id == sel.customEnvironmentID }),
+	}, nil)
+}
+
+// resolveSelector looks up the ID of custom environments
+func (s *VercelService) resolveSelector(p project, environment, gitBranch string) (selector, error) {
+	switch environment {
+	case EnvironmentPreview:
+		return selector{target: environment, gitBranch: gitBranch}, nil
+	case EnvironmentProduction, EnvironmentDevelopment:
+		return selector{target: environment}, nil
+	}
+
+	response := struct {
+		Environments []struct {
+			ID   string `json:"id"`
+			Slug string `json:"slug"`
+		} `json:"environments"`
+	}{}
+	if err := s.client.Do("GET", p.path("/v9/projects/%s/custom-environments"), nil, &response); err != nil {
+		return selector{}, fmt.Errorf("failed to list Vercel custom environments: %w", err)
+	}
+	for _, custom := range response.Environments {
+		if custom.Slug == environment || custom.ID == environment {
+			return selector{customEnvironmentID: custom.ID}, nil
+		}
+	}
+	return selector{}, fmt.Errorf("Vercel custom environment %s not found in project %s", environment, p.name)
+}
+
+// listEnv lists all environment variables of the project, following pagination.next until the last page
+func (s *VercelService) listEnv(p project) ([]envVar, error) {
+	path := p.path("/v9/projects/%s/env")
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	envs := []envVar{}
+	pagePath := path
+	for {
+		response := struct {
+			Envs       []envVar `json:"envs"`
+			Pagination *struct {
+				Next *int64 `json:"next"`
+			} `json:"pagination"`
+		}{}
+		if err := s.client.Do("GET", pagePath, nil, &response); err != nil {
+			return nil, fmt.Errorf("failed to list Vercel environment variables: %w", err)
+		}
+		envs = append(envs, response.Envs...)
+		if response.Pagination == nil || response.Pagination.Next == nil || len(response.Envs) == 0 {
+			return envs, nil
+		}
+		pagePath = path + separator + "until=" + strconv.FormatInt(*response.Pagination.Next, 10)
+	}
+}
+
+// matches reports whether the variable applies to the selected environment
+func (sel selector) matches(variable envVar) bool {
+	if sel.customEnvironmentID != "" {
+		return slices.Contains(variable.CustomEnvironmentIDs, sel.customEnvironmentID)
+	}
+	return slices.Contains(variable.Target, sel.target) && variable.GitBranch == sel.gitBranch
+}
+
+// exclusive reports whether the variable applies to the selected environment only
+func (sel selector) exclusive(variable envVar) bool {
+	return sel.matches(variable) && len(variable.Target)+len(variable.CustomEnvironmentIDs) == 1
+}
+
+func (sel selector) newVar(key, value, varType string) envVar {
+	variable := envVar{Key: key, Value: value, Type: varType}
+	if sel.customEnvironmentID != "" {
+		variable.CustomEnvironmentIDs = []string{sel.customEnvironmentID}
+	} else {
+		variable.Target = []string{sel.target}
+		variable.GitBranch = sel.gitBranch
+	}
+	return variable
+}
+
+func findVar(existing []envVar, key string, sel selector) *envVar {
+	for i, variable := range existing {
+		if variable.Key == key && sel.matches(variable) {
+			return &existing[i]
+		}
+	}
+	return nil
+}
+
+// project is a Vercel project reference with an optional team
+type project struct {
+	name string
+	team string
+}
+
+// parseProject parses [team/]project, teams are given by ID (team_...) or slug
+func parseProject(ref string) (project, error) {
+	parts := strings.Split(strings.Trim(ref, "/"), "/")
+	switch {
+	case ref == "":
+		return project{}, fmt.Errorf("no Vercel project configured, set the profile URL to [team/]project")
+	case len(parts) == 1:
+		return project{name: parts[0]}, nil
+	case len(parts) == 2:
+		return project{team: parts[0], name: parts[1]}, nil
+	default:
+		return project{}, fmt.Errorf("invalid Vercel project %s, expected [team/]project", ref)
+	}
+}
+
+// path formats an API path with the escaped project name and any further IDs, adding the team query parameter
+func (p project) path(format string, ids ...string) string {
+	args := []any{url.PathEscape(p.name)}
+	for _, id := range ids {
+		args = append(args, url.PathEscape(id))
+	}
+	path := fmt.Sprintf(format, args...)
+	switch {
+	case p.team == "":
+		return path
+	case strings.HasPrefix(p.team, "team_"):
+		return path + "?teamId=" + url.QueryEscape(p.team)
+	default:
+		return path + "?slug=" + url.QueryEscape(p.team)
+	}
+}
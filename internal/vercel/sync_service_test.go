@@ -0,0 +1,198 @@
+package vercel
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/hinterland-software/openv/internal"
+	"github.com/hinterland-software/openv/internal/plan"
+)
+
+// fakeVercel serves the environment variables of one project, pageSize per page
+type fakeVercel struct {
+	t        *testing.T
+	mu       sync.Mutex
+	vars     []envVar
+	pageSize int
+	requests []string
+}
+
+func newFakeVercel(t *testing.T, pageSize int, vars ...envVar) (*fakeVercel, *VercelService) {
+	f := &fakeVercel{t: t, vars: vars, pageSize: pageSize}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, NewVercelService("token", server.URL)
+}
+
+func (f *fakeVercel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.RequestURI())
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/v9/projects/app/env":
+		// Pages are addressed by the index of their first variable, passed as until
+		start := 0
+		if until := r.URL.Query().Get("until"); until != "" {
+			start, _ = strconv.Atoi(until)
+		}
+		end := min(start+f.pageSize, len(f.vars))
+		var next *int64
+		if end < len(f.vars) {
+			n := int64(end)
+			next = &n
+		}
+		writeJSON(w, map[string]any{
+			"envs":       f.vars[start:end],
+			"pagination": map[string]any{"count": end - start, "next": next},
+		})
+	case r.Method == "POST" && r.URL.Path == "/v10/projects/app/env":
+		created := []envVar{}
+		if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+			f.t.Errorf("invalid create body: %v", err)
+		}
+		for _, v := range created {
+			v.ID = "env_" + v.Key
+			f.vars = append(f.vars, v)
+		}
+		writeJSON(w, map[string]any{"created": created})
+	case r.Method == "PATCH":
+		var update envVar
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			f.t.Errorf("invalid update body: %v", err)
+		}
+		for i, v := range f.vars {
+			if "/v9/projects/app/env/"+v.ID == r.URL.Path {
+				f.vars[i].Value = update.Value
+				f.vars[i].Type = update.Type
+			}
+		}
+		writeJSON(w, update)
+	case r.Method == "DELETE":
+		f.vars = slices.DeleteFunc(f.vars, func(v envVar) bool {
+			return "/v9/projects/app/env/"+v.ID == r.URL.Path
+		})
+		writeJSON(w, map[string]any{})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeVercel) find(key string) []envVar {
+	found := []envVar{}
+	for _, v := range f.vars {
+		if v.Key == key {
+			found = append(found, v)
+		}
+	}
+	return found
+}
+
+func productionVar(key, value string) envVar {
+	return envVar{ID: "env_" + key, Key: key, Value: value, Type: typePlain, Target: []string{EnvironmentProduction}}
+}
+
+func TestListEnvFollowsPagination(t *testing.T) {
+	vars := []envVar{}
+	for i := range 5 {
+		vars = append(vars, productionVar("KEY_"+strconv.Itoa(i), "v"))
+	}
+	f, service := newFakeVercel(t, 2, vars...)
+
+	got, err := service.listEnv(project{name: "app"})
+	if err != nil {
+		t.Fatalf("listEnv() error = %v", err)
+	}
+	if len(got) != 5 {
+		t.Errorf("listEnv() returned %d variables, want 5", len(got))
+	}
+	want := []string{"GET /v9/projects/app/env", "GET /v9/projects/app/env?until=2", "GET /v9/projects/app/env?until=4"}
+	if !slices.Equal(f.requests, want) {
+		t.Errorf("requests = %v, want %v", f.requests, want)
+	}
+}
+
+func TestListEnvPaginationWithTeam(t *testing.T) {
+	f, service := newFakeVercel(t, 1, productionVar("A", "1"), productionVar("B", "2"))
+
+	got, err := service.listEnv(project{name: "app", team: "team_1"})
+	if err != nil {
+		t.Fatalf("listEnv() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("listEnv() returned %d variables, want 2", len(got))
+	}
+	if f.requests[1] != "GET /v9/projects/app/env?teamId=team_1&until=1" {
+		t.Errorf("second page request = %s", f.requests[1])
+	}
+}
+
+func TestSyncToEnvironmentUsesLaterPages(t *testing.T) {
+	f, service := newFakeVercel(t, 1,
+		productionVar("UNMANAGED", "keep"),
+		productionVar("REMOVED", "gone"),
+		productionVar("CHANGED", "old"),
+		productionVar(internal.OPENV_KEYS_VERCEL, `["CHANGED","REMOVED"]`),
+	)
+
+	err := service.SyncToEnvironment("app", EnvironmentProduction, "", map[string]string{
+		"CHANGED":           "new",
+		internal.OPENV_KEYS: `["CHANGED"]`,
+	}, []string{"CHANGED"}, true)
+	if err != nil {
+		t.Fatalf("SyncToEnvironment() error = %v", err)
+	}
+
+	if changed := f.find("CHANGED"); len(changed) != 1 || changed[0].Value != "new" {
+		t.Errorf("CHANGED = %+v, want one variable updated in place", changed)
+	}
+	if bookkeeping := f.find(internal.OPENV_KEYS_VERCEL); len(bookkeeping) != 1 || bookkeeping[0].Value != `["CHANGED"]` {
+		t.Errorf("%s = %+v, want one updated bookkeeping variable", internal.OPENV_KEYS_VERCEL, bookkeeping)
+	}
+	if len(f.find("REMOVED")) != 0 {
+		t.Error("REMOVED was not deleted")
+	}
+	if len(f.find("UNMANAGED")) != 1 {
+		t.Error("UNMANAGED was deleted")
+	}
+}
+
+func TestPlanEnvironmentUsesLaterPages(t *testing.T) {
+	_, service := newFakeVercel(t, 1,
+		productionVar("SAME", "1"),
+		productionVar("REMOVED", "gone"),
+		productionVar(internal.OPENV_KEYS_VERCEL, `["REMOVED","SAME"]`),
+	)
+
+	p, err := service.PlanEnvironment("app", EnvironmentProduction, "", map[string]string{
+		"SAME":              "1",
+		internal.OPENV_KEYS: `["SAME"]`,
+	})
+	if err != nil {
+		t.Fatalf("PlanEnvironment() error = %v", err)
+	}
+	got := map[string]plan.Action{}
+	for _, change := range p.Changes {
+		got[change.Key] = change.Action
+	}
+	want := map[string]plan.Action{
+		"SAME":                     plan.ActionUnchanged,
+		"REMOVED":                  plan.ActionDelete,
+		internal.OPENV_KEYS_VERCEL: plan.ActionUpdate,
+	}
+	for key, action := range want {
+		if got[key] != action {
+			t.Errorf("plan action of %s = %s, want %s", key, got[key], action)
+		}
+	}
+}